@@ -1,31 +1,74 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"ovsdb-viewer/internal/ovsdb"
 
 	ovsdbovsdb "github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct
 type App struct {
-	ctx         context.Context
-	ovsdbClient *ovsdb.OVSDBClient
-	history     []ConnectionHistory
+	ctx context.Context
+	// mu guards sessions; Wails runs bound methods concurrently
+	mu         sync.RWMutex
+	sessions   map[string]*session
+	sessionSeq uint64
+
+	*stores
+	// emit, when set, receives the events meant for the frontend; the CLI answers prompts
+	// and prints table updates with it
+	emit func(name string, data interface{})
+	// remote is set on the Apps of web sessions, whose requests come from browsers: the
+	// endpoints they pass may not run commands or name files of the server
+	remote bool
+	// user names who the requests of a web session come from in the audit log; empty for
+	// the OS user
+	user string
+
+	promptMu  sync.Mutex
+	prompts   map[string]chan promptAnswer
+	promptSeq uint64
+}
+
+// stores hold what an App keeps in files between runs: the connection history, profiles,
+// settings and the audit log. The Apps of web sessions share one set.
+type stores struct {
+	historyMu      sync.Mutex
+	history        []ConnectionHistory
+	profilesMu     sync.Mutex
+	profiles       []Profile
+	sharedProfiles []Profile
+	settingsMu     sync.Mutex
+	settings       Settings
+	audit          *ovsdb.AuditLog
+
+	// profilesErr is why profiles.json could not be read; the file is not written over
+	// until it loads, so the profiles in it are not lost
+	profilesErr error
 }
 
 const historyVersion = 2
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{stores: &stores{settings: Settings{ReadOnlyDefault: true}}}
+}
+
+// newWebApp creates the App of a web session, sharing the stores of app, which has started;
+// user is recorded in the audit log for the session's transactions
+func newWebApp(app *App, user string) *App {
+	return &App{ctx: app.ctx, stores: app.stores, remote: true, user: user}
 }
 
 // startup is called when the app starts. The context is saved
@@ -33,83 +76,444 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	a.LoadHistory()
+	a.LoadSettings()
+	a.LoadProfiles()
+	if path, err := ovsdb.DefaultAuditLogPath(); err == nil {
+		a.audit = ovsdb.NewAuditLog(path)
+	}
+}
+
+// shutdown is called when the app is closing; every session is disconnected
+func (a *App) shutdown(ctx context.Context) {
+	a.closeSessions()
+}
+
+// ConnectOVSDB connects a session to the Open_vSwitch database, see ConnectDynamic
+func (a *App) ConnectOVSDB(sessionID string, req ConnectRequest) (string, error) {
+	return a.ConnectDynamic(sessionID, req, "Open_vSwitch")
 }
 
-// ConnectOVSDB connects to the OVSDB server using the provided configuration
-func (a *App) ConnectOVSDB(req ConnectRequest) error {
-	return a.ConnectDynamic(req, "Open_vSwitch")
+// ConnectDynamic connects to the OVSDB server using the dynamic client. With an empty
+// sessionID a new session is opened next to the others; otherwise the session's connection
+// is replaced. It returns the session's id. In a web session, command endpoints and paths
+// to keys, certificates or known hosts files are refused; only profiles may use them.
+func (a *App) ConnectDynamic(sessionID string, req ConnectRequest, dbName string) (string, error) {
+	return a.connect(sessionID, req, dbName, a.remote)
 }
 
-// ConnectDynamic connects to the OVSDB server using the dynamic client
-func (a *App) ConnectDynamic(req ConnectRequest, dbName string) error {
+// connect is ConnectDynamic; restricted refuses endpoints that use the server's commands
+// or files
+func (a *App) connect(sessionID string, req ConnectRequest, dbName string, restricted bool) (string, error) {
 	endpoints := normalizeEndpoints(req.Endpoints)
 	if len(endpoints) == 0 {
-		return fmt.Errorf("no endpoints provided")
+		return "", fmt.Errorf("no endpoints provided")
 	}
 
-	if a.ovsdbClient != nil {
-		a.ovsdbClient.Disconnect()
-		a.ovsdbClient = nil
+	members := make([]ovsdb.Member, 0, len(endpoints))
+	names := make([]string, 0, len(endpoints))
+	for _, ep := range endpoints {
+		cfg, err := a.connectionConfig(ep, restricted)
+		if err != nil {
+			return "", err
+		}
+		members = append(members, ovsdb.Member{Endpoint: ep.Endpoint, Config: cfg})
+		names = append(names, ep.Endpoint)
+	}
+	if sessionID == "" {
+		sessionID = a.newSessionID()
+	}
+	s := &session{
+		id:             sessionID,
+		endpoints:      names,
+		database:       dbName,
+		readOnlySource: ReadOnlySourceDefault,
+	}
+	s.client = a.newClient(s)
+	s.client.ReadOnly = a.readOnlyDefault()
+	s.client.Audit = a.audit
+	s.client.AuditUser = a.user
+	s.client.OnAuditError = a.emitAuditError
+	if req.ReadOnly != nil {
+		s.client.ReadOnly = *req.ReadOnly
+		s.readOnlySource = ReadOnlySourceConnection
+	}
+	opts := ovsdb.ClusterOptions{Follower: strings.TrimSpace(req.Follower)}
+	if opts.Follower != "" {
+		// A follower only forwards writes to the leader, so browsing it stays read-only
+		s.client.ReadOnly = true
+		s.readOnlySource = ReadOnlySourceFollower
 	}
+	if err := s.client.ConnectCluster(a.ctx, members, dbName, opts); err != nil {
+		return "", fmt.Errorf("failed to connect to any endpoint: %w", err)
+	}
+	a.register(s)
+	a.AddToHistory(ConnectionHistory{
+		Version:   historyVersion,
+		Endpoints: cloneEndpoints(endpoints),
+		ReadOnly:  req.ReadOnly,
+		Timestamp: time.Now().Unix(),
+	})
+	_ = a.SaveHistory()
+	return sessionID, nil
+}
 
-	var lastErr error
-	for _, ep := range endpoints {
-		client := &ovsdb.OVSDBClient{}
-		cfg := ovsdb.ConnectionConfig{}
-		if ep.Tunnel != nil {
-			cfg = tunnelConfigToConnectionConfig(ep.Tunnel)
+// newClient returns a client whose events carry the session's id
+func (a *App) newClient(s *session) *ovsdb.OVSDBClient {
+	return &ovsdb.OVSDBClient{
+		OnStateChange: func(event ovsdb.StateEvent) {
+			if !s.replaced.Load() {
+				a.emitConnectionState(s.id, event)
+			}
+		},
+		OnTableUpdate: func(delta ovsdb.TableDelta) {
+			if !s.replaced.Load() {
+				a.emitTableUpdate(s.id, delta)
+			}
+		},
+	}
+}
+
+// OpenDatabaseFile opens a standalone or clustered database file in place of a server, for
+// browsing offline and read-only, in a session like ConnectDynamic does. With an empty path
+// the file is chosen with the open dialog; it returns nil if the dialog was cancelled.
+func (a *App) OpenDatabaseFile(sessionID string, path string) (*FileSession, error) {
+	if path == "" {
+		var err error
+		path, err = runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+			Title:   "Open database file",
+			Filters: []runtime.FileFilter{{DisplayName: "OVSDB database files", Pattern: "*.db"}, {DisplayName: "All files", Pattern: "*"}},
+		})
+		if err != nil || path == "" {
+			return nil, err
 		}
-		err := client.Connect(a.ctx, cfg, ep.Endpoint, dbName)
-		if err == nil {
-			a.ovsdbClient = client
-			a.AddToHistory(ConnectionHistory{
-				Version:   historyVersion,
-				Endpoints: cloneEndpoints(endpoints),
-				Timestamp: time.Now().Unix(),
-			})
-			_ = a.SaveHistory()
-			return nil
+	}
+	if sessionID == "" {
+		sessionID = a.newSessionID()
+	}
+
+	s := &session{id: sessionID, readOnlySource: ReadOnlySourceFile}
+	s.client = a.newClient(s)
+	info, err := s.client.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s.endpoints = []string{"file:" + info.Path}
+	s.database = info.Database
+	a.register(s)
+	return &FileSession{SessionID: sessionID, DBFileInfo: info}, nil
+}
+
+// GetDatabaseFileInfo describes the database file a session opened with OpenDatabaseFile,
+// or returns nil when it is connected to a server
+func (a *App) GetDatabaseFileInfo(sessionID string) *ovsdb.DBFileInfo {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil
+	}
+	return client.FileInfo()
+}
+
+// GetFileTransactions lists the transactions of the session's database file, oldest first,
+// with their time, comment and the rows they changed in each table
+func (a *App) GetFileTransactions(sessionID string) ([]ovsdb.FileTransaction, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.FileTransactions()
+}
+
+// SeekDatabaseFile shows the session's database file as it was just after a transaction,
+// counted from 1, or as it is now for 0; tables read afterwards reflect that state
+func (a *App) SeekDatabaseFile(sessionID string, number int) (*ovsdb.DBFileInfo, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.SeekFile(number)
+}
+
+// connectionConfig translates a normalized endpoint into the client configuration. When
+// restricted, an endpoint that runs a command or names a file of the server is refused.
+func (a *App) connectionConfig(ep EndpointConfig, restricted bool) (ovsdb.ConnectionConfig, error) {
+	if restricted {
+		if ep.Mode == EndpointModeCommand {
+			return ovsdb.ConnectionConfig{}, fmt.Errorf("endpoint %s: command endpoints are only available through profiles in server mode", ep.Endpoint)
+		}
+		if files := endpointFiles(ep); len(files) > 0 {
+			return ovsdb.ConnectionConfig{}, fmt.Errorf("endpoint %s: files of the server, such as %s, can only be named by profiles in server mode", ep.Endpoint, files[0])
+		}
+		if err := checkPromptedAuth(ep.Tunnel); err != nil {
+			return ovsdb.ConnectionConfig{}, fmt.Errorf("endpoint %s: %w", ep.Endpoint, err)
 		}
-		lastErr = err
 	}
+	cfg := ovsdb.ConnectionConfig{}
+	switch ep.Mode {
+	case EndpointModeSSH:
+		cfg = tunnelConfigToConnectionConfig(ep.Tunnel)
+		cfg.ConfirmHostKey = a.promptHostKey
+		cfg.Prompt = a.promptAuth
+	case EndpointModeCommand:
+		cfg.Command = commandConfigToOVSDB(ep.Command)
+	}
+	cfg.TLS = tlsConfigToOVSDB(ep.TLS)
+	return cfg, nil
+}
 
-	if lastErr == nil {
-		lastErr = fmt.Errorf("all endpoints were empty")
+// checkPromptedAuth makes sure every hop of a tunnel authenticates with an answer the user
+// gives, a password or keyboard-interactive, rather than with the server's ssh-agent or its
+// default and ssh_config identities, which are used when no method is configured
+func checkPromptedAuth(t *TunnelConfig) error {
+	if t == nil {
+		return nil
+	}
+	if len(t.Auth) == 0 {
+		return fmt.Errorf("SSH tunnels need password or keyboard-interactive auth in server mode; the server's ssh-agent and keys are only available through profiles")
 	}
-	return fmt.Errorf("failed to connect to any endpoint: %w", lastErr)
+	methods := append([]AuthMethodConfig{}, t.Auth...)
+	for _, jump := range t.JumpAuth {
+		methods = append(methods, jump...)
+	}
+	for _, m := range methods {
+		if m.Type != ovsdb.AuthPassword && m.Type != ovsdb.AuthKeyboardInteractive {
+			return fmt.Errorf("%s auth uses the server's identities and is only available through profiles in server mode", m.Type)
+		}
+	}
+	return nil
 }
 
-// DisconnectOVSDB disconnects from the OVSDB server
-func (a *App) DisconnectOVSDB() error {
-	if a.ovsdbClient != nil {
-		a.ovsdbClient.Disconnect()
+// endpointFiles lists the files of the machine an endpoint names: SSH keys, certificates
+// and known hosts files, and TLS keys and certificates
+func endpointFiles(ep EndpointConfig) []string {
+	var files []string
+	if t := ep.Tunnel; t != nil {
+		files = append(files, t.KeyFile, t.KnownHostsFile)
+		methods := append([]AuthMethodConfig{}, t.Auth...)
+		for _, jump := range t.JumpAuth {
+			methods = append(methods, jump...)
+		}
+		for _, m := range methods {
+			files = append(files, m.KeyFile, m.CertFile)
+		}
 	}
+	if t := ep.TLS; t != nil {
+		files = append(files, t.PrivateKey, t.Certificate, t.CACert)
+	}
+	named := files[:0]
+	for _, file := range files {
+		if file != "" {
+			named = append(named, file)
+		}
+	}
+	return named
+}
+
+// DisconnectOVSDB disconnects a session and closes it
+func (a *App) DisconnectOVSDB(sessionID string) error {
+	a.closeSession(sessionID)
 	return nil
 }
 
+// ConnectionState is emitted to the frontend as the "connection:state" event whenever the
+// connection state changes, including while reconnecting in the background
+type ConnectionState struct {
+	Session  string `json:"session"`
+	State    string `json:"state"` // connecting, connected, reconnecting, failed or disconnected
+	Endpoint string `json:"endpoint"`
+	Attempt  int    `json:"attempt,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// GetConnectionState returns the state of a session's connection
+func (a *App) GetConnectionState(sessionID string) string {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return ovsdb.StateDisconnected
+	}
+	return client.State()
+}
+
+// TableUpdate is emitted to the frontend as the "table:update" event with the changes to a
+// monitored table. When reset is set, inserted holds the whole table and replaces the rows
+// shown so far.
+type TableUpdate struct {
+	Session  string           `json:"session"`
+	Database string           `json:"database"`
+	Table    string           `json:"table"`
+	Reset    bool             `json:"reset,omitempty"`
+	Inserted []ovsdb.TypedRow `json:"inserted,omitempty"`
+	Modified []ovsdb.TypedRow `json:"modified,omitempty"`
+	Deleted  []string         `json:"deleted,omitempty"`
+}
+
+func (a *App) emitTableUpdate(sessionID string, delta ovsdb.TableDelta) {
+	a.emitEvent("table:update", TableUpdate{
+		Session:  sessionID,
+		Database: delta.Database,
+		Table:    delta.Table,
+		Reset:    delta.Reset,
+		Inserted: delta.Inserted,
+		Modified: delta.Modified,
+		Deleted:  delta.Deleted,
+	})
+}
+
+// ClusterMemberStatus is the role of one configured endpoint as reported by its _Server database.
+// ovsdb-server does not publish the Raft term there, so only the log index is available.
+type ClusterMemberStatus struct {
+	Endpoint  string `json:"endpoint"`
+	Role      string `json:"role"` // leader, follower, disconnected, standalone, relay or unreachable
+	Active    bool   `json:"active"`
+	Model     string `json:"model,omitempty"`
+	Connected bool   `json:"connected"`
+	ClusterID string `json:"clusterId,omitempty"`
+	ServerID  string `json:"serverId,omitempty"`
+	Index     int    `json:"index,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GetClusterStatus returns the role of every configured endpoint for a session's database
+func (a *App) GetClusterStatus(sessionID string) ([]ClusterMemberStatus, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := client.ClusterStatus(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]ClusterMemberStatus, 0, len(members))
+	for _, m := range members {
+		status := ClusterMemberStatus{
+			Endpoint:  m.Endpoint,
+			Role:      m.Role,
+			Active:    m.Active,
+			Model:     m.Database.Model,
+			Connected: m.Database.Connected,
+			ClusterID: m.Database.Cid,
+			ServerID:  m.Database.Sid,
+			Index:     m.Database.Index,
+		}
+		if m.Err != nil {
+			status.Error = m.Err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (a *App) emitConnectionState(sessionID string, event ovsdb.StateEvent) {
+	state := ConnectionState{
+		Session:  sessionID,
+		State:    event.State,
+		Endpoint: event.Endpoint,
+		Attempt:  event.Attempt,
+	}
+	if event.Err != nil {
+		state.Error = event.Err.Error()
+	}
+	a.emitEvent("connection:state", state)
+}
+
+// emitEvent sends an event to the frontend, or to emit when it is set
+func (a *App) emitEvent(name string, data interface{}) {
+	if a.emit != nil {
+		a.emit(name, data)
+		return
+	}
+	runtime.EventsEmit(a.ctx, name, data)
+}
+
+// emitAuditError tells the frontend, as the "audit:error" event, that a transaction was
+// sent but could not be recorded in the audit log
+func (a *App) emitAuditError(err error) {
+	a.emitEvent("audit:error", err.Error())
+}
+
+// GetAuditLog returns the audited transactions matching the filter, newest first
+func (a *App) GetAuditLog(filter ovsdb.AuditFilter) ([]ovsdb.AuditRecord, error) {
+	if a.audit == nil {
+		return nil, fmt.Errorf("audit log is not available")
+	}
+	return a.audit.Records(filter)
+}
+
+// GetAuditLogPath returns the file the audit log is kept in
+func (a *App) GetAuditLogPath() string {
+	if a.audit == nil {
+		return ""
+	}
+	return a.audit.Path()
+}
+
 // ConnectionHistory represents a saved connection configuration
 type ConnectRequest struct {
 	Endpoints []EndpointConfig `json:"endpoints"`
+	// Follower is the endpoint to browse even if it is not the cluster leader. When empty,
+	// the leader among the endpoints is used and followed across leadership changes. A
+	// follower is always browsed read-only.
+	Follower string `json:"follower,omitempty"`
+	// ReadOnly rejects every write on this connection; when unset the global default applies
+	ReadOnly *bool `json:"readOnly,omitempty"`
 }
 
+// Endpoint modes select how an endpoint is reached
+const (
+	EndpointModeDirect  = "direct"
+	EndpointModeSSH     = "ssh"
+	EndpointModeCommand = "command"
+)
+
 type EndpointConfig struct {
-	Endpoint string        `json:"endpoint"`
-	Tunnel   *TunnelConfig `json:"tunnel,omitempty"`
+	Endpoint string         `json:"endpoint"`
+	Mode     string         `json:"mode,omitempty"`
+	Tunnel   *TunnelConfig  `json:"tunnel,omitempty"`
+	Command  *CommandConfig `json:"command,omitempty"`
+	TLS      *TLSConfig     `json:"tls,omitempty"`
 }
 
 type TunnelConfig struct {
-	Host               string   `json:"host"`
-	Port               int      `json:"port"`
-	User               string   `json:"user"`
-	KeyFile            string   `json:"keyFile"`
-	JumpHosts          []string `json:"jumpHosts"`
-	LocalForwarderType string   `json:"localForwarderType"`
+	Host               string                        `json:"host"`
+	Port               int                           `json:"port"`
+	User               string                        `json:"user"`
+	KeyFile            string                        `json:"keyFile"`
+	Auth               []AuthMethodConfig            `json:"auth,omitempty"`
+	JumpHosts          []string                      `json:"jumpHosts"`
+	JumpAuth           map[string][]AuthMethodConfig `json:"jumpAuth,omitempty"`
+	LocalForwarderType string                        `json:"localForwarderType"`
+	KnownHostsFile     string                        `json:"knownHostsFile,omitempty"`
+}
+
+// AuthMethodConfig selects one SSH authentication method for a hop.
+// Type is "agent", "publickey", "password" or "keyboard-interactive"; secrets are never stored.
+type AuthMethodConfig struct {
+	Type     string `json:"type"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	CertFile string `json:"certFile,omitempty"`
+}
+
+// CommandConfig describes a local relay command speaking OVSDB JSON-RPC on its stdin/stdout.
+// "{endpoint}" and "{address}" in args are replaced with the endpoint and its address part.
+type CommandConfig struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Dir     string   `json:"dir,omitempty"`
+}
+
+// TLSConfig holds the certificate paths used for ssl: endpoints
+type TLSConfig struct {
+	PrivateKey      string `json:"privateKey"`
+	Certificate     string `json:"certificate"`
+	CACert          string `json:"caCert"`
+	BootstrapCACert bool   `json:"bootstrapCaCert"`
 }
 
 // ConnectionHistory represents a saved connection configuration
 type ConnectionHistory struct {
 	Version   int              `json:"version"`
 	Endpoints []EndpointConfig `json:"endpoints"`
+	ReadOnly  *bool            `json:"readOnly,omitempty"`
 	Timestamp int64            `json:"timestamp"`
 
 	// Legacy fields kept for upgrade path
@@ -124,11 +528,15 @@ type ConnectionHistory struct {
 
 // GetHistory returns the connection history
 func (a *App) GetHistory() []ConnectionHistory {
-	return a.history
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
+	return append([]ConnectionHistory{}, a.history...)
 }
 
 // AddToHistory adds a new connection to history
 func (a *App) AddToHistory(conn ConnectionHistory) {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
 	conn.Version = historyVersion
 	a.history = append([]ConnectionHistory{conn}, a.history...)
 	seen := make(map[string]bool)
@@ -151,6 +559,8 @@ func (a *App) AddToHistory(conn ConnectionHistory) {
 
 // SaveHistory saves the history to a file
 func (a *App) SaveHistory() error {
+	a.historyMu.Lock()
+	defer a.historyMu.Unlock()
 	data, err := json.Marshal(a.history)
 	if err != nil {
 		return err
@@ -168,35 +578,141 @@ func (a *App) SaveHistory() error {
 
 // LoadHistory loads the history from a file
 func (a *App) LoadHistory() error {
+	history, upgraded, err := readHistory()
+	a.historyMu.Lock()
+	a.history = history
+	a.historyMu.Unlock()
+	if err == nil && upgraded {
+		return a.SaveHistory()
+	}
+	return err
+}
+
+// readHistory reads the history file, upgrading records saved by older versions
+func readHistory() ([]ConnectionHistory, bool, error) {
+	history := []ConnectionHistory{}
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		a.history = []ConnectionHistory{}
-		return nil
+		return history, false, nil
 	}
 	filePath := filepath.Join(homeDir, ".ovsdb-viewer", "connection_history.json")
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			a.history = []ConnectionHistory{}
-			return nil
+			return history, false, nil
 		}
-		return err
+		return history, false, err
 	}
-	if err := json.Unmarshal(data, &a.history); err != nil {
-		return err
+	if err := json.Unmarshal(data, &history); err != nil {
+		return []ConnectionHistory{}, false, err
 	}
 	upgraded := false
-	for i, record := range a.history {
+	for i, record := range history {
 		updated, changed := upgradeHistoryRecord(record)
 		if changed {
 			upgraded = true
-			a.history[i] = updated
+			history[i] = updated
 		}
 	}
-	if upgraded {
-		return a.SaveHistory()
+	return history, upgraded, nil
+}
+
+// Settings holds the application-wide preferences
+type Settings struct {
+	// ReadOnlyDefault applies to connections that do not set ReadOnly themselves
+	ReadOnlyDefault bool `json:"readOnlyDefault"`
+	// SharedProfileDirs hold profile bundles shared by a team, such as a checked out
+	// repository, listed next to the user's own profiles
+	SharedProfileDirs []string `json:"sharedProfileDirs,omitempty"`
+	// TrustedCommands are fingerprints of the relay commands of profiles that were
+	// confirmed to run on this machine, see TrustProfile
+	TrustedCommands []string `json:"trustedCommands,omitempty"`
+}
+
+// Sources of a connection's read-only mode
+const (
+	ReadOnlySourceConnection = "connection"
+	ReadOnlySourceDefault    = "default"
+	ReadOnlySourceFile       = "file"     // database files are always read-only
+	ReadOnlySourceFollower   = "follower" // so are connections to a chosen follower
+)
+
+// ReadOnlyStatus tells whether a session rejects writes, and why
+type ReadOnlyStatus struct {
+	Connected bool   `json:"connected"`
+	ReadOnly  bool   `json:"readOnly"`
+	Source    string `json:"source"` // connection, default, file or follower
+	Default   bool   `json:"default"`
+}
+
+// GetReadOnlyStatus returns the read-only mode of a session, or the default the next
+// connection gets when the session is not connected
+func (a *App) GetReadOnlyStatus(sessionID string) ReadOnlyStatus {
+	readOnly := a.readOnlyDefault()
+	status := ReadOnlyStatus{
+		ReadOnly: readOnly,
+		Source:   ReadOnlySourceDefault,
+		Default:  readOnly,
 	}
-	return nil
+	if s, err := a.session(sessionID); err == nil && s.client.State() != ovsdb.StateDisconnected {
+		status.Connected = true
+		status.ReadOnly = s.client.ReadOnly
+		status.Source = s.readOnlySource
+	}
+	return status
+}
+
+// SetReadOnlyDefault changes the read-only mode of future connections that do not set their
+// own; open sessions keep their mode
+func (a *App) SetReadOnlyDefault(readOnly bool) error {
+	a.settingsMu.Lock()
+	a.settings.ReadOnlyDefault = readOnly
+	a.settingsMu.Unlock()
+	return a.SaveSettings()
+}
+
+func (a *App) readOnlyDefault() bool {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return a.settings.ReadOnlyDefault
+}
+
+// SaveSettings saves the settings to a file
+func (a *App) SaveSettings() error {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	data, err := json.Marshal(a.settings)
+	if err != nil {
+		return err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(homeDir, ".ovsdb-viewer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "settings.json"), data, 0644)
+}
+
+// LoadSettings loads the settings from a file. Without one, connections are read-only.
+func (a *App) LoadSettings() error {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.settings = Settings{ReadOnlyDefault: true}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".ovsdb-viewer", "settings.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &a.settings)
 }
 
 func normalizeEndpoints(endpoints []EndpointConfig) []EndpointConfig {
@@ -210,6 +726,18 @@ func normalizeEndpoints(endpoints []EndpointConfig) []EndpointConfig {
 			ep.Tunnel.Host = strings.TrimSpace(ep.Tunnel.Host)
 			ep.Tunnel.User = strings.TrimSpace(ep.Tunnel.User)
 			ep.Tunnel.KeyFile = strings.TrimSpace(ep.Tunnel.KeyFile)
+			ep.Tunnel.KnownHostsFile = strings.TrimSpace(ep.Tunnel.KnownHostsFile)
+			ep.Tunnel.Auth = normalizeAuthMethods(ep.Tunnel.Auth)
+			if len(ep.Tunnel.JumpAuth) > 0 {
+				jumpAuth := make(map[string][]AuthMethodConfig, len(ep.Tunnel.JumpAuth))
+				for jump, methods := range ep.Tunnel.JumpAuth {
+					jump = strings.TrimSpace(jump)
+					if methods = normalizeAuthMethods(methods); jump != "" && len(methods) > 0 {
+						jumpAuth[jump] = methods
+					}
+				}
+				ep.Tunnel.JumpAuth = jumpAuth
+			}
 			if ep.Tunnel.Host == "" {
 				ep.Tunnel = nil
 			} else {
@@ -231,11 +759,79 @@ func normalizeEndpoints(endpoints []EndpointConfig) []EndpointConfig {
 				}
 			}
 		}
+		if ep.Command != nil {
+			ep.Command.Command = strings.TrimSpace(ep.Command.Command)
+			ep.Command.Dir = strings.TrimSpace(ep.Command.Dir)
+			if ep.Command.Command == "" {
+				ep.Command = nil
+			}
+		}
+		if ep.Mode == "" {
+			switch {
+			case ep.Command != nil:
+				ep.Mode = EndpointModeCommand
+			case ep.Tunnel != nil:
+				ep.Mode = EndpointModeSSH
+			default:
+				ep.Mode = EndpointModeDirect
+			}
+		}
+		switch ep.Mode {
+		case EndpointModeSSH:
+			ep.Command = nil
+			if ep.Tunnel == nil {
+				ep.Mode = EndpointModeDirect
+			}
+		case EndpointModeCommand:
+			ep.Tunnel = nil
+			if ep.Command == nil {
+				ep.Mode = EndpointModeDirect
+			}
+		default:
+			ep.Mode = EndpointModeDirect
+			ep.Tunnel = nil
+			ep.Command = nil
+		}
+		if ep.TLS != nil {
+			ep.TLS.PrivateKey = strings.TrimSpace(ep.TLS.PrivateKey)
+			ep.TLS.Certificate = strings.TrimSpace(ep.TLS.Certificate)
+			ep.TLS.CACert = strings.TrimSpace(ep.TLS.CACert)
+			if !strings.HasPrefix(ep.Endpoint, "ssl:") {
+				ep.TLS = nil
+			}
+		}
 		cleaned = append(cleaned, ep)
 	}
 	return cleaned
 }
 
+func normalizeAuthMethods(methods []AuthMethodConfig) []AuthMethodConfig {
+	if len(methods) == 0 {
+		return nil
+	}
+	cleaned := make([]AuthMethodConfig, 0, len(methods))
+	for _, m := range methods {
+		m.Type = strings.TrimSpace(m.Type)
+		m.KeyFile = strings.TrimSpace(m.KeyFile)
+		m.CertFile = strings.TrimSpace(m.CertFile)
+		if m.Type != "" {
+			cleaned = append(cleaned, m)
+		}
+	}
+	return cleaned
+}
+
+func authMethodsToOVSDB(methods []AuthMethodConfig) []ovsdb.AuthMethod {
+	if len(methods) == 0 {
+		return nil
+	}
+	out := make([]ovsdb.AuthMethod, len(methods))
+	for i, m := range methods {
+		out[i] = ovsdb.AuthMethod{Type: m.Type, KeyFile: m.KeyFile, CertFile: m.CertFile}
+	}
+	return out
+}
+
 func tunnelConfigToConnectionConfig(t *TunnelConfig) ovsdb.ConnectionConfig {
 	if t == nil {
 		return ovsdb.ConnectionConfig{}
@@ -245,26 +841,78 @@ func tunnelConfigToConnectionConfig(t *TunnelConfig) ovsdb.ConnectionConfig {
 		Port:               t.Port,
 		User:               t.User,
 		KeyFile:            t.KeyFile,
+		Auth:               authMethodsToOVSDB(t.Auth),
 		JumpHosts:          append([]string{}, t.JumpHosts...),
 		LocalForwarderType: t.LocalForwarderType,
+		KnownHostsFile:     t.KnownHostsFile,
 	}
 	if cfg.Port == 0 {
 		cfg.Port = 22
 	}
+	if len(t.JumpAuth) > 0 {
+		cfg.JumpAuth = make(map[string][]ovsdb.AuthMethod, len(t.JumpAuth))
+		for jump, methods := range t.JumpAuth {
+			cfg.JumpAuth[jump] = authMethodsToOVSDB(methods)
+		}
+	}
 	return cfg
 }
 
+func commandConfigToOVSDB(c *CommandConfig) *ovsdb.CommandConfig {
+	if c == nil {
+		return nil
+	}
+	return &ovsdb.CommandConfig{
+		Command: c.Command,
+		Args:    append([]string{}, c.Args...),
+		Env:     append([]string{}, c.Env...),
+		Dir:     c.Dir,
+	}
+}
+
+func tlsConfigToOVSDB(t *TLSConfig) *ovsdb.TLSConfig {
+	if t == nil {
+		return nil
+	}
+	return &ovsdb.TLSConfig{
+		PrivateKey:      t.PrivateKey,
+		Certificate:     t.Certificate,
+		CACert:          t.CACert,
+		BootstrapCACert: t.BootstrapCACert,
+	}
+}
+
 func cloneEndpoints(endpoints []EndpointConfig) []EndpointConfig {
 	clones := make([]EndpointConfig, len(endpoints))
 	for i, ep := range endpoints {
 		clones[i].Endpoint = ep.Endpoint
+		clones[i].Mode = ep.Mode
 		if ep.Tunnel != nil {
 			tunnelCopy := *ep.Tunnel
 			if len(ep.Tunnel.JumpHosts) > 0 {
 				tunnelCopy.JumpHosts = append([]string{}, ep.Tunnel.JumpHosts...)
 			}
+			if len(ep.Tunnel.Auth) > 0 {
+				tunnelCopy.Auth = append([]AuthMethodConfig{}, ep.Tunnel.Auth...)
+			}
+			if len(ep.Tunnel.JumpAuth) > 0 {
+				tunnelCopy.JumpAuth = make(map[string][]AuthMethodConfig, len(ep.Tunnel.JumpAuth))
+				for jump, methods := range ep.Tunnel.JumpAuth {
+					tunnelCopy.JumpAuth[jump] = append([]AuthMethodConfig{}, methods...)
+				}
+			}
 			clones[i].Tunnel = &tunnelCopy
 		}
+		if ep.Command != nil {
+			commandCopy := *ep.Command
+			commandCopy.Args = append([]string{}, ep.Command.Args...)
+			commandCopy.Env = append([]string{}, ep.Command.Env...)
+			clones[i].Command = &commandCopy
+		}
+		if ep.TLS != nil {
+			tlsCopy := *ep.TLS
+			clones[i].TLS = &tlsCopy
+		}
 	}
 	return clones
 }
@@ -322,59 +970,253 @@ func upgradeHistoryRecord(record ConnectionHistory) (ConnectionHistory, bool) {
 
 // DeleteHistory removes a connection from history by index
 func (a *App) DeleteHistory(index int) error {
+	a.historyMu.Lock()
 	if index < 0 || index >= len(a.history) {
+		a.historyMu.Unlock()
 		return fmt.Errorf("invalid index")
 	}
-	a.history = append(a.history[:index], a.history[index+1:]...)
+	a.history = append(a.history[:index:index], a.history[index+1:]...)
+	a.historyMu.Unlock()
 	return a.SaveHistory()
 }
 
-// GetOVSDBClient returns the underlying OVSDB client for direct operations
-func (a *App) GetOVSDBClient() *ovsdb.OVSDBClient {
-	return a.ovsdbClient
+// ListSSHHostAliases returns the host aliases declared in ~/.ssh/config with their resolved settings
+func (a *App) ListSSHHostAliases() ([]ovsdb.SSHHostConfig, error) {
+	file, err := ovsdb.DefaultSSHConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	sshConfig, err := ovsdb.LoadSSHConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	aliases := sshConfig.Aliases()
+	hosts := make([]ovsdb.SSHHostConfig, 0, len(aliases))
+	for _, alias := range aliases {
+		hosts = append(hosts, sshConfig.Resolve(alias))
+	}
+	return hosts, nil
+}
+
+// GetOVSDBClient returns the underlying OVSDB client of a session for direct operations
+func (a *App) GetOVSDBClient(sessionID string) *ovsdb.OVSDBClient {
+	client, _ := a.client(sessionID)
+	return client
 }
 
 // GetSchema returns the OVSDB schema
-func (a *App) GetSchema() (*ovsdbovsdb.DatabaseSchema, error) {
-	if a.ovsdbClient == nil {
-		return nil, fmt.Errorf("not connected")
+func (a *App) GetSchema(sessionID string) (*ovsdbovsdb.DatabaseSchema, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	// Default to Open_vSwitch for legacy compatibility if needed,
-	// but GetSchema on client now takes dbName.
-	// However, the client implementation of GetSchema ignores dbName and returns schema of connected client.
-	return a.ovsdbClient.GetSchema(a.ctx, "")
+	// An empty name is the database the client was connected for
+	return client.GetSchema(a.ctx, "")
 }
 
-// GetTable retrieves all rows from the specified table as a slice of maps
-func (a *App) GetTable(table string) ([]map[string]any, error) {
-	if a.ovsdbClient == nil {
-		return nil, fmt.Errorf("not connected")
+// GetTable retrieves all rows from the specified table, with typed values
+func (a *App) GetTable(sessionID string, table string) ([]ovsdb.TypedRow, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	return a.ovsdbClient.GetTableData(a.ctx, table)
+	return client.GetTableData(a.ctx, "", table)
 }
 
 // ListDatabases returns a list of available database names
-func (a *App) ListDatabases() ([]string, error) {
-	if a.ovsdbClient == nil {
-		return nil, fmt.Errorf("not connected")
+func (a *App) ListDatabases(sessionID string) ([]string, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListDatabases(a.ctx)
+}
+
+// GetSchemaDynamic returns the OVSDB schema for any database of the connected server
+func (a *App) GetSchemaDynamic(sessionID string, dbName string) (*ovsdbovsdb.DatabaseSchema, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetSchema(a.ctx, dbName)
+}
+
+// GetTableDynamic retrieves all rows from a table of any database of the server, with every
+// value tagged with its OVSDB type so that it can be shown and written back without guessing
+func (a *App) GetTableDynamic(sessionID string, dbName string, tableName string) ([]ovsdb.TypedRow, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTableData(a.ctx, dbName, tableName)
+}
+
+// QueryTable retrieves the rows of a table matching every condition; the filtering is
+// done by the server
+func (a *App) QueryTable(sessionID string, dbName string, tableName string, conditions []ovsdb.Condition) ([]ovsdb.TypedRow, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTableData(a.ctx, dbName, tableName, conditions...)
+}
+
+// GetTablePage retrieves one page of a table with only the given columns (all when empty),
+// sorted by the sort keys, together with the total number of rows
+func (a *App) GetTablePage(sessionID string, dbName string, tableName string, columns []string, sort []ovsdb.SortKey, offset int, limit int) (*ovsdb.TablePage, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetTablePage(a.ctx, ovsdb.PageRequest{
+		Database: dbName,
+		Table:    tableName,
+		Columns:  columns,
+		Sort:     sort,
+		Offset:   offset,
+		Limit:    limit,
+	})
+}
+
+// PreviewTransaction checks edits against the schema and returns the JSON-RPC transaction
+// ApplyTransaction would send, without the selects it adds to capture rows for undo
+func (a *App) PreviewTransaction(sessionID string, dbName string, edits []ovsdb.Edit) (string, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return client.PreviewTransaction(a.ctx, dbName, edits)
+}
+
+// ApplyTransaction runs edits as one atomic transaction and reports the result of each
+func (a *App) ApplyTransaction(sessionID string, dbName string, edits []ovsdb.Edit) (*ovsdb.TransactionResult, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.ApplyTransaction(a.ctx, dbName, edits)
+}
+
+// GuardedUpdate updates a row only if it still holds the original values it was shown with;
+// when someone changed it in the meantime, nothing is written and the result carries the
+// conflict with the row as it is now
+func (a *App) GuardedUpdate(sessionID string, dbName string, tableName string, uuid string, original map[string]ovsdb.Value, changes map[string]ovsdb.Value) (*ovsdb.TransactionResult, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
 	}
-	return a.ovsdbClient.ListDatabases(a.ctx)
+	return client.GuardedUpdate(a.ctx, dbName, tableName, uuid, original, changes)
 }
 
-// GetSchemaDynamic returns the OVSDB schema for a specific database
-func (a *App) GetSchemaDynamic(dbName string) (*ovsdbovsdb.DatabaseSchema, error) {
-	if a.ovsdbClient == nil {
-		return nil, fmt.Errorf("not connected")
+// GetUndoHistory returns the committed transactions of the connection that can be undone,
+// newest first
+func (a *App) GetUndoHistory(sessionID string) []ovsdb.UndoEntry {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return []ovsdb.UndoEntry{}
+	}
+	return client.UndoHistory()
+}
+
+// UndoLast reverts the most recent transaction not undone yet, unless a row it changed has
+// changed again since; the result then carries the conflict
+func (a *App) UndoLast(sessionID string) (*ovsdb.TransactionResult, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.UndoLast(a.ctx)
+}
+
+// UndoTransaction reverts a transaction of the undo history by its id, with the same guard
+// as UndoLast
+func (a *App) UndoTransaction(sessionID string, id int) (*ovsdb.TransactionResult, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.UndoTransaction(a.ctx, id)
+}
+
+// ExportTable saves a table, narrowed by the conditions and columns and in the order shown,
+// to a file chosen with the save dialog. It returns the file's path, or "" if the dialog
+// was cancelled.
+func (a *App) ExportTable(sessionID string, dbName string, tableName string, format string, conditions []ovsdb.Condition, columns []string, sort []ovsdb.SortKey, csv ovsdb.CSVOptions) (string, error) {
+	return a.export(sessionID, ovsdb.ExportOptions{
+		Format:     format,
+		Database:   dbName,
+		Tables:     []string{tableName},
+		Conditions: conditions,
+		Columns:    columns,
+		Sort:       sort,
+		CSV:        csv,
+	}, tableName)
+}
+
+// ExportDatabase saves every table of a database to a file chosen with the save dialog; a
+// CSV export is a zip archive with a file per table. It returns the file's path, or "" if
+// the dialog was cancelled.
+func (a *App) ExportDatabase(sessionID string, dbName string, format string, csv ovsdb.CSVOptions) (string, error) {
+	return a.export(sessionID, ovsdb.ExportOptions{Format: format, Database: dbName, CSV: csv}, "")
+}
+
+func (a *App) export(sessionID string, opts ovsdb.ExportOptions, tableName string) (string, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return "", err
 	}
-	return a.ovsdbClient.GetSchema(a.ctx, dbName)
+	schema, err := client.GetSchema(a.ctx, opts.Database)
+	if err != nil {
+		return "", err
+	}
+	name := schema.Name
+	if tableName != "" {
+		name += "-" + tableName
+	}
+	ext := ovsdb.ExportExtension(opts)
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export " + name,
+		DefaultFilename: fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), ext),
+		Filters:         []runtime.FileFilter{{DisplayName: strings.ToUpper(ext) + " files", Pattern: "*." + ext}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	w := bufio.NewWriter(f)
+	err = client.Export(a.ctx, w, opts)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// MonitorTable returns the rows of a table and keeps it monitored; changes arrive as
+// "table:update" events until StopMonitorTable is called
+func (a *App) MonitorTable(sessionID string, dbName string, tableName string) ([]ovsdb.TypedRow, error) {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.MonitorTable(a.ctx, dbName, tableName)
 }
 
-// GetTableDynamic retrieves all rows from the specified table using the dynamic client
-func (a *App) GetTableDynamic(dbName string, tableName string) ([]map[string]any, error) {
-	if a.ovsdbClient == nil {
-		return nil, fmt.Errorf("not connected")
+// StopMonitorTable stops the "table:update" events of a table
+func (a *App) StopMonitorTable(sessionID string, dbName string, tableName string) error {
+	client, err := a.client(sessionID)
+	if err != nil {
+		return nil
 	}
-	// Note: dbName is currently ignored by the client as it uses the connected DB,
-	// but we keep it in the signature for future flexibility or validation.
-	return a.ovsdbClient.GetTableData(a.ctx, tableName)
+	return client.CancelMonitor(a.ctx, dbName, tableName)
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ovn-kubernetes/libovsdb/database/inmemory"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb/serverdb"
+	"github.com/ovn-kubernetes/libovsdb/server"
+	libtest "github.com/ovn-kubernetes/libovsdb/test"
+)
+
+func TestConnectionConfigRestricted(t *testing.T) {
+	tests := []struct {
+		name    string
+		ep      EndpointConfig
+		allowed bool
+	}{
+		{"direct", EndpointConfig{Endpoint: "tcp:10.0.0.1:6641"}, true},
+		{"unix socket", EndpointConfig{Endpoint: "unix:/var/run/ovn/ovnnb_db.sock"}, true},
+		{"ssh with password", EndpointConfig{Endpoint: "unix:/var/run/ovn/ovnnb_db.sock", Tunnel: &TunnelConfig{Host: "node1", Auth: []AuthMethodConfig{{Type: "password"}, {Type: "keyboard-interactive"}}}}, true},
+		{"ssh with agent", EndpointConfig{Endpoint: "unix:/var/run/ovn/ovnnb_db.sock", Tunnel: &TunnelConfig{Host: "node1", Auth: []AuthMethodConfig{{Type: "agent"}}}}, false},
+		{"ssh without auth", EndpointConfig{Endpoint: "unix:/var/run/ovn/ovnnb_db.sock", Tunnel: &TunnelConfig{Host: "node1"}}, false},
+		{"ssh with default keys", EndpointConfig{Endpoint: "unix:/var/run/ovn/ovnnb_db.sock", Tunnel: &TunnelConfig{Host: "node1", Auth: []AuthMethodConfig{{Type: "publickey"}}}}, false},
+		{"jump host with agent", EndpointConfig{Endpoint: "tcp:127.0.0.1:6641", Tunnel: &TunnelConfig{Host: "node1", Auth: []AuthMethodConfig{{Type: "password"}}, JumpHosts: []string{"bastion"}, JumpAuth: map[string][]AuthMethodConfig{
+			"bastion": {{Type: "agent"}},
+		}}}, false},
+		{"command", EndpointConfig{Endpoint: "unix:/run/db.sock", Command: &CommandConfig{Command: "kubectl"}}, false},
+		{"ssh key file", EndpointConfig{Endpoint: "tcp:127.0.0.1:6641", Tunnel: &TunnelConfig{Host: "node1", KeyFile: "/root/.ssh/id_rsa"}}, false},
+		{"ssh known hosts", EndpointConfig{Endpoint: "tcp:127.0.0.1:6641", Tunnel: &TunnelConfig{Host: "node1", KnownHostsFile: "/etc/ssh/known"}}, false},
+		{"jump host certificate", EndpointConfig{Endpoint: "tcp:127.0.0.1:6641", Tunnel: &TunnelConfig{Host: "node1", JumpHosts: []string{"bastion"}, JumpAuth: map[string][]AuthMethodConfig{
+			"bastion": {{Type: "publickey", KeyFile: "/k", CertFile: "/k-cert.pub"}},
+		}}}, false},
+		{"tls files", EndpointConfig{Endpoint: "ssl:10.0.0.1:6641", TLS: &TLSConfig{PrivateKey: "/etc/ovn/key.pem"}}, false},
+	}
+	a := NewApp()
+	for _, tt := range tests {
+		ep := normalizeEndpoints([]EndpointConfig{tt.ep})[0]
+		if _, err := a.connectionConfig(ep, true); (err == nil) != tt.allowed {
+			t.Errorf("%s: restricted connection config returned %v, want allowed %v", tt.name, err, tt.allowed)
+		}
+		if _, err := a.connectionConfig(ep, false); err != nil {
+			t.Errorf("%s: unrestricted connection config returned %v", tt.name, err)
+		}
+	}
+}
+
+// startServer runs an in-memory Open_vSwitch database server on a unix socket for the
+// duration of the test and returns its endpoint
+func startServer(t *testing.T) string {
+	t.Helper()
+	dbModel, err := libtest.GetModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverModel, err := serverdb.FullDatabaseModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverDBModel, errs := model.NewDatabaseModel(serverdb.Schema(), serverModel)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	logger := logr.Discard()
+	db := inmemory.NewDatabase(map[string]model.ClientDBModel{
+		"Open_vSwitch": dbModel.Client(),
+		"_Server":      serverModel,
+	}, &logger)
+	srv, err := server.NewOvsdbServer(db, &logger, dbModel, serverDBModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "db.sock")
+	go func() {
+		_ = srv.Serve("unix", sock)
+	}()
+	for i := 0; !srv.Ready(); i++ {
+		if i == 100 {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(srv.Close)
+	return "unix:" + sock
+}
+
+// testApp returns an app with its settings and history under a temporary home directory,
+// whose events are dropped
+func testApp(t *testing.T) *App {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	a := NewApp()
+	a.ctx = context.Background()
+	a.emit = func(string, interface{}) {}
+	t.Cleanup(a.closeSessions)
+	return a
+}
+
+func TestConnectFollowerReadOnly(t *testing.T) {
+	a := testApp(t)
+	endpoint := startServer(t)
+	writable := false
+	tests := []struct {
+		name     string
+		req      ConnectRequest
+		readOnly bool
+		source   string
+	}{
+		{"writable", ConnectRequest{ReadOnly: &writable}, false, ReadOnlySourceConnection},
+		{"follower", ConnectRequest{Follower: endpoint}, true, ReadOnlySourceFollower},
+		{"writable follower", ConnectRequest{Follower: endpoint, ReadOnly: &writable}, true, ReadOnlySourceFollower},
+	}
+	for _, tt := range tests {
+		tt.req.Endpoints = []EndpointConfig{{Endpoint: endpoint}}
+		id, err := a.ConnectDynamic("", tt.req, "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		status := a.GetReadOnlyStatus(id)
+		if !status.Connected || status.ReadOnly != tt.readOnly || status.Source != tt.source {
+			t.Errorf("%s: read-only status %+v", tt.name, status)
+		}
+	}
+}
+
+func TestReadOnlyDefault(t *testing.T) {
+	a := testApp(t)
+	endpoint := startServer(t)
+	connect := func() string {
+		t.Helper()
+		id, err := a.ConnectDynamic("", ConnectRequest{Endpoints: []EndpointConfig{{Endpoint: endpoint}}}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	first := connect()
+	if status := a.GetReadOnlyStatus(first); !status.ReadOnly || !status.Default || status.Source != ReadOnlySourceDefault {
+		t.Errorf("status with the default = %+v", status)
+	}
+	if history := a.GetHistory(); len(history) != 1 || history[0].ReadOnly != nil {
+		t.Errorf("history of a connection with the default = %+v", history)
+	}
+
+	// A new default applies to sessions connected afterwards and is kept in the settings
+	if err := a.SetReadOnlyDefault(false); err != nil {
+		t.Fatal(err)
+	}
+	if status := a.GetReadOnlyStatus(first); !status.ReadOnly {
+		t.Errorf("existing session became writable: %+v", status)
+	}
+	if status := a.GetReadOnlyStatus(connect()); status.ReadOnly || status.Default || status.Source != ReadOnlySourceDefault {
+		t.Errorf("status with a writable default = %+v", status)
+	}
+	b := NewApp()
+	if err := b.LoadSettings(); err != nil || b.readOnlyDefault() {
+		t.Errorf("loaded read-only default = %v, %v", b.readOnlyDefault(), err)
+	}
+
+	readOnly := true
+	id, err := a.ConnectDynamic("", ConnectRequest{Endpoints: []EndpointConfig{{Endpoint: endpoint}}, ReadOnly: &readOnly}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := a.GetReadOnlyStatus(id); !status.ReadOnly || status.Source != ReadOnlySourceConnection {
+		t.Errorf("status of a read-only connection = %+v", status)
+	}
+	if history := a.GetHistory(); len(history) != 1 || history[0].ReadOnly == nil || !*history[0].ReadOnly {
+		t.Errorf("history of a read-only connection = %+v", history)
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ovsdb-viewer/internal/ovsdb"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gopkg.in/yaml.v3"
+)
+
+const profileBundleVersion = 1
+
+// ProfileBundle is the file profiles are exported to and imported from, as JSON or YAML
+// with the same field names. A profiles.json has the same layout and can be imported or
+// shared as it is.
+type ProfileBundle struct {
+	Version  int       `json:"version"`
+	Exported int64     `json:"exported,omitempty"`
+	Profiles []Profile `json:"profiles"`
+}
+
+// What ImportProfiles does with a profile that has the id, or the folder and name, of one
+// the user already has
+const (
+	ImportSkip    = "skip"    // keep the existing profile
+	ImportReplace = "replace" // overwrite it, keeping its id and favourite mark
+	ImportRename  = "rename"  // keep both, the imported one under a new name
+)
+
+// ProfileImport lists the profiles of a bundle, as folder/name, by what became of them
+type ProfileImport struct {
+	Added    []string `json:"added"`
+	Replaced []string `json:"replaced"`
+	Renamed  []string `json:"renamed"`
+	Skipped  []string `json:"skipped"`
+	// Unconfirmed lists the added, replaced or renamed profiles whose relay commands must be
+	// confirmed with TrustProfile before they connect
+	Unconfirmed []string `json:"unconfirmed"`
+}
+
+// ExportProfiles saves the profiles with the given ids, or all of them when there are none,
+// to a bundle chosen with the save dialog, in json or yaml format. It returns the file's
+// path, or "" if the dialog was cancelled.
+func (a *App) ExportProfiles(ids []string, format string) (string, error) {
+	if format == "" {
+		format = ovsdb.ExportJSON
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export profiles",
+		DefaultFilename: fmt.Sprintf("ovsdb-viewer-profiles-%s.%s", time.Now().Format("20060102"), format),
+		Filters:         []runtime.FileFilter{{DisplayName: strings.ToUpper(format) + " files", Pattern: "*." + format}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := a.writeProfileBundle(&buf, ids, format); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// ImportProfiles adds the profiles of a bundle to the user's own, resolving conflicts as
+// onConflict says: skip, replace or rename. With an empty path the bundle is chosen with the
+// open dialog; it returns nil if the dialog was cancelled.
+func (a *App) ImportProfiles(path string, onConflict string) (*ProfileImport, error) {
+	if path == "" {
+		var err error
+		path, err = runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+			Title:   "Import profiles",
+			Filters: []runtime.FileFilter{{DisplayName: "Profile bundles", Pattern: "*.json;*.yaml;*.yml"}},
+		})
+		if err != nil || path == "" {
+			return nil, err
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return a.importProfileBundle(data, onConflict)
+}
+
+// GetSharedProfileDirs returns the shared profile directories of the settings
+func (a *App) GetSharedProfileDirs() []string {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return append([]string{}, a.settings.SharedProfileDirs...)
+}
+
+// SetSharedProfileDirs changes the shared profile directories and reads their profiles.
+// Every .json, .yaml or .yml file in them is a bundle; the profiles are listed with the
+// user's own but cannot be changed.
+func (a *App) SetSharedProfileDirs(dirs []string) error {
+	cleaned := []string{}
+	for _, dir := range dirs {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			cleaned = append(cleaned, filepath.Clean(dir))
+		}
+	}
+	a.settingsMu.Lock()
+	a.settings.SharedProfileDirs = cleaned
+	a.settingsMu.Unlock()
+	if err := a.SaveSettings(); err != nil {
+		return err
+	}
+	shared, err := loadSharedProfiles(a.sharedProfileDirs())
+	a.profilesMu.Lock()
+	a.sharedProfiles = shared
+	a.profilesMu.Unlock()
+	return err
+}
+
+// sharedProfileDirs returns the directories of the settings followed by those of
+// $OVSDB_VIEWER_PROFILE_DIRS
+func (a *App) sharedProfileDirs() []string {
+	dirs := a.GetSharedProfileDirs()
+	for _, dir := range filepath.SplitList(os.Getenv("OVSDB_VIEWER_PROFILE_DIRS")) {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// writeProfileBundle writes the profiles with the given ids, or all of them, as a bundle
+func (a *App) writeProfileBundle(w io.Writer, ids []string, format string) error {
+	profiles := a.GetProfiles()
+	if len(ids) > 0 {
+		byID := make(map[string]Profile, len(profiles))
+		for _, p := range profiles {
+			byID[p.ID] = p
+		}
+		profiles = profiles[:0:0]
+		for _, id := range ids {
+			p, ok := byID[id]
+			if !ok {
+				return fmt.Errorf("no profile with id %s", id)
+			}
+			profiles = append(profiles, p)
+		}
+	}
+	bundle := ProfileBundle{Version: profileBundleVersion, Exported: time.Now().Unix(), Profiles: make([]Profile, 0, len(profiles))}
+	for _, p := range profiles {
+		bundle.Profiles = append(bundle.Profiles, exportableProfile(p))
+	}
+
+	switch format {
+	case ovsdb.ExportJSON, "":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(bundle)
+	case ovsdb.ExportYAML:
+		// Going through JSON gives the YAML the same field names
+		data, err := json.Marshal(bundle)
+		if err != nil {
+			return err
+		}
+		var document interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(yamlNumbers(document)); err != nil {
+			return fmt.Errorf("failed to write YAML: %w", err)
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("unknown bundle format %q; use json or yaml", format)
+}
+
+// yamlNumbers turns the json.Number values of a decoded document into integers or floats,
+// which YAML writes unquoted
+func yamlNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = yamlNumbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = yamlNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// exportableProfile leaves out of a profile what is only the user's: the favourite mark, the
+// last use, and the environment of relay commands, which may hold credentials. Profiles
+// never hold passwords or passphrases; key and certificate paths are kept.
+func exportableProfile(p Profile) Profile {
+	p.Endpoints = cloneEndpoints(p.Endpoints)
+	for i := range p.Endpoints {
+		if p.Endpoints[i].Command != nil {
+			p.Endpoints[i].Command.Env = nil
+		}
+	}
+	p.Tags = append([]string{}, p.Tags...)
+	p.Favorite = false
+	p.LastUsed = 0
+	p.Shared = ""
+	return p
+}
+
+// importProfileBundle adds the profiles of a bundle to the user's own and saves them
+func (a *App) importProfileBundle(data []byte, onConflict string) (*ProfileImport, error) {
+	switch onConflict {
+	case "":
+		onConflict = ImportSkip
+	case ImportSkip, ImportReplace, ImportRename:
+	default:
+		return nil, fmt.Errorf("unknown conflict resolution %q; use skip, replace or rename", onConflict)
+	}
+	imported, err := decodeProfileBundle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := a.trustedCommands()
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	result := &ProfileImport{Added: []string{}, Replaced: []string{}, Renamed: []string{}, Skipped: []string{}, Unconfirmed: []string{}}
+	now := time.Now().Unix()
+	for _, p := range imported {
+		p.Favorite = false
+		p.Shared = ""
+		p.Updated = now
+		if p.Created == 0 {
+			p.Created = now
+		}
+		existing := -1
+		if p.ID != "" {
+			existing = a.ownProfile(p.ID)
+		}
+		if existing < 0 {
+			existing = a.namedProfile(p.Folder, p.Name, -1)
+		}
+
+		switch {
+		case existing < 0:
+			if p.ID == "" {
+				p.ID = randomToken()
+			}
+			a.profiles = append(a.profiles, p)
+			result.Added = append(result.Added, profilePath(p))
+		case onConflict == ImportSkip:
+			result.Skipped = append(result.Skipped, profilePath(p))
+		case onConflict == ImportReplace:
+			old := a.profiles[existing]
+			p.ID = old.ID
+			p.Created = old.Created
+			p.Favorite = old.Favorite
+			p.LastUsed = old.LastUsed
+			p.Name = a.uniqueProfileName(p.Folder, p.Name, existing)
+			a.profiles[existing] = p
+			result.Replaced = append(result.Replaced, profilePath(p))
+		default:
+			p.ID = randomToken()
+			p.Name = a.uniqueProfileName(p.Folder, p.Name, -1)
+			a.profiles = append(a.profiles, p)
+			result.Renamed = append(result.Renamed, profilePath(p))
+		}
+		if existing < 0 || onConflict != ImportSkip {
+			if len(unconfirmedCommands(p, trusted)) > 0 {
+				result.Unconfirmed = append(result.Unconfirmed, profilePath(p))
+			}
+		}
+	}
+	if err := a.writeProfiles(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// namedProfile returns the index of the user's own profile with the given folder and
+// name, other than except, or -1; profilesMu must be held
+func (a *App) namedProfile(folder string, name string, except int) int {
+	for i, p := range a.profiles {
+		if i != except && p.Folder == folder && strings.EqualFold(p.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// uniqueProfileName numbers a name no other profile of the folder has; profilesMu must be held
+func (a *App) uniqueProfileName(folder string, name string, except int) string {
+	unique := name
+	for n := 2; a.namedProfile(folder, unique, except) >= 0; n++ {
+		unique = fmt.Sprintf("%s (%d)", name, n)
+	}
+	return unique
+}
+
+// decodeProfileBundle reads the profiles of a JSON or YAML bundle. A connection history
+// file is read as well, its records upgraded as LoadHistory does and turned into profiles
+// without ids.
+func decodeProfileBundle(data []byte) ([]Profile, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse profile bundle: %w", err)
+	}
+	// YAML is read as the JSON it stands for, so both use the JSON field names
+	normalized, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile bundle: %w", err)
+	}
+
+	var profiles []Profile
+	if _, ok := document.([]interface{}); ok {
+		var history []ConnectionHistory
+		if err := json.Unmarshal(normalized, &history); err != nil {
+			return nil, fmt.Errorf("failed to read connection history: %w", err)
+		}
+		for i := range history {
+			history[i], _ = upgradeHistoryRecord(history[i])
+		}
+		profiles = profilesFromHistory(history)
+		for i := range profiles {
+			profiles[i].ID = ""
+		}
+	} else {
+		var bundle ProfileBundle
+		if err := json.Unmarshal(normalized, &bundle); err != nil {
+			return nil, fmt.Errorf("failed to read profile bundle: %w", err)
+		}
+		if bundle.Version < 1 {
+			return nil, fmt.Errorf("not a profile bundle: no version")
+		}
+		if bundle.Version > profileBundleVersion {
+			return nil, fmt.Errorf("profile bundle is version %d; this version reads up to %d", bundle.Version, profileBundleVersion)
+		}
+		profiles = bundle.Profiles
+	}
+
+	for i := range profiles {
+		p, err := normalizeProfile(profiles[i])
+		if err != nil {
+			return nil, fmt.Errorf("profile %d of the bundle: %w", i+1, err)
+		}
+		profiles[i] = p
+	}
+	return profiles, nil
+}
+
+// loadSharedProfiles reads the bundles of the shared profile directories. A profile without
+// an id gets one made from its file, folder and name, so that it stays the same across
+// loads; of profiles with the same id, the first one read is kept. Files that cannot be read
+// are reported after the others are loaded.
+func loadSharedProfiles(dirs []string) ([]Profile, error) {
+	profiles := []Profile{}
+	seen := make(map[string]bool)
+	var errs []error
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read shared profiles: %w", err))
+			continue
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".yaml", ".yml":
+			default:
+				continue
+			}
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			data, err := os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read shared profiles: %w", err))
+				continue
+			}
+			bundle, err := decodeProfileBundle(data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			for _, p := range bundle {
+				if p.ID == "" {
+					sum := sha1.Sum([]byte(path + "\n" + profilePath(p)))
+					p.ID = "shared-" + hex.EncodeToString(sum[:8])
+				}
+				if seen[p.ID] {
+					continue
+				}
+				seen[p.ID] = true
+				p.Favorite = false
+				p.Shared = path
+				profiles = append(profiles, p)
+			}
+		}
+	}
+	return profiles, errors.Join(errs...)
+}
+
+// TrustProfile confirms that the relay commands of a profile, such as one imported from a
+// bundle or read from a shared directory, may run on this machine. A profile whose commands
+// change later needs confirming again.
+func (a *App) TrustProfile(id string) error {
+	profile, err := a.profile(id)
+	if err != nil {
+		return err
+	}
+	return a.trustCommands(*profile)
+}
+
+// trustCommands adds the fingerprints of a profile's relay commands to the settings
+func (a *App) trustCommands(p Profile) error {
+	a.settingsMu.Lock()
+	trusted := make(map[string]bool, len(a.settings.TrustedCommands))
+	for _, fingerprint := range a.settings.TrustedCommands {
+		trusted[fingerprint] = true
+	}
+	added := false
+	for _, ep := range p.Endpoints {
+		if ep.Command == nil {
+			continue
+		}
+		if fingerprint := commandFingerprint(ep); !trusted[fingerprint] {
+			trusted[fingerprint] = true
+			a.settings.TrustedCommands = append(a.settings.TrustedCommands, fingerprint)
+			added = true
+		}
+	}
+	a.settingsMu.Unlock()
+	if !added {
+		return nil
+	}
+	return a.SaveSettings()
+}
+
+// trustedCommands returns the fingerprints of the confirmed relay commands
+func (a *App) trustedCommands() map[string]bool {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	trusted := make(map[string]bool, len(a.settings.TrustedCommands))
+	for _, fingerprint := range a.settings.TrustedCommands {
+		trusted[fingerprint] = true
+	}
+	return trusted
+}
+
+// unconfirmedCommands returns the command lines of a profile's relay commands whose
+// fingerprints are not trusted
+func unconfirmedCommands(p Profile, trusted map[string]bool) []string {
+	var commands []string
+	for _, ep := range p.Endpoints {
+		if ep.Command != nil && !trusted[commandFingerprint(ep)] {
+			commands = append(commands, strings.Join(append([]string{ep.Command.Command}, ep.Command.Args...), " "))
+		}
+	}
+	return commands
+}
+
+// commandFingerprint identifies what a command endpoint runs: the command with its
+// arguments, environment and directory, and the endpoint it is given
+func commandFingerprint(ep EndpointConfig) string {
+	data, _ := json.Marshal(struct {
+		Endpoint string         `json:"endpoint"`
+		Command  *CommandConfig `json:"command"`
+	}{ep.Endpoint, ep.Command})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// profilePath names a profile with its folder, as folder/name
+func profilePath(p Profile) string {
+	if p.Folder == "" {
+		return p.Name
+	}
+	return p.Folder + "/" + p.Name
+}
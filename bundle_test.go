@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProfileBundles(t *testing.T) {
+	a := testApp(t)
+	east, err := a.SaveProfile(Profile{Name: "east", Folder: "ovn", Tags: []string{"prod"}, Endpoints: []EndpointConfig{{
+		Endpoint: "unix:/run/ovn/ovnnb_db.sock",
+		Command:  &CommandConfig{Command: "kubectl", Args: []string{"exec", "-i", "db"}, Env: []string{"KUBECONFIG=/secret"}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SaveProfile(Profile{Name: "west", Folder: "ovn", Endpoints: []EndpointConfig{{Endpoint: "tcp:10.0.0.2:6641"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetProfileFavorite(east.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bundles leave out the favourite mark and the environment of commands
+	var buf bytes.Buffer
+	if err := a.writeProfileBundle(&buf, []string{east.ID}, "json"); err != nil {
+		t.Fatal(err)
+	}
+	jsonBundle := append([]byte{}, buf.Bytes()...)
+	var bundle ProfileBundle
+	if err := json.Unmarshal(jsonBundle, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Version != profileBundleVersion || len(bundle.Profiles) != 1 || bundle.Profiles[0].Favorite || bundle.Profiles[0].ID != east.ID ||
+		bundle.Profiles[0].Endpoints[0].Command.Env != nil || !reflect.DeepEqual(bundle.Profiles[0].Endpoints[0].Command.Args, []string{"exec", "-i", "db"}) {
+		t.Errorf("JSON bundle = %s", jsonBundle)
+	}
+	buf.Reset()
+	if err := a.writeProfileBundle(&buf, nil, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &document); err != nil || document["version"] != profileBundleVersion || len(document["profiles"].([]interface{})) != 2 {
+		t.Errorf("YAML bundle = %s, %v", buf.Bytes(), err)
+	}
+	yamlBundle := append([]byte{}, buf.Bytes()...)
+	if err := a.writeProfileBundle(&buf, nil, "xml"); err == nil {
+		t.Error("exported an xml bundle")
+	}
+	if err := a.writeProfileBundle(&buf, []string{"unknown"}, "json"); err == nil {
+		t.Error("exported an unknown profile")
+	}
+
+	// Profiles with the id, or the folder and name, of one the user has are resolved as asked
+	tests := []struct {
+		onConflict string
+		data       []byte
+		want       ProfileImport
+		profiles   int
+	}{
+		{ImportSkip, yamlBundle, ProfileImport{Skipped: []string{"ovn/east", "ovn/west"}}, 2},
+		{ImportRename, jsonBundle, ProfileImport{Renamed: []string{"ovn/east (2)"}, Unconfirmed: []string{"ovn/east (2)"}}, 3},
+		{ImportReplace, yamlBundle, ProfileImport{Replaced: []string{"ovn/east", "ovn/west"}, Unconfirmed: []string{"ovn/east"}}, 3},
+	}
+	for _, tt := range tests {
+		data := tt.data
+		if tt.onConflict == ImportReplace {
+			// Replaced profiles keep their favourite mark, and their commands are new
+			data = []byte(strings.ReplaceAll(string(data), `"db"`, `"db-1"`))
+			data = []byte(strings.ReplaceAll(string(data), "- db\n", "- db-1\n"))
+		}
+		got, err := a.importProfileBundle(data, tt.onConflict)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.onConflict, err)
+		}
+		for _, list := range []*[]string{&tt.want.Added, &tt.want.Replaced, &tt.want.Renamed, &tt.want.Skipped, &tt.want.Unconfirmed} {
+			if *list == nil {
+				*list = []string{}
+			}
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: import = %+v, want %+v", tt.onConflict, *got, tt.want)
+		}
+		if profiles := a.GetProfiles(); len(profiles) != tt.profiles {
+			t.Errorf("%s: %d profiles after the import", tt.onConflict, len(profiles))
+		}
+	}
+	if p, err := a.profile(east.ID); err != nil || !p.Favorite || p.Endpoints[0].Command.Args[2] != "db-1" {
+		t.Errorf("replaced profile = %+v, %v", p, err)
+	}
+
+	// A connection history file is imported as profiles, upgrading old records
+	history := []byte(`[{"host": "bastion", "user": "admin", "endpoint": "tcp:127.0.0.1:6641", "timestamp": 1700000000}]`)
+	if got, err := a.importProfileBundle(history, ImportSkip); err != nil || !reflect.DeepEqual(got.Added, []string{"tcp:127.0.0.1:6641 via bastion"}) {
+		t.Errorf("history import = %+v, %v", got, err)
+	}
+
+	for _, tt := range []struct {
+		data       string
+		onConflict string
+	}{
+		{`{"version": 1, "profiles": []}`, "merge"},
+		{`{"version": 2, "profiles": []}`, ImportSkip},
+		{`{"profiles": []}`, ImportSkip},
+		{`{"version": 1, "profiles": [{"name": "empty"}]}`, ImportSkip},
+		{`{"version": 1, "profiles": [`, ImportSkip},
+	} {
+		if _, err := a.importProfileBundle([]byte(tt.data), tt.onConflict); err == nil {
+			t.Errorf("imported %s with %s", tt.data, tt.onConflict)
+		}
+	}
+}
+
+func TestSharedProfiles(t *testing.T) {
+	a := testApp(t)
+	shared := t.TempDir()
+	files := map[string]string{
+		"team.yaml":   "version: 1\nprofiles:\n  - name: lab\n    folder: team\n    endpoints:\n      - endpoint: tcp:10.0.0.3:6641\n",
+		"broken.json": `{"version": 1, "profiles": [`,
+		"notes.txt":   "not a bundle",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(shared, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.SetSharedProfileDirs([]string{" " + shared + "/ "}); err == nil || !strings.Contains(err.Error(), "broken.json") {
+		t.Errorf("loading a broken bundle returned %v", err)
+	}
+	if dirs := a.GetSharedProfileDirs(); !reflect.DeepEqual(dirs, []string{shared}) {
+		t.Errorf("shared directories = %v", dirs)
+	}
+	profiles := a.GetProfiles()
+	if len(profiles) != 1 || profiles[0].Name != "lab" || profiles[0].Shared != filepath.Join(shared, "team.yaml") || !strings.HasPrefix(profiles[0].ID, "shared-") {
+		t.Fatalf("profiles = %+v", profiles)
+	}
+	lab := profiles[0]
+
+	// Shared profiles keep their id across loads and cannot be changed, only overridden
+	if err := a.LoadProfiles(); err == nil || a.GetProfiles()[0].ID != lab.ID {
+		t.Errorf("reloaded shared profiles = %+v, %v", a.GetProfiles(), err)
+	}
+	if err := a.SetProfileFavorite(lab.ID, true); err == nil || !strings.Contains(err.Error(), "shared") {
+		t.Errorf("changing a shared profile returned %v", err)
+	}
+	if err := a.DeleteProfile(lab.ID); err == nil {
+		t.Error("deleted a shared profile")
+	}
+	lab.Description = "Lab cluster"
+	if _, err := a.SaveProfile(lab); err != nil {
+		t.Fatal(err)
+	}
+	if profiles := a.GetProfiles(); len(profiles) != 1 || profiles[0].Shared != "" || profiles[0].Description != "Lab cluster" || profiles[0].ID != lab.ID {
+		t.Errorf("profiles after overriding the shared one = %+v", profiles)
+	}
+}
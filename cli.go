@@ -0,0 +1,835 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"ovsdb-viewer/internal/ovsdb"
+
+	"golang.org/x/term"
+)
+
+// Output formats of the CLI
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// cliCommand is a subcommand that runs without the window
+type cliCommand struct {
+	args     string // synopsis of the arguments after the flags
+	summary  string
+	connects bool // takes the connection flags
+	run      func(c *cli, args []string) error
+}
+
+var cliCommands map[string]cliCommand
+
+func init() {
+	cliCommands = map[string]cliCommand{
+		"export-profiles": {
+			args:    "[profile...]",
+			summary: "Print a bundle of the given profiles, or of all of them, without favourites or relay command environments, for import-profiles.",
+			run:     (*cli).exportProfiles,
+		},
+		"history": {
+			summary: "List the recent connections, most recent first; -recent takes their number.",
+			run:     (*cli).history,
+		},
+		"import-profiles": {
+			args:    "file",
+			summary: "Add the profiles of a bundle, or of a connection history file, to the saved profiles.",
+			run:     (*cli).importProfiles,
+		},
+		"list-dbs": {
+			summary:  "List the databases of the server.",
+			connects: true,
+			run:      (*cli).listDatabases,
+		},
+		"schema": {
+			args:     "[table]",
+			summary:  "Show the tables of the database, or the columns of one table.",
+			connects: true,
+			run:      (*cli).schema,
+		},
+		"dump": {
+			args:     "[table...]",
+			summary:  "Print every row of the database, or of the given tables, read in one transaction.",
+			connects: true,
+			run:      (*cli).dump,
+		},
+		"profiles": {
+			summary: "List the saved connection profiles by folder; -profile takes their name, folder/name or id.",
+			run:     (*cli).profiles,
+		},
+		"trust-profile": {
+			args:    "profile...",
+			summary: "Confirm that the relay commands of imported or shared profiles may run on this machine; until then they do not connect.",
+			run:     (*cli).trustProfiles,
+		},
+		"query": {
+			args:     "table [condition...]",
+			summary:  "Print the rows of a table matching every condition, such as name==br-int, ofport>=1 or 'external_ids includes {\"k\":\"v\"}'. Values are JSON, or strings when they do not parse.",
+			connects: true,
+			run:      (*cli).query,
+		},
+		"serve": {
+			summary: "Serve the UI and a JSON API to browsers, each session with its own connection, until interrupted.",
+			run:     (*cli).serve,
+		},
+		"watch": {
+			args:     "table",
+			summary:  "Print the rows of a table, then every change to it until interrupted.",
+			connects: true,
+			run:      (*cli).watch,
+		},
+	}
+}
+
+// isCLICommand reports whether the first argument of the binary selects the CLI
+func isCLICommand(name string) bool {
+	_, ok := cliCommands[name]
+	return ok || name == "help" || name == "-h" || name == "--help"
+}
+
+// cli runs one subcommand against the same App the window binds, with its saved history
+// and settings. Events meant for the frontend are handled on the terminal instead.
+type cli struct {
+	app    *App
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+	output string
+	// session is the App session connect opened
+	session string
+
+	// Connection flags
+	profile  string
+	recent   int
+	endpoint string
+	ssh      string
+	jump     string
+	key      string
+	tlsKey   string
+	tlsCert  string
+	tlsCA    string
+	file     string
+	follower string
+	db       string
+
+	// dump and query flags
+	format  string
+	columns string
+	sort    string
+
+	// import-profiles flags
+	conflict string
+
+	// serve flags
+	listen    string
+	token     string
+	idle      time.Duration
+	httpsCert string
+	httpsKey  string
+
+	printMu  sync.Mutex
+	watching bool
+}
+
+// runCLI runs the subcommand named by args[0] and returns the exit status
+func runCLI(args []string) int {
+	if _, ok := cliCommands[args[0]]; !ok {
+		printCLIUsage(os.Stdout)
+		return 0
+	}
+	c := &cli{
+		app:    NewApp(),
+		stdin:  bufio.NewReader(os.Stdin),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	return c.run(args)
+}
+
+// run parses the flags of the subcommand named by args[0], runs it and returns the exit
+// status
+func (c *cli) run(args []string) int {
+	name := args[0]
+	cmd := cliCommands[name]
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: ovsdb-viewer %s [flags] %s\n\n%s\n\nflags:\n", name, cmd.args, cmd.summary)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&c.output, "o", outputTable, "output format: table or json")
+	if cmd.connects {
+		flags.StringVar(&c.profile, "profile", "", "connect with a saved profile, by its name, folder/name or id in \"profiles\"")
+		flags.IntVar(&c.recent, "recent", 0, "connect like a recent connection, by its number in \"history\"")
+		flags.StringVar(&c.endpoint, "endpoint", "", "connect to these comma-separated endpoints, such as tcp:10.0.0.1:6641")
+		flags.StringVar(&c.ssh, "ssh", "", "reach -endpoint through an SSH tunnel to user@host[:port]")
+		flags.StringVar(&c.jump, "jump", "", "comma-separated SSH jump hosts on the way to -ssh")
+		flags.StringVar(&c.key, "key", "", "SSH private key file for -ssh")
+		flags.StringVar(&c.tlsKey, "tls-key", "", "private key for ssl: endpoints")
+		flags.StringVar(&c.tlsCert, "tls-cert", "", "certificate for ssl: endpoints")
+		flags.StringVar(&c.tlsCA, "tls-ca", "", "CA certificate for ssl: endpoints")
+		flags.StringVar(&c.file, "file", "", "read a database file offline instead of connecting")
+		flags.StringVar(&c.follower, "follower", "", "browse this cluster member even if it is not the leader")
+		flags.StringVar(&c.db, "db", "", "database to use (default Open_vSwitch, or the database of -file)")
+	}
+	if name == "dump" || name == "query" {
+		flags.StringVar(&c.format, "format", "", "dump, json, yaml or csv (default: dump, or json with -o json)")
+	}
+	if name == "export-profiles" {
+		flags.StringVar(&c.format, "format", ovsdb.ExportJSON, "json or yaml")
+	}
+	if name == "import-profiles" {
+		flags.StringVar(&c.conflict, "conflict", ImportSkip, "what to do with a profile whose id or folder/name exists: skip, replace or rename")
+	}
+	if name == "query" {
+		flags.StringVar(&c.columns, "columns", "", "comma-separated columns to print")
+		flags.StringVar(&c.sort, "sort", "", "comma-separated columns to sort by; a leading - sorts descending")
+	}
+	if name == "serve" {
+		flags.StringVar(&c.listen, "listen", "127.0.0.1:8080", "address to serve on")
+		flags.StringVar(&c.token, "token", os.Getenv("OVSDB_VIEWER_TOKEN"), "token browsers must present (default $OVSDB_VIEWER_TOKEN, or a random one that is printed)")
+		flags.DurationVar(&c.idle, "idle", 30*time.Minute, "disconnect browser sessions left closed for this long")
+		flags.StringVar(&c.httpsCert, "https-cert", "", "serve HTTPS with this certificate")
+		flags.StringVar(&c.httpsKey, "https-key", "", "private key of -https-cert")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if c.output != outputTable && c.output != outputJSON {
+		fmt.Fprintf(c.stderr, "ovsdb-viewer %s: unknown output format %q\n", name, c.output)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	c.app.startup(ctx)
+	c.app.emit = c.handleEvent
+	err := cmd.run(c, flags.Args())
+	c.app.closeSessions()
+	if err != nil {
+		fmt.Fprintf(c.stderr, "ovsdb-viewer %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// printCLIUsage lists the subcommands
+func printCLIUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: ovsdb-viewer [command [flags] [arguments]]")
+	fmt.Fprintln(w, "\nWithout a command the window opens. Commands:")
+	names := make([]string, 0, len(cliCommands))
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		cmd := cliCommands[name]
+		fmt.Fprintf(tw, "  %s %s\t%s\n", name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun \"ovsdb-viewer <command> -h\" for the flags of a command.")
+}
+
+// connect opens the connection the flags select: a saved profile, endpoints or a file
+func (c *cli) connect() error {
+	sources := 0
+	for _, set := range []bool{c.profile != "", c.recent != 0, c.endpoint != "", c.file != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of -profile, -recent, -endpoint or -file is required")
+	}
+	if c.file != "" {
+		file, err := c.app.OpenDatabaseFile("", c.file)
+		if err != nil {
+			return err
+		}
+		c.session = file.SessionID
+		if c.db == "" {
+			c.db = file.Database
+		}
+		return nil
+	}
+	if c.profile != "" {
+		profile, err := c.findProfile(c.profile)
+		if err != nil {
+			return err
+		}
+		if c.db == "" {
+			c.db = profile.Database
+		}
+		if c.db == "" {
+			c.db = "Open_vSwitch"
+		}
+		if len(profile.UnconfirmedCommands) > 0 {
+			return fmt.Errorf("profile %q runs %s on this machine; confirm it first with \"ovsdb-viewer trust-profile %s\"",
+				profile.Name, strings.Join(profile.UnconfirmedCommands, "; "), c.profile)
+		}
+		c.session, err = c.app.ConnectProfile("", profile.ID, c.db)
+		return err
+	}
+	if c.db == "" {
+		c.db = "Open_vSwitch"
+	}
+
+	var req ConnectRequest
+	if c.recent != 0 {
+		history := c.app.GetHistory()
+		if c.recent < 1 || c.recent > len(history) {
+			return fmt.Errorf("no recent connection %d; \"ovsdb-viewer history\" lists them", c.recent)
+		}
+		recent := history[c.recent-1]
+		req = ConnectRequest{Endpoints: cloneEndpoints(recent.Endpoints), ReadOnly: recent.ReadOnly}
+	} else {
+		endpoints, err := c.endpoints()
+		if err != nil {
+			return err
+		}
+		req.Endpoints = endpoints
+	}
+	req.Follower = c.follower
+	session, err := c.app.ConnectDynamic("", req, c.db)
+	c.session = session
+	return err
+}
+
+// endpoints builds the endpoints given with -endpoint and the SSH and TLS flags
+func (c *cli) endpoints() ([]EndpointConfig, error) {
+	var tunnel *TunnelConfig
+	if c.ssh != "" {
+		tunnel = &TunnelConfig{KeyFile: c.key, JumpHosts: splitList(c.jump)}
+		target := c.ssh
+		if user, host, ok := strings.Cut(target, "@"); ok {
+			tunnel.User, target = user, host
+		}
+		tunnel.Host = target
+		if host, port, err := net.SplitHostPort(target); err == nil {
+			n, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SSH port in %s", c.ssh)
+			}
+			tunnel.Host, tunnel.Port = host, n
+		}
+	}
+	var endpoints []EndpointConfig
+	for _, endpoint := range splitList(c.endpoint) {
+		ep := EndpointConfig{Endpoint: endpoint, Mode: EndpointModeDirect}
+		if tunnel != nil {
+			t := *tunnel
+			ep.Mode, ep.Tunnel = EndpointModeSSH, &t
+		}
+		if c.tlsKey != "" || c.tlsCert != "" || c.tlsCA != "" {
+			ep.TLS = &TLSConfig{PrivateKey: c.tlsKey, Certificate: c.tlsCert, CACert: c.tlsCA}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// cliRecent is a recent connection as "history -o json" prints it
+type cliRecent struct {
+	Recent int `json:"recent"`
+	ConnectionHistory
+}
+
+func (c *cli) history(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	history := c.app.GetHistory()
+	if c.output == outputJSON {
+		recent := make([]cliRecent, 0, len(history))
+		for i, h := range history {
+			recent = append(recent, cliRecent{Recent: i + 1, ConnectionHistory: h})
+		}
+		return c.printJSON(recent)
+	}
+	rows := make([][]string, 0, len(history))
+	for i, h := range history {
+		endpoints := make([]string, 0, len(h.Endpoints))
+		for _, ep := range h.Endpoints {
+			endpoints = append(endpoints, describeEndpoint(ep))
+		}
+		readOnly := "default"
+		if h.ReadOnly != nil {
+			readOnly = strconv.FormatBool(*h.ReadOnly)
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			strings.Join(endpoints, ", "),
+			readOnly,
+			time.Unix(h.Timestamp, 0).Format("2006-01-02 15:04"),
+		})
+	}
+	return c.printTable([]string{"RECENT", "ENDPOINTS", "READ-ONLY", "LAST USED"}, rows)
+}
+
+func (c *cli) profiles(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if err := c.app.LoadProfiles(); err != nil {
+		fmt.Fprintf(c.stderr, "warning: %v\n", err)
+	}
+	profiles := c.app.GetProfiles()
+	if c.output == outputJSON {
+		return c.printJSON(profiles)
+	}
+	rows := make([][]string, 0, len(profiles))
+	for _, p := range profiles {
+		endpoints := make([]string, 0, len(p.Endpoints))
+		for _, ep := range p.Endpoints {
+			endpoints = append(endpoints, describeEndpoint(ep))
+		}
+		name := p.Name
+		if p.Favorite {
+			name = "* " + name
+		}
+		if len(p.UnconfirmedCommands) > 0 {
+			name += " (unconfirmed)"
+		}
+		readOnly := "default"
+		if p.ReadOnly != nil {
+			readOnly = strconv.FormatBool(*p.ReadOnly)
+		}
+		rows = append(rows, []string{
+			p.Folder,
+			name,
+			strings.Join(p.Tags, ","),
+			p.Database,
+			strings.Join(endpoints, ", "),
+			readOnly,
+		})
+	}
+	return c.printTable([]string{"FOLDER", "NAME", "TAGS", "DATABASE", "ENDPOINTS", "READ-ONLY"}, rows)
+}
+
+func (c *cli) exportProfiles(args []string) error {
+	ids := make([]string, 0, len(args))
+	for _, ref := range args {
+		profile, err := c.findProfile(ref)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, profile.ID)
+	}
+	w := bufio.NewWriter(c.stdout)
+	if err := c.app.writeProfileBundle(w, ids, c.format); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (c *cli) importProfiles(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one file is required")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	result, err := c.app.importProfileBundle(data, c.conflict)
+	if err != nil {
+		return err
+	}
+	if c.output == outputJSON {
+		return c.printJSON(result)
+	}
+	var rows [][]string
+	for _, outcome := range []struct {
+		name  string
+		paths []string
+	}{{"added", result.Added}, {"replaced", result.Replaced}, {"renamed", result.Renamed}, {"skipped", result.Skipped}} {
+		for _, path := range outcome.paths {
+			rows = append(rows, []string{path, outcome.name})
+		}
+	}
+	if err := c.printTable([]string{"PROFILE", "IMPORT"}, rows); err != nil {
+		return err
+	}
+	for _, path := range result.Unconfirmed {
+		fmt.Fprintf(c.stderr, "warning: %s runs relay commands on this machine; confirm it with \"ovsdb-viewer trust-profile %s\"\n", path, path)
+	}
+	return nil
+}
+
+func (c *cli) trustProfiles(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("at least one profile is required")
+	}
+	for _, ref := range args {
+		profile, err := c.findProfile(ref)
+		if err != nil {
+			return err
+		}
+		if len(profile.UnconfirmedCommands) == 0 {
+			continue
+		}
+		if err := c.app.TrustProfile(profile.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%s: confirmed %s\n", profilePath(*profile), strings.Join(profile.UnconfirmedCommands, "; "))
+	}
+	return nil
+}
+
+// findProfile looks a profile up by id, folder/name or, when unique, by name alone
+func (c *cli) findProfile(ref string) (*Profile, error) {
+	var matches []Profile
+	for _, p := range c.app.GetProfiles() {
+		switch {
+		case p.ID == ref:
+			return &p, nil
+		case strings.EqualFold(p.Name, ref), p.Folder != "" && strings.EqualFold(p.Folder+"/"+p.Name, ref):
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no profile %q; \"ovsdb-viewer profiles\" lists them", ref)
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, fmt.Errorf("profile %q is in several folders; give it as folder/name", ref)
+}
+
+// describeEndpoint tells how an endpoint is reached, in one line
+func describeEndpoint(ep EndpointConfig) string {
+	switch {
+	case ep.Mode == EndpointModeSSH && ep.Tunnel != nil:
+		via := ep.Tunnel.Host
+		if ep.Tunnel.User != "" {
+			via = ep.Tunnel.User + "@" + via
+		}
+		hops := append(append([]string{}, ep.Tunnel.JumpHosts...), via)
+		return fmt.Sprintf("%s via %s", ep.Endpoint, strings.Join(hops, " -> "))
+	case ep.Mode == EndpointModeCommand && ep.Command != nil:
+		return fmt.Sprintf("%s via %s", ep.Endpoint, ep.Command.Command)
+	}
+	return ep.Endpoint
+}
+
+func (c *cli) listDatabases(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	dbs, err := c.app.ListDatabases(c.session)
+	if err != nil {
+		return err
+	}
+	if c.output == outputJSON {
+		return c.printJSON(dbs)
+	}
+	rows := make([][]string, 0, len(dbs))
+	for _, db := range dbs {
+		rows = append(rows, []string{db})
+	}
+	return c.printTable([]string{"DATABASE"}, rows)
+}
+
+func (c *cli) schema(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("expected at most one table")
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	schema, err := c.app.GetSchemaDynamic(c.session, c.db)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if c.output == outputJSON {
+			return c.printJSON(schema)
+		}
+		names := make([]string, 0, len(schema.Tables))
+		for name := range schema.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rows := make([][]string, 0, len(names))
+		for _, name := range names {
+			table := schema.Tables[name]
+			rows = append(rows, []string{name, strconv.Itoa(len(table.Columns)), strconv.FormatBool(table.IsRoot)})
+		}
+		fmt.Fprintf(c.stdout, "%s %s\n\n", schema.Name, schema.Version)
+		return c.printTable([]string{"TABLE", "COLUMNS", "ROOT"}, rows)
+	}
+
+	table, ok := schema.Tables[args[0]]
+	if !ok {
+		return fmt.Errorf("table %s not found in %s", args[0], schema.Name)
+	}
+	if c.output == outputJSON {
+		return c.printJSON(table)
+	}
+	names := make([]string, 0, len(table.Columns))
+	for name := range table.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []string{name, table.Columns[name].String()})
+	}
+	return c.printTable([]string{"COLUMN", "TYPE"}, rows)
+}
+
+func (c *cli) dump(args []string) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	return c.export(ovsdb.ExportOptions{Database: c.db, Tables: args})
+}
+
+func (c *cli) query(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a table is required")
+	}
+	opts := ovsdb.ExportOptions{Database: c.db, Tables: args[:1], Columns: splitList(c.columns)}
+	for _, arg := range args[1:] {
+		cond, err := parseCondition(arg)
+		if err != nil {
+			return err
+		}
+		opts.Conditions = append(opts.Conditions, cond)
+	}
+	for _, column := range splitList(c.sort) {
+		key := ovsdb.SortKey{Column: column}
+		if strings.HasPrefix(column, "-") {
+			key = ovsdb.SortKey{Column: column[1:], Descending: true}
+		}
+		opts.Sort = append(opts.Sort, key)
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	return c.export(opts)
+}
+
+// export prints an export in the format of -format, or the one -o implies
+func (c *cli) export(opts ovsdb.ExportOptions) error {
+	opts.Format = c.format
+	if opts.Format == "" {
+		opts.Format = ovsdb.ExportDump
+		if c.output == outputJSON {
+			opts.Format = ovsdb.ExportJSON
+		}
+	}
+	client, err := c.app.client(c.session)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(c.stdout)
+	if err := client.Export(c.app.ctx, w, opts); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// cliOperators are the condition functions a query argument can use; at the same position
+// the longer one wins, so "<=" is not read as "<"
+var cliOperators = []string{"==", "!=", "<=", ">=", "<", ">", " includes ", " excludes "}
+
+// parseCondition reads a condition written as <column><function><value>
+func parseCondition(arg string) (ovsdb.Condition, error) {
+	at, op := -1, ""
+	for _, candidate := range cliOperators {
+		i := strings.Index(arg, candidate)
+		if i >= 0 && (at < 0 || i < at || i == at && len(candidate) > len(op)) {
+			at, op = i, candidate
+		}
+	}
+	if at <= 0 {
+		return ovsdb.Condition{}, fmt.Errorf("invalid condition %q: expected <column><function><value>", arg)
+	}
+	cond := ovsdb.Condition{
+		Column:   strings.TrimSpace(arg[:at]),
+		Function: strings.TrimSpace(op),
+	}
+	text := strings.TrimSpace(arg[at+len(op):])
+	// Numbers are kept as typed, so that integers beyond 2^53 survive
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	if err := dec.Decode(&cond.Value); err != nil {
+		cond.Value = text
+	} else if _, err := dec.Token(); err != io.EOF {
+		cond.Value = text
+	}
+	return cond, nil
+}
+
+func (c *cli) watch(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("exactly one table is required")
+	}
+	if err := c.connect(); err != nil {
+		return err
+	}
+	c.printMu.Lock()
+	c.watching = true
+	c.printMu.Unlock()
+	rows, err := c.app.MonitorTable(c.session, c.db, args[0])
+	if err != nil {
+		return err
+	}
+	c.printUpdate(TableUpdate{Database: c.db, Table: args[0], Reset: true, Inserted: rows})
+	if c.app.GetDatabaseFileInfo(c.session) != nil {
+		// A database file never changes
+		return nil
+	}
+	<-c.app.ctx.Done()
+	return nil
+}
+
+// printUpdate prints the changes to a watched table: one JSON object per update, or one
+// line per row with the time, the change, the uuid and the row
+func (c *cli) printUpdate(update TableUpdate) {
+	c.printMu.Lock()
+	defer c.printMu.Unlock()
+	if !c.watching {
+		return
+	}
+	if c.output == outputJSON {
+		data, err := json.Marshal(update)
+		if err == nil {
+			fmt.Fprintf(c.stdout, "%s\n", data)
+		}
+		return
+	}
+	now := time.Now().Format("15:04:05")
+	insert := "insert"
+	if update.Reset {
+		insert = "row"
+	}
+	for _, row := range update.Inserted {
+		c.printRow(now, insert, row)
+	}
+	for _, row := range update.Modified {
+		c.printRow(now, "modify", row)
+	}
+	for _, uuid := range update.Deleted {
+		fmt.Fprintf(c.stdout, "%s  %-6s  %s\n", now, "delete", uuid)
+	}
+}
+
+func (c *cli) printRow(now string, change string, row ovsdb.TypedRow) {
+	rest := make(map[string]any, len(row))
+	for name, value := range row {
+		if name != "_uuid" {
+			rest[name] = value.Plain()
+		}
+	}
+	data, _ := json.Marshal(rest)
+	fmt.Fprintf(c.stdout, "%s  %-6s  %v  %s\n", now, change, row["_uuid"].Plain(), data)
+}
+
+// handleEvent receives the events meant for the frontend: prompts are asked on the
+// terminal, table updates printed while watching, and trouble reported on stderr
+func (c *cli) handleEvent(name string, data interface{}) {
+	switch event := data.(type) {
+	case HostKeyPrompt:
+		c.confirmHostKey(event)
+	case AuthPrompt:
+		c.answerAuth(event)
+	case TableUpdate:
+		c.printUpdate(event)
+	case ConnectionState:
+		if event.State == ovsdb.StateReconnecting {
+			fmt.Fprintf(c.stderr, "reconnecting to %s (attempt %d): %s\n", event.Endpoint, event.Attempt, event.Error)
+		}
+	case string:
+		fmt.Fprintf(c.stderr, "%s: %s\n", name, event)
+	}
+}
+
+func (c *cli) confirmHostKey(p HostKeyPrompt) {
+	fmt.Fprintf(c.stderr, "The authenticity of host %s (%s) can't be established.\n%s key fingerprint is %s.\nAdd it to known_hosts and continue? [y/N] ",
+		p.Host, p.Address, p.KeyType, p.Fingerprint)
+	line, err := c.readLine()
+	accept := err == nil && (strings.EqualFold(line, "y") || strings.EqualFold(line, "yes"))
+	c.app.ConfirmHostKey(p.ID, accept)
+}
+
+func (c *cli) answerAuth(p AuthPrompt) {
+	message := strings.TrimSpace(p.Message)
+	if message == "" {
+		message = fmt.Sprintf("%s for %s@%s:", p.Kind, p.User, p.Host)
+	}
+	fmt.Fprintf(c.stderr, "%s ", message)
+	var value string
+	var err error
+	if fd := int(os.Stdin.Fd()); !p.Echo && term.IsTerminal(fd) {
+		var secret []byte
+		secret, err = term.ReadPassword(fd)
+		fmt.Fprintln(c.stderr)
+		value = string(secret)
+	} else {
+		value, err = c.readLine()
+	}
+	c.app.AnswerAuthPrompt(p.ID, value, err == nil)
+}
+
+// readLine reads one line of standard input, without its line ending
+func (c *cli) readLine() (string, error) {
+	line, err := c.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *cli) printJSON(v interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cli) printTable(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
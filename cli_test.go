@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ovsdb-viewer/internal/ovsdb"
+
+	ovsdbovsdb "github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want ovsdb.Condition
+	}{
+		{"name==sw0", ovsdb.Condition{Column: "name", Function: "==", Value: "sw0"}},
+		{`name=="sw0"`, ovsdb.Condition{Column: "name", Function: "==", Value: "sw0"}},
+		{"name != sw0 ", ovsdb.Condition{Column: "name", Function: "!=", Value: "sw0"}},
+		{"priority<=100", ovsdb.Condition{Column: "priority", Function: "<=", Value: json.Number("100")}},
+		{"priority>=1000", ovsdb.Condition{Column: "priority", Function: ">=", Value: json.Number("1000")}},
+		{"priority<5", ovsdb.Condition{Column: "priority", Function: "<", Value: json.Number("5")}},
+		{"tunnel_key==9007199254740993", ovsdb.Condition{Column: "tunnel_key", Function: "==", Value: json.Number("9007199254740993")}},
+		{"name==1 2", ovsdb.Condition{Column: "name", Function: "==", Value: "1 2"}},
+		{"enabled==true", ovsdb.Condition{Column: "enabled", Function: "==", Value: true}},
+		{"match==ip4.src == 10.0.0.1", ovsdb.Condition{Column: "match", Function: "==", Value: "ip4.src == 10.0.0.1"}},
+		{`external_ids includes {"owner":"ovn"}`, ovsdb.Condition{Column: "external_ids", Function: "includes", Value: map[string]interface{}{"owner": "ovn"}}},
+		{`addresses excludes ["dynamic"]`, ovsdb.Condition{Column: "addresses", Function: "excludes", Value: []interface{}{"dynamic"}}},
+		{"name==", ovsdb.Condition{Column: "name", Function: "==", Value: ""}},
+	}
+	for _, tt := range tests {
+		got, err := parseCondition(tt.arg)
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", tt.arg, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCondition(%q) = %#v, want %#v", tt.arg, got, tt.want)
+		}
+	}
+
+	for _, arg := range []string{"", "name", "==sw0", " includes x"} {
+		if _, err := parseCondition(arg); err == nil {
+			t.Errorf("parseCondition(%q) accepted an invalid condition", arg)
+		}
+	}
+}
+
+// runTestCLI runs a subcommand as the binary would and returns its exit status and output
+func runTestCLI(args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	c := &cli{
+		app:    NewApp(),
+		stdin:  bufio.NewReader(strings.NewReader("")),
+		stdout: &stdout,
+		stderr: &stderr,
+	}
+	status := c.run(args)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	endpoint := startServer(t)
+	client := &ovsdb.OVSDBClient{}
+	if err := client.Connect(context.Background(), ovsdb.ConnectionConfig{}, endpoint, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	for _, name := range []string{"br0", "br1"} {
+		if _, err := client.Transact(context.Background(), "", ovsdbovsdb.Operation{Op: ovsdbovsdb.OperationInsert, Table: "Bridge", Row: ovsdbovsdb.Row{"name": name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) string {
+		t.Helper()
+		status, stdout, stderr := runTestCLI(args...)
+		if status != 0 {
+			t.Fatalf("%s exited with %d: %s", strings.Join(args, " "), status, stderr)
+		}
+		return stdout
+	}
+
+	// The test server has no rows in _Server.Database, so it lists no databases
+	if out := run("list-dbs", "-endpoint", endpoint, "-o", "json"); out != "[]\n" {
+		t.Errorf("list-dbs printed %q", out)
+	}
+	if out := run("schema", "-endpoint", endpoint, "Bridge"); !strings.HasPrefix(out, "COLUMN") || !strings.Contains(out, "external_ids") {
+		t.Errorf("schema of Bridge printed\n%s", out)
+	}
+
+	// Each connection is remembered and can be made again by its number
+	var recent []cliRecent
+	if err := json.Unmarshal([]byte(run("history", "-o", "json")), &recent); err != nil || len(recent) != 1 || recent[0].Recent != 1 ||
+		len(recent[0].Endpoints) != 1 || recent[0].Endpoints[0].Endpoint != endpoint {
+		t.Errorf("history printed %+v, %v", recent, err)
+	}
+	out := run("query", "-recent", "1", "-columns", "name", "-sort", "-name", "Bridge", "name!=br2")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || lines[0] != "Bridge table" || !strings.Contains(lines[3], `"br1"`) || !strings.Contains(lines[4], `"br0"`) {
+		t.Errorf("query printed\n%s", out)
+	}
+	out = run("query", "-endpoint", endpoint, "-format", "csv", "-columns", "name", "Bridge", "name==br1")
+	if rows, err := csv.NewReader(strings.NewReader(out)).ReadAll(); err != nil || len(rows) != 2 || rows[1][1] != "br1" {
+		t.Errorf("query as CSV printed\n%s", out)
+	}
+	if out := run("dump", "-endpoint", endpoint); !strings.Contains(out, "Bridge table") || !strings.Contains(out, "Port table") {
+		t.Errorf("dump printed\n%s", out)
+	}
+
+	for _, args := range [][]string{
+		{"list-dbs"},
+		{"list-dbs", "-endpoint", endpoint, "-file", "test.db"},
+		{"list-dbs", "-recent", "2"},
+		{"query", "-endpoint", endpoint},
+		{"query", "-endpoint", endpoint, "Bridge", "name"},
+		{"schema", "-endpoint", endpoint, "Other"},
+	} {
+		if status, _, stderr := runTestCLI(args...); status != 1 || stderr == "" {
+			t.Errorf("%s exited with %d: %s", strings.Join(args, " "), status, stderr)
+		}
+	}
+	if status, _, _ := runTestCLI("list-dbs", "-o", "xml"); status != 2 {
+		t.Errorf("unknown output format exited with %d", status)
+	}
+}
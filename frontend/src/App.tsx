@@ -39,7 +39,10 @@ import {
   GetTable,
   GetTableDynamic,
   ListDatabases,
+  ConfirmHostKey,
+  AnswerAuthPrompt,
 } from "../wailsjs/go/main/App";
+import { EventsOn } from "../wailsjs/runtime/runtime";
 import { PlusOutlined, DeleteOutlined } from "@ant-design/icons";
 import { main, ovsdb } from "../wailsjs/go/models";
 
@@ -90,6 +93,61 @@ interface EndpointConfigPayload {
   tunnel?: HistoryTunnel;
 }
 
+// Rows arrive typed: integers beyond 2^53 come as strings so they stay exact
+interface TypedAtom {
+  type: string;
+  value: any;
+  refTable?: string;
+}
+
+interface TypedValue {
+  kind: "atom" | "optional" | "set" | "map";
+  atom?: TypedAtom;
+  set?: TypedAtom[];
+  map?: { key: TypedAtom; value: TypedAtom }[];
+}
+
+// plainRow turns a typed row into the values the table renders: a set becomes an array,
+// a map an object and an empty optional undefined
+const plainRow = (row: { [column: string]: TypedValue }) => {
+  const plain: { [column: string]: any } = {};
+  for (const [column, value] of Object.entries(row)) {
+    switch (value.kind) {
+      case "set":
+        plain[column] = (value.set || []).map((atom) => atom.value);
+        break;
+      case "map":
+        plain[column] = Object.fromEntries(
+          (value.map || []).map((pair) => [pair.key.value, pair.value.value])
+        );
+        break;
+      default:
+        plain[column] = value.atom?.value;
+    }
+  }
+  return plain;
+};
+
+// An SSH host key that is not in known_hosts yet, sent as the "ssh:host-key" event
+interface HostKeyPrompt {
+  id: string;
+  host: string;
+  address: string;
+  keyType: string;
+  fingerprint: string;
+}
+
+// A passphrase, password or keyboard-interactive question, sent as the "ssh:auth-prompt"
+// event; echo tells whether the answer may be shown while typed
+interface AuthPrompt {
+  id: string;
+  kind: string;
+  host: string;
+  user: string;
+  message: string;
+  echo: boolean;
+}
+
 const DEFAULT_LOCAL_FORWARDER = "tcp";
 const DEFAULT_SSH_PORT = 22;
 
@@ -221,6 +279,8 @@ function App() {
   const [currentDb, setCurrentDb] = useState("Open_vSwitch");
   const [dbList, setDbList] = useState<string[]>([]);
   const [currentConnectionRequest, setCurrentConnectionRequest] = useState<any>(null);
+  // Backend session of this window's connection; reconnecting reuses it
+  const [sessionId, setSessionId] = useState("");
 
   // UI states
   const [showConnectModal, setShowConnectModal] = useState(false);
@@ -245,6 +305,10 @@ function App() {
   const [drawerTitle, setDrawerTitle] = useState<string | null>(null);
   const [drawerContent, setDrawerContent] = useState<any | null>(null);
   const [highlightedRow, setHighlightedRow] = useState<string | null>(null);
+  // SSH prompts waiting for an answer while a connection is made, oldest first
+  const [hostKeyPrompts, setHostKeyPrompts] = useState<HostKeyPrompt[]>([]);
+  const [authPrompts, setAuthPrompts] = useState<AuthPrompt[]>([]);
+  const [authAnswer, setAuthAnswer] = useState("");
   const endpointsWatch = Form.useWatch("endpoints", form) as
     | EndpointFormValues[]
     | undefined;
@@ -300,7 +364,8 @@ function App() {
       // Save connection request for switching databases later
       setCurrentConnectionRequest(request);
 
-      await ConnectDynamic(request, currentDb);
+      const session = await ConnectDynamic(sessionId, request, currentDb);
+      setSessionId(session);
       setConnected(true);
       setConnectionStatus("Connected successfully!");
       setShowConnectModal(false);
@@ -308,14 +373,14 @@ function App() {
 
       // Fetch available DBs
       try {
-        const dbs = await ListDatabases();
+        const dbs = await ListDatabases(session);
         setDbList(dbs);
       } catch (e) {
         console.error("Failed to list databases", e);
       }
 
       // Load schema
-      const dbSchema = await GetSchemaDynamic(currentDb);
+      const dbSchema = await GetSchemaDynamic(session, currentDb);
       setSchema(dbSchema);
       // If a table is already selected, load just that one (useEffect also covers this)
       if (selectedTable) {
@@ -328,26 +393,20 @@ function App() {
   }
 
   async function switchDatabase(dbName: string) {
-    if (!currentConnectionRequest) return;
-    if (dbName === currentDb && connected) return;
+    if (!connected) return;
+    if (dbName === currentDb) return;
 
     try {
       setConnectionStatus(`Switching to ${dbName}...`);
-      // Disconnect first? ConnectDynamic handles it, but good to be clean.
-      // Actually ConnectDynamic in backend handles disconnect.
-      
-      await ConnectDynamic(currentConnectionRequest, dbName);
+      // The backend serves every database of the server over the same connection
+      const dbSchema = await GetSchemaDynamic(sessionId, dbName);
       setCurrentDb(dbName);
-      setConnected(true);
       setConnectionStatus(`Connected to ${dbName}`);
       
       // Clear previous DB state
       setMonitoredTables([]);
       setSelectedTable(null);
       setTableData({});
-      
-      // Load new schema
-      const dbSchema = await GetSchemaDynamic(dbName);
       setSchema(dbSchema);
       
     } catch (error) {
@@ -358,7 +417,8 @@ function App() {
 
   async function disconnectOVSDB() {
     try {
-      await DisconnectOVSDB();
+      await DisconnectOVSDB(sessionId);
+      setSessionId("");
       setConnected(false);
       setConnectionStatus("Disconnected");
       // Clear data
@@ -379,8 +439,11 @@ function App() {
       }
       setDataStatus(`Loading ${tableName}...`);
       console.log("loadDataForTable: tableName =", tableName);
-      const res = await GetTableDynamic(currentDb, tableName);
-      setTableData((prev) => ({ ...prev, [tableName]: res }));
+      const res = await GetTableDynamic(sessionId, currentDb, tableName);
+      setTableData((prev) => ({
+        ...prev,
+        [tableName]: (res || []).map((row: any) => plainRow(row)),
+      }));
       setDataStatus("Data loaded successfully");
     } catch (error) {
       console.log("loadDataForTable error:", error);
@@ -713,6 +776,43 @@ function App() {
     loadHistory();
   }, []);
 
+  // Queue the SSH prompts the backend waits on while connecting
+  useEffect(() => {
+    const offHostKey = EventsOn("ssh:host-key", (prompt: HostKeyPrompt) =>
+      setHostKeyPrompts((prompts) => [...prompts, prompt]),
+    );
+    const offAuth = EventsOn("ssh:auth-prompt", (prompt: AuthPrompt) =>
+      setAuthPrompts((prompts) => [...prompts, prompt]),
+    );
+    return () => {
+      offHostKey();
+      offAuth();
+    };
+  }, []);
+
+  async function answerHostKey(accept: boolean) {
+    const prompt = hostKeyPrompts[0];
+    setHostKeyPrompts((prompts) => prompts.slice(1));
+    try {
+      await ConfirmHostKey(prompt.id, accept);
+    } catch (err) {
+      // The prompt timed out; the connection attempt reports it
+      console.error(err);
+    }
+  }
+
+  async function answerAuth(ok: boolean) {
+    const prompt = authPrompts[0];
+    const answer = authAnswer;
+    setAuthAnswer("");
+    setAuthPrompts((prompts) => prompts.slice(1));
+    try {
+      await AnswerAuthPrompt(prompt.id, ok ? answer : "", ok);
+    } catch (err) {
+      console.error(err);
+    }
+  }
+
   // Compute table body scroll y (so Table uses an internal scroll area and header becomes fixed inside the table)
   useEffect(() => {
     function computeHeight() {
@@ -1080,6 +1180,68 @@ function App() {
             {JSON.stringify(drawerContent, null, 2)}
           </pre>
         </Drawer>
+        <Modal
+          title="Unknown SSH host key"
+          open={hostKeyPrompts.length > 0}
+          okText="Trust and connect"
+          cancelText="Reject"
+          onOk={() => answerHostKey(true)}
+          onCancel={() => answerHostKey(false)}
+          maskClosable={false}
+        >
+          {hostKeyPrompts.length > 0 && (
+            <div>
+              <p>
+                The authenticity of host <b>{hostKeyPrompts[0].host}</b> (
+                {hostKeyPrompts[0].address}) can't be established.
+              </p>
+              <p>
+                {hostKeyPrompts[0].keyType} key fingerprint:
+                <br />
+                <code>{hostKeyPrompts[0].fingerprint}</code>
+              </p>
+              <p>Trusting it adds the key to ~/.ssh/known_hosts.</p>
+            </div>
+          )}
+        </Modal>
+        <Modal
+          title={
+            authPrompts.length > 0
+              ? `SSH ${authPrompts[0].kind} for ${authPrompts[0].user}@${authPrompts[0].host}`
+              : ""
+          }
+          open={authPrompts.length > 0}
+          okText="OK"
+          onOk={() => answerAuth(true)}
+          onCancel={() => answerAuth(false)}
+          maskClosable={false}
+          destroyOnClose
+        >
+          {authPrompts.length > 0 && (
+            <Space direction="vertical" style={{ width: "100%" }}>
+              <span>
+                {authPrompts[0].kind === "passphrase"
+                  ? `Passphrase for key ${authPrompts[0].message}`
+                  : authPrompts[0].message}
+              </span>
+              {authPrompts[0].echo ? (
+                <Input
+                  autoFocus
+                  value={authAnswer}
+                  onChange={(e) => setAuthAnswer(e.target.value)}
+                  onPressEnter={() => answerAuth(true)}
+                />
+              ) : (
+                <Input.Password
+                  autoFocus
+                  value={authAnswer}
+                  onChange={(e) => setAuthAnswer(e.target.value)}
+                  onPressEnter={() => answerAuth(true)}
+                />
+              )}
+            </Space>
+          )}
+        </Modal>
         <Modal
           title="Connect to OVSDB"
           open={showConnectModal}
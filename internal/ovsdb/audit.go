@@ -0,0 +1,274 @@
+package ovsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AuditRecord is one transaction that writes, or tried to through a read-only connection,
+// as written to the audit log
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`     // OS user running the viewer, or the client's AuditUser
+	Endpoint string    `json:"endpoint"` // endpoint as configured
+	Local    string    `json:"local,omitempty"`
+	// Route is how the endpoint was reached: the resolved SSH jump hosts and target, or the
+	// relay command; empty for direct connections
+	Route      []string        `json:"route,omitempty"`
+	Database   string          `json:"database"`
+	Comment    string          `json:"comment,omitempty"`
+	Operations json.RawMessage `json:"operations"`
+	Results    []AuditResult   `json:"results,omitempty"`
+	Error      string          `json:"error,omitempty"` // the transaction could not be run
+	DurationMs float64         `json:"durationMs"`
+}
+
+// AuditResult is the outcome of one operation; selected rows are only counted
+type AuditResult struct {
+	Count   int    `json:"count,omitempty"`
+	UUID    string `json:"uuid,omitempty"`
+	Rows    int    `json:"rows,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit records; empty fields match everything. Text is matched, case
+// insensitively, against the whole record.
+type AuditFilter struct {
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	User     string    `json:"user,omitempty"`
+	Endpoint string    `json:"endpoint,omitempty"`
+	Database string    `json:"database,omitempty"`
+	Table    string    `json:"table,omitempty"`
+	Op       string    `json:"op,omitempty"`
+	Text     string    `json:"text,omitempty"`
+	// WritesOnly skips transactions made only of selects, waits and comments, which logs
+	// written by earlier versions hold
+	WritesOnly bool `json:"writesOnly,omitempty"`
+	Failed     bool `json:"failed,omitempty"` // only transactions with an error
+	Limit      int  `json:"limit,omitempty"`  // newest records first; 0 for all
+}
+
+// AuditLog is an append-only JSON Lines file with one record per transaction that writes
+type AuditLog struct {
+	path string
+	user string
+	mu   sync.Mutex
+}
+
+// DefaultAuditLogPath returns ~/.ovsdb-viewer/audit.jsonl
+func DefaultAuditLogPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ovsdb-viewer", "audit.jsonl"), nil
+}
+
+// NewAuditLog returns the audit log kept in path; the file is created on the first record
+func NewAuditLog(path string) *AuditLog {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return &AuditLog{path: path, user: name}
+}
+
+// Path returns the file the log is kept in
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// Append adds a record at the end of the log
+func (l *AuditLog) Append(record AuditRecord) error {
+	if record.User == "" {
+		record.User = l.user
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Records returns the records matching the filter, newest first. Lines that cannot be
+// decoded, such as one cut short by a crash, are skipped.
+func (l *AuditLog) Records(filter AuditFilter) ([]AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditRecord{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	text := strings.ToLower(filter.Text)
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	// Operations carry whole rows, which can be large
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if text != "" && !strings.Contains(strings.ToLower(string(line)), text) {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		if filter.match(&record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	// The file is in the order the records were written
+	newest := make([]AuditRecord, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(newest) == filter.Limit {
+			break
+		}
+		newest = append(newest, records[i])
+	}
+	return newest, nil
+}
+
+// match applies every filter field but Text and Limit
+func (f AuditFilter) match(r *AuditRecord) bool {
+	switch {
+	case !f.Since.IsZero() && r.Time.Before(f.Since),
+		!f.Until.IsZero() && r.Time.After(f.Until),
+		f.User != "" && r.User != f.User,
+		f.Endpoint != "" && !strings.Contains(r.Endpoint, f.Endpoint) && !strings.Contains(strings.Join(r.Route, " "), f.Endpoint),
+		f.Database != "" && r.Database != f.Database,
+		f.Failed && !r.failed():
+		return false
+	}
+	if f.Table == "" && f.Op == "" && !f.WritesOnly {
+		return true
+	}
+
+	var ops []struct {
+		Op    string `json:"op"`
+		Table string `json:"table"`
+	}
+	if err := json.Unmarshal(r.Operations, &ops); err != nil {
+		return false
+	}
+	tableFound, opFound, write := f.Table == "", f.Op == "", false
+	for _, op := range ops {
+		tableFound = tableFound || op.Table == f.Table
+		opFound = opFound || op.Op == f.Op
+		switch op.Op {
+		case ovsdb.OperationSelect, ovsdb.OperationWait, ovsdb.OperationComment:
+		default:
+			write = true
+		}
+	}
+	return tableFound && opFound && (write || !f.WritesOnly)
+}
+
+// failed reports whether the transaction or any of its operations failed
+func (r *AuditRecord) failed() bool {
+	if r.Error != "" {
+		return true
+	}
+	for _, result := range r.Results {
+		if result.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// audit records a transaction that writes in the audit log, if there is one; transactions
+// that only read are not recorded. The transaction has already run, or was rejected, so a
+// failure to record it is reported through OnAuditError.
+func (c *OVSDBClient) audit(dbName string, ops []ovsdb.Operation, results []ovsdb.OperationResult, txErr error, start time.Time) {
+	if c.Audit == nil || checkReadOnly(ops) == nil {
+		return
+	}
+	if dbName == "" {
+		dbName = c.dbName
+	}
+	record := AuditRecord{
+		Time:       start,
+		User:       c.AuditUser,
+		Database:   dbName,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	record.Endpoint, record.Local, record.Route = c.auditEndpoint()
+	for _, op := range ops {
+		if op.Op == ovsdb.OperationComment && op.Comment != nil {
+			record.Comment = *op.Comment
+		}
+	}
+	if txErr != nil {
+		record.Error = txErr.Error()
+	}
+	for _, result := range results {
+		record.Results = append(record.Results, AuditResult{
+			Count:   result.Count,
+			UUID:    result.UUID.GoUUID,
+			Rows:    len(result.Rows),
+			Error:   result.Error,
+			Details: result.Details,
+		})
+	}
+	data, err := json.Marshal(ops)
+	if err == nil {
+		record.Operations = data
+		err = c.Audit.Append(record)
+	}
+	if err != nil && c.OnAuditError != nil {
+		c.OnAuditError(fmt.Errorf("failed to audit transaction on %s: %w", dbName, err))
+	}
+}
+
+// auditEndpoint describes the member in use: its configured endpoint, the local endpoint
+// libovsdb dialed when it is tunneled, and the route the tunnel takes
+func (c *OVSDBClient) auditEndpoint() (string, string, []string) {
+	current := c.client.CurrentEndpoint()
+	c.membersMu.Lock()
+	defer c.membersMu.Unlock()
+	for _, link := range c.members {
+		if link.localEndpoint == "" || link.localEndpoint != current {
+			continue
+		}
+		if link.localEndpoint == link.Endpoint {
+			return link.Endpoint, "", nil
+		}
+		return link.Endpoint, link.localEndpoint, link.hops
+	}
+	return current, "", nil
+}
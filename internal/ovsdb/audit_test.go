@@ -0,0 +1,137 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestAuditLogRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := NewAuditLog(path)
+	if records, err := l.Records(AuditFilter{}); err != nil || len(records) != 0 {
+		t.Fatalf("records of a missing log = %+v, %v", records, err)
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ops := func(ops ...ovsdb.Operation) json.RawMessage {
+		data, err := json.Marshal(ops)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	records := []AuditRecord{
+		{Time: start, User: "alice", Endpoint: "tcp:10.0.0.1:6641", Database: "OVN_Northbound",
+			Operations: ops(ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Logical_Switch"})},
+		{Time: start.Add(time.Hour), Endpoint: "unix:/run/db.sock", Route: []string{"bastion", "node1"}, Database: "OVN_Southbound",
+			Operations: ops(ovsdb.Operation{Op: ovsdb.OperationDelete, Table: "Chassis"}), Results: []AuditResult{{Error: "constraint violation"}}},
+		{Time: start.Add(2 * time.Hour), User: "bob", Endpoint: "tcp:10.0.0.1:6641", Database: "OVN_Northbound", Comment: "Cleanup",
+			Operations: ops(ovsdb.Operation{Op: ovsdb.OperationSelect, Table: "Logical_Switch"})},
+	}
+	for _, record := range records {
+		if err := l.Append(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A line cut short by a crash is skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time": "2024-05-01T15:00:00Z", "user": "carol", "oper`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []int // indexes into records, newest first
+	}{
+		{"all", AuditFilter{}, []int{2, 1, 0}},
+		{"limit", AuditFilter{Limit: 2}, []int{2, 1}},
+		{"since", AuditFilter{Since: start.Add(time.Hour)}, []int{2, 1}},
+		{"until", AuditFilter{Until: start.Add(time.Hour)}, []int{1, 0}},
+		{"user", AuditFilter{User: "alice"}, []int{0}},
+		{"endpoint", AuditFilter{Endpoint: "10.0.0.1"}, []int{2, 0}},
+		{"route", AuditFilter{Endpoint: "bastion"}, []int{1}},
+		{"database", AuditFilter{Database: "OVN_Southbound"}, []int{1}},
+		{"table", AuditFilter{Table: "Logical_Switch"}, []int{2, 0}},
+		{"op", AuditFilter{Op: ovsdb.OperationDelete}, []int{1}},
+		{"text", AuditFilter{Text: "cleanup"}, []int{2}},
+		{"writes only", AuditFilter{WritesOnly: true}, []int{1, 0}},
+		{"failed", AuditFilter{Failed: true}, []int{1}},
+		{"several fields", AuditFilter{Table: "Logical_Switch", WritesOnly: true}, []int{0}},
+	}
+	for _, tt := range tests {
+		got, err := l.Records(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d records, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, n := range tt.want {
+			if !got[i].Time.Equal(records[n].Time) {
+				t.Errorf("%s: record %d is from %v, want %v", tt.name, i, got[i].Time, records[n].Time)
+			}
+		}
+	}
+	if got, _ := l.Records(AuditFilter{Until: start.Add(time.Hour)}); got[0].User != l.user {
+		t.Errorf("record without a user has user %q, want %q", got[0].User, l.user)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	sock := startServer(t)
+	l := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	c := &OVSDBClient{Audit: l, AuditUser: "alice", OnAuditError: func(err error) { t.Error(err) }}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+
+	// Reads are not recorded, writes are with their results
+	if _, err := c.GetTableData(ctx, "", "Bridge"); err != nil {
+		t.Fatal(err)
+	}
+	insert := ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": "br0"}}
+	results, err := c.Transact(ctx, "", insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := l.Records(AuditFilter{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records after a select and an insert = %+v, %v", records, err)
+	}
+	record := records[0]
+	if record.User != "alice" || record.Endpoint != "unix:"+sock || record.Database != "Open_vSwitch" || record.Error != "" {
+		t.Errorf("record of the insert = %+v", record)
+	}
+	if len(record.Results) != 1 || record.Results[0].UUID != results[0].UUID.GoUUID || record.Results[0].UUID == "" {
+		t.Errorf("results of the insert = %+v, want the uuid %s", record.Results, results[0].UUID.GoUUID)
+	}
+	var ops []ovsdb.Operation
+	if err := json.Unmarshal(record.Operations, &ops); err != nil || len(ops) != 1 || ops[0].Op != ovsdb.OperationInsert || ops[0].Table != "Bridge" {
+		t.Errorf("operations of the insert = %s, %v", record.Operations, err)
+	}
+
+	// Writes refused by a read-only connection are recorded with the reason
+	c.ReadOnly = true
+	comment := "remove br0"
+	_, refused := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationComment, Comment: &comment},
+		ovsdb.Operation{Op: ovsdb.OperationDelete, Table: "Bridge", Where: []ovsdb.Condition{}})
+	if !errors.Is(refused, ErrReadOnly) {
+		t.Fatalf("delete on a read-only connection returned %v", refused)
+	}
+	records, err = l.Records(AuditFilter{Failed: true})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("failed records = %+v, %v", records, err)
+	}
+	if record := records[0]; record.Comment != comment || record.Error != refused.Error() || len(record.Results) != 0 {
+		t.Errorf("record of the refused delete = %+v", record)
+	}
+}
@@ -0,0 +1,204 @@
+package ovsdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Supported SSH authentication method types
+const (
+	AuthAgent               = "agent"
+	AuthPublicKey           = "publickey"
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
+)
+
+// AuthMethod selects one way of authenticating to an SSH hop. Methods are tried in order.
+type AuthMethod struct {
+	Type     string // one of AuthAgent, AuthPublicKey, AuthPassword, AuthKeyboardInteractive
+	KeyFile  string // private key for AuthPublicKey
+	CertFile string // OpenSSH certificate for KeyFile (default: KeyFile + "-cert.pub" if it exists)
+}
+
+// AuthPrompt describes a secret the user must supply while authenticating
+type AuthPrompt struct {
+	Kind    string // "passphrase", AuthPassword or AuthKeyboardInteractive
+	Host    string // host:port of the hop
+	User    string
+	Message string // key file for passphrases, the server's question for keyboard-interactive
+	Echo    bool   // whether the answer may be displayed while typed
+}
+
+// authMethodsFor returns the configured methods for a hop; jump hosts fall back to the target's
+// methods. Without any configuration, ssh-agent and the hop's ssh_config IdentityFiles are used.
+func (c *ConnectionConfig) authMethodsFor(jump string, identityFiles []string) []AuthMethod {
+	if methods, ok := c.JumpAuth[jump]; ok && len(methods) > 0 {
+		return methods
+	}
+	if len(c.Auth) > 0 {
+		return c.Auth
+	}
+	// Legacy configurations only carry a key file
+	if c.KeyFile != "" {
+		return []AuthMethod{{Type: AuthPublicKey, KeyFile: c.KeyFile}}
+	}
+	var methods []AuthMethod
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		methods = append(methods, AuthMethod{Type: AuthAgent})
+	}
+	if len(identityFiles) == 0 {
+		identityFiles = defaultIdentityFiles()
+	}
+	for _, file := range identityFiles {
+		// Like OpenSSH, IdentityFiles that do not exist are skipped silently
+		if _, err := os.Stat(file); err == nil {
+			methods = append(methods, AuthMethod{Type: AuthPublicKey, KeyFile: file})
+		}
+	}
+	if len(methods) == 0 {
+		// Nothing usable; the agent method reports the missing SSH_AUTH_SOCK clearly
+		methods = append(methods, AuthMethod{Type: AuthAgent})
+	}
+	return methods
+}
+
+// defaultIdentityFiles returns the keys OpenSSH tries when no IdentityFile is configured
+func defaultIdentityFiles() []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519"} {
+		files = append(files, filepath.Join(homeDir, ".ssh", name))
+	}
+	return files
+}
+
+// sshAuth builds the ssh.AuthMethods for a hop, leaving out an ssh-agent that cannot be
+// reached. The returned closer releases the agent connection, if any, and must be called
+// once the handshake is over.
+func (c *ConnectionConfig) sshAuth(methods []AuthMethod, user, addr string) ([]ssh.AuthMethod, func(), error) {
+	var auth []ssh.AuthMethod
+	var closers []io.Closer
+	var unavailable error // why the agent was skipped
+	closeAll := func() {
+		for _, cl := range closers {
+			cl.Close()
+		}
+	}
+
+	for _, m := range methods {
+		switch m.Type {
+		case AuthAgent:
+			// Like OpenSSH, an agent that cannot be reached is skipped in favour of the
+			// other methods; it only fails the hop when nothing else is left
+			sock := os.Getenv("SSH_AUTH_SOCK")
+			if sock == "" {
+				unavailable = fmt.Errorf("ssh-agent requested but SSH_AUTH_SOCK is not set")
+				continue
+			}
+			conn, err := net.Dial("unix", sock)
+			if err != nil {
+				unavailable = fmt.Errorf("failed to connect to ssh-agent: %w", err)
+				continue
+			}
+			closers = append(closers, conn)
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		case AuthPublicKey:
+			m := m
+			// Loaded lazily so a passphrase is only asked for when the server gets to this method
+			auth = append(auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+				signer, err := c.loadSigner(m, user, addr)
+				if err != nil {
+					return nil, err
+				}
+				return []ssh.Signer{signer}, nil
+			}))
+		case AuthPassword:
+			auth = append(auth, ssh.PasswordCallback(func() (string, error) {
+				return c.ask(AuthPrompt{Kind: AuthPassword, Host: addr, User: user, Message: fmt.Sprintf("Password for %s@%s", user, addr)})
+			}))
+		case AuthKeyboardInteractive:
+			auth = append(auth, ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i, q := range questions {
+					answer, err := c.ask(AuthPrompt{Kind: AuthKeyboardInteractive, Host: addr, User: user, Message: q, Echo: echos[i]})
+					if err != nil {
+						return nil, err
+					}
+					answers[i] = answer
+				}
+				return answers, nil
+			}))
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unsupported auth method: %q", m.Type)
+		}
+	}
+	if len(auth) == 0 && unavailable != nil {
+		return nil, nil, unavailable
+	}
+	return auth, closeAll, nil
+}
+
+// ask forwards a prompt to the Prompt callback
+func (c *ConnectionConfig) ask(p AuthPrompt) (string, error) {
+	if c.Prompt == nil {
+		return "", fmt.Errorf("%s required for %s but no prompt is available", p.Kind, p.Host)
+	}
+	return c.Prompt(p)
+}
+
+// loadSigner loads a private key, asking for its passphrase if it is encrypted, and wraps it
+// with its OpenSSH certificate when one is configured or sits next to the key
+func (c *ConnectionConfig) loadSigner(m AuthMethod, user, addr string) (ssh.Signer, error) {
+	b, err := os.ReadFile(m.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load private key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := c.ask(AuthPrompt{Kind: "passphrase", Host: addr, User: user, Message: m.KeyFile})
+		if perr != nil {
+			return nil, perr
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(b, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", m.KeyFile, err)
+	}
+
+	certFile := m.CertFile
+	if certFile == "" {
+		if _, err := os.Stat(m.KeyFile + "-cert.pub"); err != nil {
+			return signer, nil
+		}
+		certFile = m.KeyFile + "-cert.pub"
+	}
+	certBytes, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", certFile, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an OpenSSH certificate", certFile)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s does not match key %s: %w", certFile, m.KeyFile, err)
+	}
+	return certSigner, nil
+}
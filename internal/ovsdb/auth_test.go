@@ -0,0 +1,296 @@
+package ovsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHServer accepts the password "secret", the keyboard-interactive answer "123" and
+// the authorized public key, and forwards direct-tcpip and direct-streamlocal channels
+type testSSHServer struct {
+	addr string
+	host string
+	port int
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func startSSHServer(t *testing.T, authorized ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+		PasswordCallback: func(_ ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		KeyboardInteractiveCallback: func(_ ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"OTP: "}, []bool{true})
+			if err != nil || len(answers) != 1 || answers[0] != "123" {
+				return nil, errors.New("wrong answer")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	s := &testSSHServer{addr: l.Addr().String(), host: host}
+	s.port, _ = strconv.Atoi(port)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn, config)
+		}
+	}()
+	t.Cleanup(s.dropAll)
+	return s
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go func() {
+		// Keepalives are answered, as OpenSSH does
+		for req := range reqs {
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+	for newChannel := range chans {
+		var network, addr string
+		switch newChannel.ChannelType() {
+		case "direct-tcpip":
+			var payload struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			network, addr = "tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+		case "direct-streamlocal@openssh.com":
+			var payload struct {
+				Path      string
+				Reserved0 string
+				Reserved1 uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			network, addr = "unix", payload.Path
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		remote, err := net.Dial(network, addr)
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			done := make(chan struct{}, 2)
+			go func() { io.Copy(channel, remote); done <- struct{}{} }()
+			go func() { io.Copy(remote, channel); done <- struct{}{} }()
+			<-done
+		}()
+	}
+}
+
+// dropAll closes every SSH connection, as a restarted server or a network outage would
+func (s *testSSHServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// writeUserKey writes a new private key, encrypted when passphrase is set, and returns its
+// path and public key
+func writeUserKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, key
+}
+
+// sshTestConfig returns a configuration for the server that trusts its host key, answers
+// prompts from answers and records them in asked
+func sshTestConfig(t *testing.T, s *testSSHServer, answers map[string]string, asked *[]string) ConnectionConfig {
+	t.Helper()
+	var mu sync.Mutex
+	return ConnectionConfig{
+		Host:           s.host,
+		Port:           s.port,
+		User:           "ovs",
+		KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts"),
+		SSHConfigFile:  filepath.Join(t.TempDir(), "none"),
+		ConfirmHostKey: func(HostKeyPrompt) bool { return true },
+		Prompt: func(p AuthPrompt) (string, error) {
+			mu.Lock()
+			*asked = append(*asked, p.Kind)
+			mu.Unlock()
+			answer, ok := answers[p.Kind]
+			if !ok {
+				return "", errors.New("no answer")
+			}
+			return answer, nil
+		},
+	}
+}
+
+func TestSSHAuthMethods(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	keyFile, key := writeUserKey(t, "")
+	encryptedFile, encryptedKey := writeUserKey(t, "hunter2")
+	otherFile, _ := writeUserKey(t, "")
+	answers := map[string]string{"passphrase": "hunter2", AuthPassword: "secret", AuthKeyboardInteractive: "123"}
+
+	tests := []struct {
+		name       string
+		authorized ssh.PublicKey
+		methods    []AuthMethod
+		asked      []string // prompts, in order
+		err        string   // part of the error, empty when authentication succeeds
+	}{
+		{"key", key, []AuthMethod{{Type: AuthPublicKey, KeyFile: keyFile}}, nil, ""},
+		{"encrypted key", encryptedKey, []AuthMethod{{Type: AuthPublicKey, KeyFile: encryptedFile}}, []string{"passphrase"}, ""},
+		{"password", nil, []AuthMethod{{Type: AuthPassword}}, []string{AuthPassword}, ""},
+		{"keyboard-interactive", nil, []AuthMethod{{Type: AuthKeyboardInteractive}}, []string{AuthKeyboardInteractive}, ""},
+		{"unauthorized key falls back to password", key, []AuthMethod{{Type: AuthPublicKey, KeyFile: otherFile}, {Type: AuthPassword}}, []string{AuthPassword}, ""},
+		{"unavailable agent is skipped", nil, []AuthMethod{{Type: AuthAgent}, {Type: AuthPassword}}, []string{AuthPassword}, ""},
+		{"only an unavailable agent", nil, []AuthMethod{{Type: AuthAgent}}, nil, "SSH_AUTH_SOCK is not set"},
+		{"unauthorized key", key, []AuthMethod{{Type: AuthPublicKey, KeyFile: otherFile}}, nil, "unable to authenticate"},
+		{"unknown method", nil, []AuthMethod{{Type: "hostbased"}}, nil, "unsupported auth method"},
+	}
+	for _, tt := range tests {
+		s := startSSHServer(t, tt.authorized)
+		var asked []string
+		config := sshTestConfig(t, s, answers, &asked)
+		config.Auth = tt.methods
+		client, err := config.dialSSH(context.Background())
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if client != nil {
+			client.Close()
+		}
+		if !reflect.DeepEqual(asked, tt.asked) {
+			t.Errorf("%s: asked for %v, want %v", tt.name, asked, tt.asked)
+		}
+	}
+}
+
+func TestAuthMethodsFor(t *testing.T) {
+	keyFile, _ := writeUserKey(t, "")
+	missing := filepath.Join(t.TempDir(), "id_missing")
+	password := []AuthMethod{{Type: AuthPassword}}
+	agent := []AuthMethod{{Type: AuthAgent}}
+
+	config := &ConnectionConfig{Auth: password, JumpAuth: map[string][]AuthMethod{"bastion": agent}}
+	if got := config.authMethodsFor("bastion", nil); !reflect.DeepEqual(got, agent) {
+		t.Errorf("jump host with its own methods: %v", got)
+	}
+	if got := config.authMethodsFor("other", nil); !reflect.DeepEqual(got, password) {
+		t.Errorf("jump host without its own methods: %v, want the target's", got)
+	}
+
+	legacy := &ConnectionConfig{KeyFile: keyFile}
+	if got := legacy.authMethodsFor("", nil); !reflect.DeepEqual(got, []AuthMethod{{Type: AuthPublicKey, KeyFile: keyFile}}) {
+		t.Errorf("legacy key file: %v", got)
+	}
+
+	// Without configuration: the agent when it runs, then the IdentityFiles that exist
+	defaults := &ConnectionConfig{}
+	t.Setenv("SSH_AUTH_SOCK", "/run/agent.sock")
+	want := []AuthMethod{{Type: AuthAgent}, {Type: AuthPublicKey, KeyFile: keyFile}}
+	if got := defaults.authMethodsFor("", []string{missing, keyFile}); !reflect.DeepEqual(got, want) {
+		t.Errorf("defaults: %v, want %v", got, want)
+	}
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if got := defaults.authMethodsFor("", []string{missing}); !reflect.DeepEqual(got, agent) {
+		t.Errorf("nothing usable: %v, want the agent so that its absence is reported", got)
+	}
+}
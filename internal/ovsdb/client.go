@@ -2,111 +2,265 @@ package ovsdb
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	ovsdbclient "github.com/ovn-kubernetes/libovsdb/client"
 	"github.com/ovn-kubernetes/libovsdb/model"
 	"github.com/ovn-kubernetes/libovsdb/ovsdb"
-	"github.com/ovn-kubernetes/libovsdb/ovsdb/serverdb"
 )
 
 // OVSDBClient handles dynamic OVSDB interactions without generated models
 type OVSDBClient struct {
-	client        ovsdbclient.Client
-	tunnel        *Tunnel
-	localEndpoint string
+	client    ovsdbclient.Client
+	membersMu sync.Mutex // guards the members' links and tlsConfig, which reconnect may set
+	members   []*memberLink
+	tlsConfig *tls.Config
+	follower  string // the chosen follower's endpoint, see ClusterOptions
+	dbName    string
+	endpoint  string // requested endpoint of the member in use, for state events
+	// file is the database file served in place of a server, see OpenFile. SeekFile swaps
+	// it while reads are served, so each read loads it once.
+	file atomic.Pointer[dbFile]
+
+	// ReadOnly, when set, makes Transact reject every transaction that could change the
+	// database; it must be set before connecting
+	ReadOnly bool
+	// Audit, when set, records every transaction sent through Transact that writes, or that
+	// ReadOnly rejected
+	Audit *AuditLog
+	// AuditUser is recorded as the user of the transactions; the OS user when empty
+	AuditUser string
+	// OnAuditError, when set, is told about transactions that could not be recorded
+	OnAuditError func(error)
+
+	// OnStateChange, when set, is called on every connection state change, including
+	// those of the background reconnect loop
+	OnStateChange func(StateEvent)
+	// OnTableUpdate, when set, receives the changes to tables monitored with MonitorTable
+	OnTableUpdate func(TableDelta)
+
+	stateMu sync.Mutex
+	state   string
+	stop    chan struct{}
+	lost    chan struct{} // signals the supervisor that a connection was lost
+	// mainDown is closed once libovsdb has torn down the main connection
+	mainDown chan struct{}
+
+	monitorMu sync.Mutex // serialises monitor requests
+	session   *rpcSession
+
+	pageMu  sync.Mutex
+	results map[string]*resultSet               // last select of each table, for GetTablePage
+	sorted  map[string]map[string]*sortedResult // sorted rows of each table, by sort order
+
+	undoMu  sync.Mutex
+	undo    []*UndoEntry // oldest first
+	undoSeq int
 }
 
-// Connect connects to OVSDB without a specific schema model
+// Connect connects to OVSDB without a specific schema model. Once connected, a lost
+// connection is re-established in the background until Disconnect is called.
 func (c *OVSDBClient) Connect(ctx context.Context, config ConnectionConfig, endpoint string, dbName string) error {
-	var localEndpoint string
-	var tunnel *Tunnel
-	var err error
-
-	if config.Host != "" {
-		tunnel, err = EstablishTunnel(config, endpoint)
-		if err != nil {
-			return fmt.Errorf("failed to establish tunnel: %w", err)
-		}
-		localEndpoint = tunnel.LocalEndpoint
-	} else {
-		localEndpoint = endpoint
+	return c.ConnectCluster(ctx, []Member{{Endpoint: endpoint, Config: config}}, dbName, ClusterOptions{})
+}
+
+// ConnectCluster connects to one of several members of a database. With more than one
+// member and no chosen follower, the Raft leader is used and followed across leadership
+// changes; standalone databases always count as leader.
+func (c *OVSDBClient) ConnectCluster(ctx context.Context, members []Member, dbName string, opts ClusterOptions) error {
+	if len(members) == 0 {
+		return fmt.Errorf("no endpoints provided")
+	}
+	endpoints := make([]string, len(members))
+	for i, m := range members {
+		endpoints[i] = m.Endpoint
 	}
+	c.endpoint = strings.Join(endpoints, ",")
+	c.setState(StateEvent{State: StateConnecting})
+	if err := c.connect(ctx, members, dbName, opts); err != nil {
+		c.setState(StateEvent{State: StateFailed, Err: err})
+		return err
+	}
+	c.endpoint = c.activeEndpoint()
+	c.stop = make(chan struct{})
+	c.lost = make(chan struct{}, 1)
+	c.mainDown = make(chan struct{})
+	go c.supervise(c.client, c.stop)
+	c.setState(StateEvent{State: StateConnected})
+	return nil
+}
 
+func (c *OVSDBClient) connect(ctx context.Context, members []Member, dbName string, opts ClusterOptions) error {
 	if dbName == "" {
 		dbName = "Open_vSwitch"
 	}
+	if err := checkTLS(members); err != nil {
+		return err
+	}
+
+	links := make([]*memberLink, 0, len(members))
+	stopAll := func() {
+		for _, link := range links {
+			link.stop()
+		}
+	}
+	var tlsConfig *tls.Config
+	var candidates []string
+	for _, m := range members {
+		link := &memberLink{Member: m}
+		links = append(links, link)
+		if err := link.establish(ctx); err != nil {
+			link.err = err
+			continue
+		}
+		// libovsdb takes a single TLS configuration, which checkTLS made sure every ssl:
+		// member shares
+		if tlsConfig == nil && strings.HasPrefix(link.localEndpoint, "ssl:") {
+			var err error
+			if tlsConfig, err = m.Config.TLS.clientConfig(ctx, link.localEndpoint); err != nil {
+				link.err = err
+				link.stop()
+				continue
+			}
+		}
+		if opts.Follower == "" || m.Endpoint == opts.Follower {
+			candidates = append(candidates, link.localEndpoint)
+		}
+	}
+	if len(candidates) == 0 {
+		stopAll()
+		if opts.Follower != "" && memberErrors(links) == "" {
+			return fmt.Errorf("follower %s is not one of the endpoints", opts.Follower)
+		}
+		return fmt.Errorf("failed to reach any endpoint: %s", memberErrors(links))
+	}
 
 	// We use a dummy model because libovsdb requires one to initialize.
 	// However, we won't use the cache or monitor features that rely on it.
 	// We'll use raw Transact/RPC calls.
 	dummyModel, err := model.NewClientDBModel(dbName, nil)
 	if err != nil {
-		if tunnel != nil {
-			tunnel.Stop()
-		}
+		stopAll()
 		return fmt.Errorf("failed to create dummy model: %w", err)
 	}
 
 	// Create OVSDB client
 	// We don't call MonitorAll here because we don't have a model to map to.
-	ovsdbClient, err := ovsdbclient.NewOVSDBClient(dummyModel, ovsdbclient.WithEndpoint(localEndpoint))
+	leaderOnly := opts.Follower == "" && len(members) > 1
+	clientOpts := clientOptions(tlsConfig, candidates...)
+	if leaderOnly {
+		clientOpts = append(clientOpts, ovsdbclient.WithLeaderOnly(true))
+	}
+	ovsdbClient, err := ovsdbclient.NewOVSDBClient(dummyModel, clientOpts...)
 	if err != nil {
-		if tunnel != nil {
-			tunnel.Stop()
-		}
+		stopAll()
 		return fmt.Errorf("failed to create OVSDB client: %w", err)
 	}
 
 	// Connect
 	if err := ovsdbClient.Connect(ctx); err != nil {
-		if tunnel != nil {
-			tunnel.Stop()
+		// Read the transport errors before stopping, they explain most failures
+		detail := memberErrors(links)
+		stopAll()
+		if detail != "" {
+			return fmt.Errorf("failed to connect to OVSDB: %w (%s)", err, detail)
 		}
 		return fmt.Errorf("failed to connect to OVSDB: %w", err)
 	}
 
 	c.client = ovsdbClient
-	c.tunnel = tunnel
-	c.localEndpoint = localEndpoint
+	c.membersMu.Lock()
+	c.members = links
+	c.tlsConfig = tlsConfig
+	c.membersMu.Unlock()
+	c.follower = opts.Follower
+	c.dbName = dbName
+	c.session = newRPCSession()
 	return nil
 }
 
-// Disconnect closes the connection
+// checkTLS rejects ssl: members with different TLS settings, since one libovsdb client
+// takes a single TLS configuration for all its endpoints
+func checkTLS(members []Member) error {
+	var first *Member
+	for i := range members {
+		m := &members[i]
+		if !strings.HasPrefix(m.Endpoint, "ssl:") {
+			continue
+		}
+		if first == nil {
+			first = m
+			continue
+		}
+		a, b := first.Config.TLS, m.Config.TLS
+		if (a == nil) != (b == nil) || (a != nil && *a != *b) {
+			return fmt.Errorf("endpoints %s and %s must use the same TLS settings", first.Endpoint, m.Endpoint)
+		}
+	}
+	return nil
+}
+
+// clientOptions returns the libovsdb options needed to reach the endpoints
+func clientOptions(tlsConfig *tls.Config, endpoints ...string) []ovsdbclient.Option {
+	var opts []ovsdbclient.Option
+	for _, endpoint := range endpoints {
+		opts = append(opts, ovsdbclient.WithEndpoint(endpoint))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, ovsdbclient.WithTLSConfig(tlsConfig))
+	}
+	return opts
+}
+
+// activeEndpoint returns the requested endpoint of the member the client is connected to
+func (c *OVSDBClient) activeEndpoint() string {
+	current := c.client.CurrentEndpoint()
+	c.membersMu.Lock()
+	defer c.membersMu.Unlock()
+	for _, link := range c.members {
+		if link.localEndpoint != "" && link.localEndpoint == current {
+			return link.Endpoint
+		}
+	}
+	return current
+}
+
+// Disconnect closes the connection and stops reconnecting
 func (c *OVSDBClient) Disconnect() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.closeSession()
+	c.resetPages()
 	if c.client != nil {
 		c.client.Disconnect()
 	}
-	if c.tunnel != nil {
-		c.tunnel.Stop()
+	c.membersMu.Lock()
+	for _, link := range c.members {
+		link.stop()
 	}
+	c.membersMu.Unlock()
+	c.file.Store(nil)
+	c.setState(StateEvent{State: StateDisconnected})
 }
 
 // ListDatabases returns a list of available database names
 func (c *OVSDBClient) ListDatabases(ctx context.Context) ([]string, error) {
-	if c.localEndpoint == "" {
-		return nil, fmt.Errorf("not connected")
+	if c.file.Load() != nil {
+		return []string{c.dbName}, nil
 	}
-
-	// Connect to _Server database to list databases
-	serverModel, err := serverdb.FullDatabaseModel()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create server model: %w", err)
+	if c.client == nil || !c.client.Connected() {
+		return nil, fmt.Errorf("not connected")
 	}
 
-	serverClient, err := ovsdbclient.NewOVSDBClient(serverModel, ovsdbclient.WithEndpoint(c.localEndpoint))
+	dbs, err := serverDatabases(ctx, c.client.CurrentEndpoint(), c.clientTLS())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create server client: %w", err)
-	}
-
-	if err := serverClient.Connect(ctx); err != nil {
-		return nil, fmt.Errorf("failed to connect to server db: %w", err)
-	}
-	defer serverClient.Disconnect()
-
-	var dbs []serverdb.Database
-	if err := serverClient.List(ctx, &dbs); err != nil {
-		return nil, fmt.Errorf("failed to list databases: %w", err)
+		return nil, err
 	}
 
 	names := make([]string, 0, len(dbs))
@@ -116,23 +270,50 @@ func (c *OVSDBClient) ListDatabases(ctx context.Context) ([]string, error) {
 	return names, nil
 }
 
-// GetSchema returns the schema for a specific database
+// GetSchema returns the schema for a specific database; an empty name means the connected one
 func (c *OVSDBClient) GetSchema(ctx context.Context, dbName string) (*ovsdb.DatabaseSchema, error) {
-	schema := c.client.Schema()
-	return &schema, nil
+	return c.schema(ctx, dbName)
 }
 
-// GetTableData fetches all rows from a table using a raw Select operation
-func (c *OVSDBClient) GetTableData(ctx context.Context, tableName string) ([]map[string]interface{}, error) {
-	// Construct a Select operation
-	op := ovsdb.Operation{
+// GetTableData fetches the rows of a table of any database using a raw Select operation,
+// limited to the rows matching every condition, with values that keep their OVSDB types
+func (c *OVSDBClient) GetTableData(ctx context.Context, dbName string, tableName string, conditions ...Condition) ([]TypedRow, error) {
+	table, err := c.tableSchema(ctx, dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	where, err := whereClause(table, conditions)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := c.selectRaw(ctx, dbName, ovsdb.Operation{
 		Op:    "select",
 		Table: tableName,
-		Where: []ovsdb.Condition{}, // Select all
+		Where: where,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return typedRows(table, raw), nil
+}
 
+// tableSchema returns the schema of a table of any database
+func (c *OVSDBClient) tableSchema(ctx context.Context, dbName string, tableName string) (*ovsdb.TableSchema, error) {
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	table := schema.Table(tableName)
+	if table == nil {
+		return nil, fmt.Errorf("table %s not found in %s", tableName, schema.Name)
+	}
+	return table, nil
+}
+
+// selectRaw runs a single select operation and returns its rows as received
+func (c *OVSDBClient) selectRaw(ctx context.Context, dbName string, op ovsdb.Operation) ([]ovsdb.Row, error) {
 	// Execute transaction
-	results, err := c.client.Transact(ctx, op)
+	results, err := c.Transact(ctx, dbName, op)
 	if err != nil {
 		return nil, fmt.Errorf("transaction failed: %w", err)
 	}
@@ -145,13 +326,7 @@ func (c *OVSDBClient) GetTableData(ctx context.Context, tableName string) ([]map
 		return nil, fmt.Errorf("server error: %s - %s", results[0].Error, results[0].Details)
 	}
 
-	// Convert rows to generic maps
-	rows := make([]map[string]interface{}, 0, len(results[0].Rows))
-	for _, row := range results[0].Rows {
-		rows = append(rows, normalizeRow(row))
-	}
-
-	return rows, nil
+	return results[0].Rows, nil
 }
 
 func normalizeRow(row ovsdb.Row) map[string]interface{} {
@@ -0,0 +1,156 @@
+package ovsdb
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ovn-kubernetes/libovsdb/database/inmemory"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb/serverdb"
+	"github.com/ovn-kubernetes/libovsdb/server"
+	libtest "github.com/ovn-kubernetes/libovsdb/test"
+)
+
+// startServer runs an in-memory Open_vSwitch database server on a unix socket for the
+// duration of the test and returns the socket's path
+func startServer(t *testing.T) string {
+	t.Helper()
+	dbModel, err := libtest.GetModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverModel, err := serverdb.FullDatabaseModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverDBModel, errs := model.NewDatabaseModel(serverdb.Schema(), serverModel)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	logger := logr.Discard()
+	db := inmemory.NewDatabase(map[string]model.ClientDBModel{
+		"Open_vSwitch": dbModel.Client(),
+		"_Server":      serverModel,
+	}, &logger)
+	srv, err := server.NewOvsdbServer(db, &logger, dbModel, serverDBModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "db.sock")
+	go func() {
+		_ = srv.Serve("unix", sock)
+	}()
+	for i := 0; !srv.Ready(); i++ {
+		if i == 100 {
+			t.Fatal("server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(srv.Close)
+	return sock
+}
+
+// connectServer connects a client to a server started with startServer
+func connectServer(t *testing.T, c *OVSDBClient, sock string) {
+	t.Helper()
+	if err := c.Connect(context.Background(), ConnectionConfig{}, "unix:"+sock, ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Disconnect)
+}
+
+// forward accepts connections on l and relays each to a new connection to addr, until the
+// test ends. The returned function drops the connections relayed so far, as an outage would.
+func forward(t *testing.T, l net.Listener, network, addr string) func() {
+	t.Helper()
+	var mu sync.Mutex
+	var conns []net.Conn
+	drop := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		conns = nil
+	}
+	t.Cleanup(func() {
+		l.Close()
+		drop()
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			remote, err := net.Dial(network, addr)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			mu.Lock()
+			conns = append(conns, conn, remote)
+			mu.Unlock()
+			go relay(conn, remote)
+		}
+	}()
+	return drop
+}
+
+// relay copies between two connections until either closes
+func relay(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyConn(a, b)
+	go copyConn(b, a)
+	<-done
+	a.Close()
+	b.Close()
+}
+
+func TestDatabases(t *testing.T) {
+	sock := startServer(t)
+	seedServer(t, sock, "standalone", true)
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+
+	names, err := c.ListDatabases(ctx)
+	if err != nil || len(names) != 1 || names[0] != "Open_vSwitch" {
+		t.Errorf("ListDatabases = %v, %v", names, err)
+	}
+	for _, name := range []string{"", "Open_vSwitch"} {
+		schema, err := c.GetSchema(ctx, name)
+		if err != nil || schema.Name != "Open_vSwitch" || schema.Tables["Bridge"].Columns == nil {
+			t.Errorf("schema of %q: %v", name, err)
+		}
+	}
+
+	// Other databases are read through the session connection with their own schema
+	schema, err := c.GetSchema(ctx, "_Server")
+	if err != nil || schema.Name != "_Server" {
+		t.Fatalf("schema of _Server: %+v, %v", schema, err)
+	}
+	if _, ok := schema.Tables["Bridge"]; ok {
+		t.Error("schema of _Server has the tables of Open_vSwitch")
+	}
+	rows, err := c.GetTableData(ctx, "_Server", "Database")
+	if err != nil || len(rows) != 1 || rows[0]["model"].Atom.Value != "standalone" {
+		t.Errorf("Database rows of _Server = %+v, %v", rows, err)
+	}
+	if _, err := c.GetTableData(ctx, "_Server", "Bridge"); err == nil {
+		t.Error("read of a table of another database succeeded")
+	}
+	if _, err := c.GetSchema(ctx, "OVN_Northbound"); err == nil {
+		t.Error("schema of a database the server does not have was returned")
+	}
+}
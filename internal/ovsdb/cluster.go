@@ -0,0 +1,346 @@
+package ovsdb
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	ovsdbclient "github.com/ovn-kubernetes/libovsdb/client"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb/serverdb"
+)
+
+// Member roles reported by ClusterStatus
+const (
+	RoleLeader       = "leader"
+	RoleFollower     = "follower"
+	RoleDisconnected = "disconnected" // clustered member that has lost contact with the cluster
+	RoleStandalone   = "standalone"
+	RoleRelay        = "relay"
+	RoleUnreachable  = "unreachable"
+)
+
+// Member is one server of a database, standalone or clustered, and the way to reach it
+type Member struct {
+	Endpoint string
+	Config   ConnectionConfig
+}
+
+// ClusterOptions selects which member of a clustered database is used
+type ClusterOptions struct {
+	// Follower is the endpoint to use whatever its role, for read-only browsing of a chosen
+	// member. When empty and several members are given, only the leader is used and the
+	// client moves to the new leader when leadership changes.
+	Follower string
+}
+
+// DatabaseStatus is a database's row in the _Server database of one server
+type DatabaseStatus struct {
+	Name      string
+	Model     string // "standalone", "clustered" or "relay"
+	Leader    bool
+	Connected bool
+	Cid       string // cluster ID, clustered databases only
+	Sid       string // server ID, clustered databases only
+	Index     int    // last Raft log index, clustered databases only
+}
+
+// MemberStatus describes one configured member as seen through its own _Server database.
+// The Raft term is not published in _Server, only through "ovs-appctl cluster/status",
+// so it is not reported.
+type MemberStatus struct {
+	Endpoint string
+	Role     string
+	Active   bool // whether the client is currently using this member
+	Database DatabaseStatus
+	Err      error
+}
+
+// memberLink is a member together with the local endpoint that reaches it
+type memberLink struct {
+	Member
+	tunnel        *Tunnel
+	localEndpoint string
+	hops          []string // how the tunnel reaches the member, for the audit log
+	err           error    // why the member could not be reached
+}
+
+// establish opens the member's transport, if any
+func (l *memberLink) establish(ctx context.Context) error {
+	transport := l.Config.transport()
+	if transport == nil {
+		l.localEndpoint = l.Endpoint
+		return nil
+	}
+	tunnel, err := transport.Establish(ctx, l.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to establish tunnel: %w", err)
+	}
+	l.tunnel = tunnel
+	l.localEndpoint = tunnel.LocalEndpoint
+	l.hops = l.route()
+	return nil
+}
+
+// route describes the way to the member: the relay command, or the resolved SSH jump
+// hosts followed by the SSH target
+func (l *memberLink) route() []string {
+	if cmd := l.Config.Command; cmd != nil {
+		return []string{strings.Join(append([]string{cmd.Command}, cmd.Args...), " ")}
+	}
+	hops, err := l.Config.hops()
+	if err != nil {
+		return []string{l.Config.Host}
+	}
+	route := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		if hop.user == "" {
+			route = append(route, hop.addr)
+			continue
+		}
+		route = append(route, hop.user+"@"+hop.addr)
+	}
+	return route
+}
+
+// tunnelErr reports the most recent failure of the member's transport
+func (l *memberLink) tunnelErr() error {
+	if l.tunnel == nil || l.tunnel.Err == nil {
+		return nil
+	}
+	return l.tunnel.Err()
+}
+
+// memberLinks returns a copy of the members' links, safe to read while reconnect
+// establishes them again
+func (c *OVSDBClient) memberLinks() []memberLink {
+	c.membersMu.Lock()
+	defer c.membersMu.Unlock()
+	links := make([]memberLink, len(c.members))
+	for i, link := range c.members {
+		links[i] = *link
+	}
+	return links
+}
+
+// clientTLS returns the TLS configuration of the ssl: members, nil until one is reached
+func (c *OVSDBClient) clientTLS() *tls.Config {
+	c.membersMu.Lock()
+	defer c.membersMu.Unlock()
+	return c.tlsConfig
+}
+
+// retryMembers establishes again the transports of members that could not be reached, and
+// adds them to the endpoints the client tries. libovsdb only accepts new settings while
+// disconnected, so it is called before reconnecting.
+func (c *OVSDBClient) retryMembers(ctx context.Context, client ovsdbclient.Client) {
+	c.membersMu.Lock()
+	var failed []*memberLink
+	for _, link := range c.members {
+		if link.localEndpoint == "" {
+			failed = append(failed, link)
+		}
+	}
+	c.membersMu.Unlock()
+
+	added := false
+	for _, link := range failed {
+		// The dial runs without the lock, on a link of its own
+		retry := &memberLink{Member: link.Member}
+		retry.err = retry.establish(ctx)
+		if retry.err == nil && (c.follower == "" || link.Endpoint == c.follower) {
+			if retry.err = c.useTLS(ctx, client, retry); retry.err != nil {
+				retry.stop()
+			} else {
+				added = true
+			}
+		}
+		c.membersMu.Lock()
+		*link = *retry
+		c.membersMu.Unlock()
+	}
+	if added {
+		client.UpdateEndpoints(c.candidates())
+	}
+}
+
+// useTLS gives the client the TLS configuration of a member established after it was
+// created, if it is the first ssl: member reached
+func (c *OVSDBClient) useTLS(ctx context.Context, client ovsdbclient.Client, link *memberLink) error {
+	if c.clientTLS() != nil || !strings.HasPrefix(link.localEndpoint, "ssl:") {
+		return nil
+	}
+	tlsConfig, err := link.Config.TLS.clientConfig(ctx, link.localEndpoint)
+	if err != nil {
+		return err
+	}
+	if err := client.SetOption(ovsdbclient.WithTLSConfig(tlsConfig)); err != nil {
+		return fmt.Errorf("failed to add %s: %w", link.Endpoint, err)
+	}
+	c.membersMu.Lock()
+	c.tlsConfig = tlsConfig
+	c.membersMu.Unlock()
+	return nil
+}
+
+// candidates returns the local endpoints of the reachable members the client may use
+func (c *OVSDBClient) candidates() []string {
+	c.membersMu.Lock()
+	defer c.membersMu.Unlock()
+	var endpoints []string
+	for _, link := range c.members {
+		if link.localEndpoint != "" && (c.follower == "" || link.Endpoint == c.follower) {
+			endpoints = append(endpoints, link.localEndpoint)
+		}
+	}
+	return endpoints
+}
+
+func (l *memberLink) stop() {
+	if l.tunnel != nil {
+		l.tunnel.Stop()
+		l.tunnel = nil
+	}
+	l.localEndpoint = ""
+	l.hops = nil
+}
+
+// ClusterStatus asks every configured member for the role it has in the current database
+func (c *OVSDBClient) ClusterStatus(ctx context.Context) ([]MemberStatus, error) {
+	if c.file.Load() != nil {
+		return nil, fmt.Errorf("cluster status is not available for database files")
+	}
+	if c.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	active := c.client.CurrentEndpoint()
+	links := c.memberLinks()
+	tlsConfig := c.clientTLS()
+	statuses := make([]MemberStatus, 0, len(links))
+	for _, link := range links {
+		status := MemberStatus{Endpoint: link.Endpoint, Role: RoleUnreachable}
+		if link.localEndpoint == "" {
+			status.Err = link.err
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Active = c.client.Connected() && link.localEndpoint == active
+		dbs, err := serverDatabases(ctx, link.localEndpoint, tlsConfig)
+		if err != nil {
+			status.Err = err
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Err = fmt.Errorf("database %s not found", c.dbName)
+		for _, db := range dbs {
+			if db.Name == c.dbName {
+				status.Database = db
+				status.Role = db.role()
+				status.Err = nil
+				break
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// role derives the member role from the database model and Raft state
+func (d DatabaseStatus) role() string {
+	switch d.Model {
+	case serverdb.DatabaseModelClustered:
+		switch {
+		case d.Leader:
+			return RoleLeader
+		case d.Connected:
+			return RoleFollower
+		default:
+			return RoleDisconnected
+		}
+	case serverdb.DatabaseModelRelay:
+		return RoleRelay
+	default:
+		return RoleStandalone
+	}
+}
+
+// serverDatabases reads the Database table of the _Server database. The rows are selected
+// directly because the client cache is only filled by a monitor.
+func serverDatabases(ctx context.Context, endpoint string, tlsConfig *tls.Config) ([]DatabaseStatus, error) {
+	serverModel, err := serverdb.FullDatabaseModel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server model: %w", err)
+	}
+
+	serverClient, err := ovsdbclient.NewOVSDBClient(serverModel, clientOptions(tlsConfig, endpoint)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server client: %w", err)
+	}
+
+	if err := serverClient.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to server db: %w", err)
+	}
+	defer serverClient.Disconnect()
+
+	results, err := serverClient.Transact(ctx, ovsdb.Operation{
+		Op:    ovsdb.OperationSelect,
+		Table: serverdb.DatabaseTable,
+		Where: []ovsdb.Condition{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results returned")
+	}
+	if results[0].Error != "" {
+		return nil, fmt.Errorf("server error: %s - %s", results[0].Error, results[0].Details)
+	}
+
+	dbs := make([]DatabaseStatus, 0, len(results[0].Rows))
+	for _, row := range results[0].Rows {
+		db := DatabaseStatus{}
+		db.Name, _ = row["name"].(string)
+		db.Model, _ = row["model"].(string)
+		db.Leader, _ = row["leader"].(bool)
+		db.Connected, _ = row["connected"].(bool)
+		if uuid, ok := optionalValue(row["cid"]).(ovsdb.UUID); ok {
+			db.Cid = uuid.GoUUID
+		}
+		if uuid, ok := optionalValue(row["sid"]).(ovsdb.UUID); ok {
+			db.Sid = uuid.GoUUID
+		}
+		if index, ok := optionalValue(row["index"]).(float64); ok {
+			db.Index = int(index)
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
+
+// optionalValue unwraps an optional column, which arrives either as a bare value or as a
+// set of zero or one element
+func optionalValue(v interface{}) interface{} {
+	set, ok := v.(ovsdb.OvsSet)
+	if !ok {
+		return v
+	}
+	if len(set.GoSet) == 0 {
+		return nil
+	}
+	return set.GoSet[0]
+}
+
+// memberErrors summarises why members could not be reached
+func memberErrors(links []*memberLink) string {
+	var errs []string
+	for _, link := range links {
+		if err := link.err; err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", link.Endpoint, err))
+		} else if err := link.tunnelErr(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", link.Endpoint, err))
+		}
+	}
+	return strings.Join(errs, "; ")
+}
@@ -0,0 +1,149 @@
+package ovsdb
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// seedServer records the Open_vSwitch database in the _Server database of a server started
+// with startServer, as a standalone server or a member of a cluster would
+func seedServer(t *testing.T, sock string, model string, leader bool) {
+	t.Helper()
+	row := ovsdb.Row{"name": "Open_vSwitch", "model": model, "leader": leader, "connected": true}
+	if model == "clustered" {
+		row["sid"] = ovsdb.UUID{GoUUID: map[bool]string{true: "3c5b9d3e-47a2-4c4e-8f49-2b1d5e7a0c01", false: "3c5b9d3e-47a2-4c4e-8f49-2b1d5e7a0c02"}[leader]}
+	}
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	defer c.Disconnect()
+	results, err := c.Transact(context.Background(), "_Server", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Database", Row: row})
+	if err != nil || results[0].Error != "" {
+		t.Fatalf("failed to seed _Server: %v %+v", err, results)
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	a := &TLSConfig{CACert: "/etc/ovn/ca.pem"}
+	b := &TLSConfig{CACert: "/etc/ovn/other-ca.pem"}
+	tests := []struct {
+		name    string
+		members []Member
+		ok      bool
+	}{
+		{"plain", []Member{{Endpoint: "tcp:10.0.0.1:6641"}, {Endpoint: "unix:/run/db.sock"}}, true},
+		{"same settings", []Member{{Endpoint: "ssl:10.0.0.1:6641", Config: ConnectionConfig{TLS: a}}, {Endpoint: "ssl:10.0.0.2:6641", Config: ConnectionConfig{TLS: &TLSConfig{CACert: "/etc/ovn/ca.pem"}}}}, true},
+		{"different settings", []Member{{Endpoint: "ssl:10.0.0.1:6641", Config: ConnectionConfig{TLS: a}}, {Endpoint: "ssl:10.0.0.2:6641", Config: ConnectionConfig{TLS: b}}}, false},
+		{"settings on one", []Member{{Endpoint: "ssl:10.0.0.1:6641", Config: ConnectionConfig{TLS: a}}, {Endpoint: "ssl:10.0.0.2:6641"}}, false},
+		{"tcp member without settings", []Member{{Endpoint: "ssl:10.0.0.1:6641", Config: ConnectionConfig{TLS: a}}, {Endpoint: "tcp:10.0.0.2:6641"}}, true},
+	}
+	for _, tt := range tests {
+		if err := checkTLS(tt.members); (err == nil) != tt.ok {
+			t.Errorf("%s: checkTLS returned %v", tt.name, err)
+		}
+	}
+}
+
+func TestConnectClusterLeader(t *testing.T) {
+	follower, leader := startServer(t), startServer(t)
+	seedServer(t, follower, "clustered", false)
+	seedServer(t, leader, "clustered", true)
+	members := []Member{{Endpoint: "unix:" + follower}, {Endpoint: "unix:" + leader}}
+
+	c := &OVSDBClient{}
+	if err := c.ConnectCluster(context.Background(), members, "", ClusterOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	statuses, err := c.ClusterStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		role   string
+		active bool
+	}{{RoleFollower, false}, {RoleLeader, true}}
+	for i, status := range statuses {
+		if status.Err != nil || status.Role != want[i].role || status.Active != want[i].active {
+			t.Errorf("member %s: %+v, want role %s active %v", status.Endpoint, status, want[i].role, want[i].active)
+		}
+	}
+
+	// A chosen follower is used whatever its role
+	f := &OVSDBClient{}
+	if err := f.ConnectCluster(context.Background(), members, "", ClusterOptions{Follower: "unix:" + follower}); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Disconnect()
+	statuses, err = f.ClusterStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Active || statuses[1].Active {
+		t.Errorf("connection to the follower reported %+v", statuses)
+	}
+
+	err = (&OVSDBClient{}).ConnectCluster(context.Background(), members, "", ClusterOptions{Follower: "unix:/run/other.sock"})
+	if err == nil || !strings.Contains(err.Error(), "is not one of the endpoints") {
+		t.Errorf("unknown follower returned %v", err)
+	}
+}
+
+func TestRetryMembers(t *testing.T) {
+	sock := startServer(t)
+	seedServer(t, sock, "standalone", true)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := forward(t, l, "unix", sock)
+	// The second member's relay command does not exist yet
+	relay := filepath.Join(t.TempDir(), "relay")
+	members := []Member{
+		{Endpoint: "tcp:" + l.Addr().String()},
+		{Endpoint: "unix:" + sock, Config: ConnectionConfig{Command: &CommandConfig{Command: relay, Args: []string{"{address}"}, Env: []string{"OVSDB_TEST_RELAY=unix"}}}},
+	}
+
+	states := make(chan StateEvent, 100)
+	c := &OVSDBClient{OnStateChange: func(event StateEvent) { states <- event }}
+	ctx := context.Background()
+	if err := c.ConnectCluster(ctx, members, "", ClusterOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	waitState(t, states, StateConnected)
+	statuses, err := c.ClusterStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := statuses[0]; s.Role != RoleStandalone || !s.Active || s.Err != nil {
+		t.Errorf("reachable member reported %+v", s)
+	}
+	if s := statuses[1]; s.Role != RoleUnreachable || s.Err == nil {
+		t.Errorf("member without its relay command reported %+v", s)
+	}
+
+	// Once the command exists, the member is reached again on the next reconnect, and the
+	// client can use it while the first member is down
+	if err := os.Symlink(os.Args[0], relay); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	drop()
+	waitState(t, states, StateConnected)
+	statuses, err = c.ClusterStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := statuses[1]; s.Role != RoleStandalone || !s.Active || s.Err != nil {
+		t.Errorf("member reached on reconnect reported %+v", s)
+	}
+	if _, err := c.GetTableData(ctx, "", "Bridge"); err != nil {
+		t.Errorf("read through the member reached on reconnect failed: %v", err)
+	}
+}
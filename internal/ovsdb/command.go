@@ -0,0 +1,232 @@
+package ovsdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// stderrTailSize is how much of a relay command's stderr is kept for error reports
+	stderrTailSize = 4096
+	// exitWaitTimeout bounds the wait for a command to exit once its output has ended
+	exitWaitTimeout = 2 * time.Second
+)
+
+// CommandConfig runs a local command whose stdin and stdout carry the OVSDB JSON-RPC stream, e.g.
+// "kubectl exec -i ovnkube-db-0 -- socat - UNIX-CONNECT:/var/run/ovn/ovnnb_db.sock".
+// A new instance of the command is started for every connection. In Args, "{endpoint}" is
+// replaced with the remote endpoint and "{address}" with its address without the scheme.
+type CommandConfig struct {
+	Command string
+	Args    []string
+	Env     []string // extra KEY=value entries added to the inherited environment
+	Dir     string
+}
+
+// Establish exposes the command as a local TCP endpoint; "ssl:" endpoints keep TLS end to end
+func (c *CommandConfig) Establish(ctx context.Context, remoteEndpoint string) (*Tunnel, error) {
+	if c.Command == "" {
+		return nil, fmt.Errorf("no command configured")
+	}
+	path, err := exec.LookPath(c.Command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find command %s: %w", c.Command, err)
+	}
+
+	relay := &commandRelay{
+		path:   path,
+		args:   expandCommandArgs(c.Args, remoteEndpoint),
+		env:    append(os.Environ(), c.Env...),
+		dir:    c.Dir,
+		active: make(map[*commandStream]bool),
+	}
+	tunnel, err := establishTCPTunnel(relay.start, remoteEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	stop := tunnel.Stop
+	tunnel.Stop = func() {
+		stop()
+		relay.closeAll()
+	}
+	tunnel.Err = relay.lastError
+	return tunnel, nil
+}
+
+func expandCommandArgs(args []string, remoteEndpoint string) []string {
+	address := remoteEndpoint
+	if idx := strings.Index(remoteEndpoint, ":"); idx >= 0 {
+		address = remoteEndpoint[idx+1:]
+	}
+	replacer := strings.NewReplacer("{endpoint}", remoteEndpoint, "{address}", address)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// commandRelay starts command instances and remembers how the last one failed
+type commandRelay struct {
+	path string
+	args []string
+	env  []string
+	dir  string
+
+	mu      sync.Mutex
+	active  map[*commandStream]bool
+	lastErr error
+}
+
+// start launches one instance of the command and returns its stdio as a stream
+func (r *commandRelay) start() (io.ReadWriteCloser, error) {
+	cmd := exec.Command(r.path, r.args...)
+	cmd.Env = r.env
+	cmd.Dir = r.dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// A plain pipe rather than StdoutPipe, since Wait closes the latter before it is drained
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	stderr := &tailBuffer{limit: stderrTailSize}
+	cmd.Stderr = stderr
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		r.setLastError(fmt.Errorf("failed to start %s: %w", r.path, err))
+		return nil, err
+	}
+
+	stream := &commandStream{cmd: cmd, stdin: stdin, stdout: stdout, exited: make(chan struct{})}
+	r.mu.Lock()
+	r.active[stream] = true
+	r.mu.Unlock()
+	go func() {
+		defer close(stream.exited)
+		err := cmd.Wait()
+		r.mu.Lock()
+		closed := !r.active[stream]
+		delete(r.active, stream)
+		r.mu.Unlock()
+		// Exits caused by our own Close are not failures
+		if err != nil && !closed {
+			if tail := strings.TrimSpace(stderr.String()); tail != "" {
+				err = fmt.Errorf("%w: %s", err, tail)
+			}
+			r.setLastError(fmt.Errorf("command %s exited: %w", r.path, err))
+		}
+	}()
+	return &relayStream{commandStream: stream, relay: r}, nil
+}
+
+func (r *commandRelay) setLastError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+}
+
+func (r *commandRelay) lastError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+func (r *commandRelay) closeAll() {
+	r.mu.Lock()
+	streams := make([]*commandStream, 0, len(r.active))
+	for stream := range r.active {
+		streams = append(streams, stream)
+		delete(r.active, stream)
+	}
+	r.mu.Unlock()
+	for _, stream := range streams {
+		stream.Close()
+	}
+}
+
+// commandStream reads the command's stdout and writes its stdin
+type commandStream struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	stdout    io.ReadCloser
+	exited    chan struct{} // closed once the exit status has been recorded
+	closeOnce sync.Once
+}
+
+// Read returns the command's output. At end of output it briefly waits for the command to
+// exit, so its failure is recorded before the caller reacts to the closed stream.
+func (s *commandStream) Read(p []byte) (int, error) {
+	n, err := s.stdout.Read(p)
+	if err == io.EOF {
+		select {
+		case <-s.exited:
+		case <-time.After(exitWaitTimeout):
+		}
+	}
+	return n, err
+}
+
+func (s *commandStream) Write(p []byte) (int, error) { return s.stdin.Write(p) }
+
+// Close stops the command; the process is killed rather than waited on, since relays such as
+// socat or kubectl exec do not always exit when their stdin closes
+func (s *commandStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.stdin.Close()
+		s.stdout.Close()
+		if s.cmd.Process != nil {
+			s.cmd.Process.Kill()
+		}
+	})
+	return nil
+}
+
+// relayStream unregisters the stream from its relay when the local side closes it
+type relayStream struct {
+	*commandStream
+	relay *commandRelay
+}
+
+func (s *relayStream) Close() error {
+	s.relay.mu.Lock()
+	delete(s.relay.active, s.commandStream)
+	s.relay.mu.Unlock()
+	return s.commandStream.Close()
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = b.buf[len(b.buf)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,156 @@
+package ovsdb
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary stand in for a relay command. With OVSDB_TEST_RELAY=echo it
+// prints its arguments on a line and then copies stdin to stdout; with fail it writes to
+// stderr and exits with status 3; with unix it relays stdin and stdout to the unix socket
+// given as argument.
+func TestMain(m *testing.M) {
+	switch os.Getenv("OVSDB_TEST_RELAY") {
+	case "echo":
+		fmt.Println(strings.Join(os.Args[1:], " "))
+		io.Copy(os.Stdout, os.Stdin)
+		os.Exit(0)
+	case "fail":
+		fmt.Fprintln(os.Stderr, "relay: connection refused")
+		os.Exit(3)
+	case "unix":
+		conn, err := net.Dial("unix", os.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		go io.Copy(conn, os.Stdin)
+		io.Copy(os.Stdout, conn)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func dialTunnel(t *testing.T, tunnel *Tunnel) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(tunnel.LocalEndpoint, "tcp:"))
+	if err != nil {
+		t.Fatalf("failed to dial %s: %v", tunnel.LocalEndpoint, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return conn
+}
+
+func TestCommandTransportRelaysStream(t *testing.T) {
+	cfg := &CommandConfig{
+		Command: os.Args[0],
+		Args:    []string{"{endpoint}", "{address}"},
+		Env:     []string{"OVSDB_TEST_RELAY=echo"},
+	}
+	tunnel, err := cfg.Establish(context.Background(), "unix:/var/run/ovn/ovnnb_db.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Stop()
+	if !strings.HasPrefix(tunnel.LocalEndpoint, "tcp:") {
+		t.Fatalf("local endpoint %s is not tcp", tunnel.LocalEndpoint)
+	}
+
+	// Every connection gets its own instance of the command
+	for i := 0; i < 2; i++ {
+		conn := dialTunnel(t, tunnel)
+		reader := bufio.NewReader(conn)
+		args, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "unix:/var/run/ovn/ovnnb_db.sock /var/run/ovn/ovnnb_db.sock\n"; args != want {
+			t.Fatalf("relay got arguments %q, want %q", args, want)
+		}
+		request := fmt.Sprintf(`{"method":"echo","params":[%d],"id":%d}`+"\n", i, i)
+		if _, err := io.WriteString(conn, request); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != request {
+			t.Fatalf("relay sent back %q, want %q", reply, request)
+		}
+	}
+	if err := tunnel.Err(); err != nil {
+		t.Fatalf("healthy relay reported %v", err)
+	}
+}
+
+func TestCommandTransportReportsFailure(t *testing.T) {
+	cfg := &CommandConfig{Command: os.Args[0], Env: []string{"OVSDB_TEST_RELAY=fail"}}
+	tunnel, err := cfg.Establish(context.Background(), "tcp:10.0.0.1:6641")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Stop()
+
+	conn := dialTunnel(t, tunnel)
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatal(err)
+	}
+	// The exit is recorded before the stream ends, give or take the copy to the socket
+	deadline := time.Now().Add(5 * time.Second)
+	for tunnel.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	err = tunnel.Err()
+	if err == nil {
+		t.Fatal("failed relay reported no error")
+	}
+	for _, want := range []string{"exit status 3", "relay: connection refused"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestCommandTransportMissingCommand(t *testing.T) {
+	cfg := &CommandConfig{Command: "ovsdb-viewer-no-such-relay"}
+	if _, err := cfg.Establish(context.Background(), "tcp:10.0.0.1:6641"); err == nil || !strings.Contains(err.Error(), "failed to find command") {
+		t.Fatalf("got %v, want a failure to find the command", err)
+	}
+}
+
+func TestExpandCommandArgs(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		args     []string
+		want     []string
+	}{
+		{"unix:/run/db.sock", []string{"exec", "--", "socat", "-", "UNIX-CONNECT:{address}"}, []string{"exec", "--", "socat", "-", "UNIX-CONNECT:/run/db.sock"}},
+		{"tcp:10.0.0.1:6641", []string{"{endpoint}", "{address}"}, []string{"tcp:10.0.0.1:6641", "10.0.0.1:6641"}},
+		{"ssl:[::1]:6641", []string{"-e", "{endpoint}"}, []string{"-e", "ssl:[::1]:6641"}},
+		{"tcp:10.0.0.1:6641", nil, []string{}},
+	}
+	for _, tt := range tests {
+		got := expandCommandArgs(tt.args, tt.endpoint)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("expandCommandArgs(%q, %s) = %q, want %q", tt.args, tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestTailBufferKeepsLastBytes(t *testing.T) {
+	b := &tailBuffer{limit: 8}
+	io.WriteString(b, "first line\n")
+	io.WriteString(b, "tail")
+	if got, want := b.String(), "ine\ntail"; got != want {
+		t.Fatalf("got %q, want the last 8 bytes %q", got, want)
+	}
+}
@@ -0,0 +1,686 @@
+package ovsdb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// Magic strings that start the header of every record of a database file: ovsdb-server
+// writes standalone databases as "OVSDB JSON" logs and clustered ones as "CLUSTER" logs
+const (
+	standaloneMagic = "OVSDB JSON"
+	clusteredMagic  = "CLUSTER"
+)
+
+// logTransaction is one transaction of a database file. A transaction that carries a
+// schema starts the database over: its rows are the whole contents, as after a conversion
+// or in a snapshot.
+type logTransaction struct {
+	schema  *ovsdb.DatabaseSchema
+	tables  map[string]map[string]json.RawMessage // table → uuid → row, null for a deleted row
+	date    time.Time                             // zero when not recorded
+	comment string
+	isDiff  bool   // modified rows carry the difference to the old row rather than new values
+	index   uint64 // Raft log index; 0 in standalone files
+}
+
+// dbLog is the content of a database file
+type dbLog struct {
+	clustered    bool
+	serverID     string           // Raft server id of a clustered file
+	transactions []logTransaction // in commit order; the first one carries the schema
+	warning      string           // trouble at the end of the file, such as a record cut short
+
+	// checkpoints hold the database as of every checkpointInterval transactions, by
+	// number of transactions applied, so that seeking does not replay the whole file
+	checkpointMu sync.Mutex
+	checkpoints  map[int]*fileState
+
+	summariesOnce sync.Once // FileTransactions replays the log once
+	summaries     []FileTransaction
+	summariesErr  error
+}
+
+// checkpointInterval is how many transactions apart replay keeps checkpoints
+const checkpointInterval = 1000
+
+// readDBFile reads a standalone or clustered database file. A file whose end is damaged,
+// as after a crash while writing, is read up to the last good record.
+func readDBFile(path string) (*dbLog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	magic, records, warning, err := readRecords(bufio.NewReader(f), info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		// The first record is damaged, so there is not even a schema to read up to
+		return nil, fmt.Errorf("failed to read %s: no complete record: %s", path, warning)
+	}
+	var log *dbLog
+	if magic == clusteredMagic {
+		log, err = parseClusteredLog(records)
+	} else {
+		log, err = parseStandaloneLog(records)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if log.warning == "" {
+		log.warning = warning
+	}
+	return log, nil
+}
+
+// readRecords splits a file into its records. Each is a header line
+// "<magic> <length> <sha1>" followed by length bytes of JSON, which the SHA-1 covers.
+// A length beyond the size of the file is bad rather than a record cut short, so a damaged
+// header cannot make it allocate more than the file holds.
+func readRecords(r *bufio.Reader, size int64) (string, []json.RawMessage, string, error) {
+	var magic string
+	var records []json.RawMessage
+	var offset int64
+	for {
+		header, err := r.ReadString('\n')
+		offset += int64(len(header))
+		if err == io.EOF && header == "" {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return "", nil, "", err
+		}
+		fields := strings.Fields(header)
+		var recordMagic string
+		if len(fields) >= 3 {
+			recordMagic = strings.Join(fields[:len(fields)-2], " ")
+		}
+		if recordMagic != standaloneMagic && recordMagic != clusteredMagic || magic != "" && recordMagic != magic {
+			if magic == "" {
+				return "", nil, "", fmt.Errorf("not an OVSDB database file")
+			}
+			return magic, records, fmt.Sprintf("record %d has a bad header; the rest of the file is ignored", len(records)+1), nil
+		}
+		magic = recordMagic
+		length, err := strconv.Atoi(fields[len(fields)-2])
+		if err != nil || length < 0 || int64(length) > size-offset {
+			return magic, records, fmt.Sprintf("record %d has a bad length; the rest of the file is ignored", len(records)+1), nil
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return magic, records, fmt.Sprintf("record %d is cut short; the rest of the file is ignored", len(records)+1), nil
+		}
+		offset += int64(length)
+		sum := sha1.Sum(data)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), fields[len(fields)-1]) {
+			return magic, records, fmt.Sprintf("record %d has a bad checksum; the rest of the file is ignored", len(records)+1), nil
+		}
+		records = append(records, bytes.TrimSpace(data))
+	}
+	if magic == "" {
+		return "", nil, "", fmt.Errorf("file is empty")
+	}
+	return magic, records, "", nil
+}
+
+// parseStandaloneLog reads a standalone file: the schema, then one record per transaction
+func parseStandaloneLog(records []json.RawMessage) (*dbLog, error) {
+	var schema ovsdb.DatabaseSchema
+	if err := json.Unmarshal(records[0], &schema); err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	log := &dbLog{transactions: []logTransaction{{schema: &schema}}}
+	for i, record := range records[1:] {
+		tx, err := parseTransaction(record)
+		if err != nil {
+			// Keep what was read so far, like ovsdb-server does with a damaged file
+			log.warning = fmt.Sprintf("record %d: %v; the rest of the file is ignored", i+2, err)
+			break
+		}
+		log.transactions = append(log.transactions, tx)
+	}
+	return log, nil
+}
+
+// raftHeader is the first record of a clustered file. prev_data, when present, is a
+// snapshot of the database as of prev_index.
+type raftHeader struct {
+	ServerID  string          `json:"server_id"`
+	PrevIndex uint64          `json:"prev_index"`
+	PrevData  json.RawMessage `json:"prev_data"`
+}
+
+// raftRecord is any later record of a clustered file. Only log entries, which have an
+// index, matter here; votes, leader notes and commit indexes are skipped.
+type raftRecord struct {
+	Index *uint64         `json:"index"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// parseClusteredLog reads a clustered file: a header with the last snapshot, then the Raft
+// log entries since. An entry for an index already written replaces it and every entry
+// after it, as happens when a new leader overrides entries that were never committed.
+// Every remaining entry is applied, as ovsdb-tool cluster-to-standalone does.
+func parseClusteredLog(records []json.RawMessage) (*dbLog, error) {
+	var header raftHeader
+	if err := json.Unmarshal(records[0], &header); err != nil {
+		return nil, fmt.Errorf("failed to decode Raft header: %w", err)
+	}
+	if header.ServerID == "" {
+		return nil, fmt.Errorf("missing Raft header")
+	}
+	log := &dbLog{clustered: true, serverID: header.ServerID}
+	if len(header.PrevData) > 0 && !isNull(header.PrevData) {
+		tx, err := parseEntryData(header.PrevData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+		tx.index = header.PrevIndex
+		log.transactions = append(log.transactions, tx)
+	}
+
+	type entry struct {
+		index uint64
+		data  json.RawMessage
+	}
+	var entries []entry
+	for i, record := range records[1:] {
+		var r raftRecord
+		if err := json.Unmarshal(record, &r); err != nil {
+			log.warning = fmt.Sprintf("record %d: %v; the rest of the file is ignored", i+2, err)
+			break
+		}
+		if r.Index == nil {
+			continue
+		}
+		for len(entries) > 0 && entries[len(entries)-1].index >= *r.Index {
+			entries = entries[:len(entries)-1]
+		}
+		entries = append(entries, entry{index: *r.Index, data: r.Data})
+	}
+
+	for _, e := range entries {
+		// Entries that change the cluster membership carry no data
+		if len(e.data) == 0 || isNull(e.data) {
+			continue
+		}
+		tx, err := parseEntryData(e.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode log entry %d: %w", e.index, err)
+		}
+		if tx.schema == nil && tx.tables == nil {
+			continue
+		}
+		tx.index = e.index
+		log.transactions = append(log.transactions, tx)
+	}
+	if len(log.transactions) == 0 || log.transactions[0].schema == nil {
+		return nil, fmt.Errorf("no schema found")
+	}
+	return log, nil
+}
+
+// parseEntryData decodes the data of a Raft entry or snapshot: a schema, or null when it
+// does not change, and a transaction, or null
+func parseEntryData(data json.RawMessage) (logTransaction, error) {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil || len(pair) != 2 {
+		return logTransaction{}, fmt.Errorf("expected a [schema, data] pair")
+	}
+	var tx logTransaction
+	if !isNull(pair[1]) {
+		var err error
+		if tx, err = parseTransaction(pair[1]); err != nil {
+			return logTransaction{}, err
+		}
+	}
+	if !isNull(pair[0]) {
+		var schema ovsdb.DatabaseSchema
+		if err := json.Unmarshal(pair[0], &schema); err != nil {
+			return logTransaction{}, fmt.Errorf("failed to decode schema: %w", err)
+		}
+		tx.schema = &schema
+	}
+	return tx, nil
+}
+
+// parseTransaction decodes a transaction record: an object of tables, each an object of
+// rows keyed by uuid, plus the "_date", "_comment" and "_is_diff" members
+func parseTransaction(data json.RawMessage) (logTransaction, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return logTransaction{}, fmt.Errorf("failed to decode transaction: %w", err)
+	}
+	tx := logTransaction{tables: make(map[string]map[string]json.RawMessage)}
+	for name, value := range members {
+		switch name {
+		case "_date":
+			var ms float64
+			if err := json.Unmarshal(value, &ms); err != nil {
+				return logTransaction{}, fmt.Errorf("failed to decode _date: %w", err)
+			}
+			tx.date = time.UnixMilli(int64(ms))
+		case "_comment":
+			if err := json.Unmarshal(value, &tx.comment); err != nil {
+				return logTransaction{}, fmt.Errorf("failed to decode _comment: %w", err)
+			}
+		case "_is_diff":
+			if err := json.Unmarshal(value, &tx.isDiff); err != nil {
+				return logTransaction{}, fmt.Errorf("failed to decode _is_diff: %w", err)
+			}
+		default:
+			if strings.HasPrefix(name, "_") {
+				continue
+			}
+			var rows map[string]json.RawMessage
+			if err := json.Unmarshal(value, &rows); err != nil {
+				return logTransaction{}, fmt.Errorf("failed to decode rows of %s: %w", name, err)
+			}
+			tx.tables[name] = rows
+		}
+	}
+	return tx, nil
+}
+
+func isNull(data json.RawMessage) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
+
+// fileState is the content of a database file as of one of its transactions
+type fileState struct {
+	schema *ovsdb.DatabaseSchema
+	tables map[string]map[string]ovsdb.Row // table → uuid → row
+}
+
+// replay builds the database as of the first n transactions of the log, or all of them
+// when n is negative
+func (l *dbLog) replay(n int) (*fileState, error) {
+	return l.replayFrom(nil, 0, n)
+}
+
+// replayFrom builds the database as of the first n transactions of the log, or all of them
+// when n is negative, starting from base, the database as of transaction at, or from the
+// last checkpoint before n when that is closer. Neither base nor the checkpoints change.
+func (l *dbLog) replayFrom(base *fileState, at, n int) (*fileState, error) {
+	if n < 0 || n > len(l.transactions) {
+		n = len(l.transactions)
+	}
+	if base == nil || at > n {
+		base, at = nil, 0
+	}
+	l.checkpointMu.Lock()
+	for i := n - n%checkpointInterval; i > at; i -= checkpointInterval {
+		if checkpoint, ok := l.checkpoints[i]; ok {
+			base, at = checkpoint, i
+			break
+		}
+	}
+	l.checkpointMu.Unlock()
+
+	state := &fileState{}
+	if base != nil {
+		state = base.clone()
+	}
+	for i := at; i < n; i++ {
+		if _, err := state.apply(&l.transactions[i]); err != nil {
+			return nil, fmt.Errorf("failed to replay transaction %d: %w", i+1, err)
+		}
+		if (i+1)%checkpointInterval == 0 {
+			l.checkpoint(i+1, state)
+		}
+	}
+	if state.schema == nil {
+		return nil, fmt.Errorf("no schema found")
+	}
+	return state, nil
+}
+
+// checkpoint keeps a copy of the database as of the first n transactions
+func (l *dbLog) checkpoint(n int, state *fileState) {
+	l.checkpointMu.Lock()
+	defer l.checkpointMu.Unlock()
+	if _, ok := l.checkpoints[n]; ok {
+		return
+	}
+	if l.checkpoints == nil {
+		l.checkpoints = make(map[int]*fileState)
+	}
+	l.checkpoints[n] = state.clone()
+}
+
+// clone copies the state. The rows are shared, since apply replaces a row rather than
+// changing it.
+func (s *fileState) clone() *fileState {
+	tables := make(map[string]map[string]ovsdb.Row, len(s.tables))
+	for name, rows := range s.tables {
+		copied := make(map[string]ovsdb.Row, len(rows))
+		for uuid, row := range rows {
+			copied[uuid] = row
+		}
+		tables[name] = copied
+	}
+	return &fileState{schema: s.schema, tables: tables}
+}
+
+// apply applies a transaction to the state and counts the rows it changed in each table.
+// Rows are created with the default value of every column, and a diff transaction changes
+// sets and maps by the elements it lists.
+func (s *fileState) apply(tx *logTransaction) ([]TableChanges, error) {
+	if tx.schema != nil {
+		s.schema = tx.schema
+		s.tables = make(map[string]map[string]ovsdb.Row, len(tx.schema.Tables))
+		for name := range tx.schema.Tables {
+			s.tables[name] = make(map[string]ovsdb.Row)
+		}
+	}
+	if s.schema == nil {
+		return nil, fmt.Errorf("transaction before the schema")
+	}
+	changes := make([]TableChanges, 0, len(tx.tables))
+	for tableName, rows := range tx.tables {
+		table := s.schema.Table(tableName)
+		if table == nil {
+			return nil, fmt.Errorf("table %s not found in %s", tableName, s.schema.Name)
+		}
+		counts := TableChanges{Table: tableName}
+		for uuid, data := range rows {
+			row, ok := s.tables[tableName][uuid]
+			if isNull(data) {
+				if ok {
+					delete(s.tables[tableName], uuid)
+					counts.Deleted++
+				}
+				continue
+			}
+			var change exactRow
+			if err := json.Unmarshal(data, &change); err != nil {
+				return nil, fmt.Errorf("failed to decode row %s of %s: %w", uuid, tableName, err)
+			}
+			if ok {
+				counts.Modified++
+				// The row may be shared with a checkpoint, so it is replaced, not changed
+				modified := make(ovsdb.Row, len(row))
+				for name, value := range row {
+					modified[name] = value
+				}
+				row = modified
+			} else {
+				counts.Inserted++
+				row = ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: uuid}}
+				for name, column := range table.Columns {
+					row[name] = valueOrDefault(column, nil)
+				}
+			}
+			for name, value := range change {
+				column := table.Column(name)
+				if column == nil || strings.HasPrefix(name, "_") {
+					continue
+				}
+				if tx.isDiff {
+					value = applyDiff(column, row[name], value)
+				}
+				row[name] = value
+			}
+			s.tables[tableName][uuid] = row
+		}
+		changes = append(changes, counts)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Table < changes[j].Table })
+	return changes, nil
+}
+
+// transact answers a transaction from the state. Selects are evaluated here and comments
+// accepted; a file cannot be changed, nor waited on.
+func (s *fileState) transact(dbName string, ops []ovsdb.Operation) ([]ovsdb.OperationResult, error) {
+	if dbName != "" && dbName != s.schema.Name {
+		return nil, fmt.Errorf("database %s not found in file", dbName)
+	}
+	results := make([]ovsdb.OperationResult, 0, len(ops))
+	for _, op := range ops {
+		switch op.Op {
+		case ovsdb.OperationSelect:
+			rows, err := s.selectRows(op)
+			if err != nil {
+				// The server aborts a transaction at its first failing operation
+				return append(results, ovsdb.OperationResult{Error: "syntax error", Details: err.Error()}), nil
+			}
+			results = append(results, ovsdb.OperationResult{Rows: rows})
+		case ovsdb.OperationComment:
+			results = append(results, ovsdb.OperationResult{})
+		case ovsdb.OperationWait:
+			return nil, fmt.Errorf("%s operations are not supported on database files", op.Op)
+		default:
+			return nil, fmt.Errorf("%w: %s operations are not allowed on database files", ErrReadOnly, op.Op)
+		}
+	}
+	return results, nil
+}
+
+// selectRows returns the rows of a table matching every condition of a select, ordered
+// by uuid, with the values a server would send
+func (s *fileState) selectRows(op ovsdb.Operation) ([]ovsdb.Row, error) {
+	rows, ok := s.tables[op.Table]
+	if !ok {
+		return nil, fmt.Errorf("no table named %s", op.Table)
+	}
+	uuids := make([]string, 0, len(rows))
+	for uuid := range rows {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	selected := make([]ovsdb.Row, 0, len(uuids))
+	for _, uuid := range uuids {
+		row := rows[uuid]
+		matched := true
+		for _, cond := range op.Where {
+			ok, err := matchCondition(row, cond)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		out := make(ovsdb.Row, len(row))
+		for name, value := range row {
+			out[name] = wireValue(value)
+		}
+		if op.Columns != nil {
+			projected := make(ovsdb.Row, len(op.Columns))
+			for _, name := range op.Columns {
+				if value, ok := out[name]; ok {
+					projected[name] = value
+				}
+			}
+			out = projected
+		}
+		selected = append(selected, out)
+	}
+	return selected, nil
+}
+
+// matchCondition evaluates a condition of a where clause as RFC 7047 defines it
+func matchCondition(row ovsdb.Row, cond ovsdb.Condition) (bool, error) {
+	value, ok := row[cond.Column]
+	if !ok {
+		return false, fmt.Errorf("no column named %s", cond.Column)
+	}
+	switch cond.Function {
+	case ovsdb.ConditionEqual, ovsdb.ConditionNotEqual:
+		equal := sameKeys(datumKeys(value), datumKeys(cond.Value))
+		return equal == (cond.Function == ovsdb.ConditionEqual), nil
+	case ovsdb.ConditionIncludes, ovsdb.ConditionExcludes:
+		have := make(map[string]bool)
+		for _, key := range datumKeys(value) {
+			have[key] = true
+		}
+		for _, key := range datumKeys(cond.Value) {
+			if have[key] != (cond.Function == ovsdb.ConditionIncludes) {
+				return false, nil
+			}
+		}
+		return true, nil
+	case ovsdb.ConditionLessThan, ovsdb.ConditionLessThanOrEqual, ovsdb.ConditionGreaterThan, ovsdb.ConditionGreaterThanOrEqual:
+		elems, operand := setElements(value), setElements(cond.Value)
+		if len(elems) != 1 || len(operand) != 1 {
+			return false, nil
+		}
+		cmp := compareFloat(toFloat(elems[0]), toFloat(operand[0]))
+		switch cond.Function {
+		case ovsdb.ConditionLessThan:
+			return cmp < 0, nil
+		case ovsdb.ConditionLessThanOrEqual:
+			return cmp <= 0, nil
+		case ovsdb.ConditionGreaterThan:
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	}
+	return false, fmt.Errorf("unknown condition function %s", cond.Function)
+}
+
+// datumKeys identifies the elements of a value, or its pairs for a map
+func datumKeys(v interface{}) []string {
+	switch d := v.(type) {
+	case ovsdb.OvsMap:
+		keys := make([]string, 0, len(d.GoMap))
+		for k, value := range d.GoMap {
+			keys = append(keys, atomKey(k)+"="+atomKey(value))
+		}
+		return keys
+	case *ovsdb.OvsSet:
+		return datumKeys(*d)
+	case *ovsdb.OvsMap:
+		return datumKeys(*d)
+	}
+	elems := setElements(v)
+	keys := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		keys = append(keys, atomKey(elem))
+	}
+	return keys
+}
+
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int, len(a))
+	for _, key := range a {
+		count[key]++
+	}
+	for _, key := range b {
+		if count[key] == 0 {
+			return false
+		}
+		count[key]--
+	}
+	return true
+}
+
+// wireValue puts a value in the form a server sends it in: a set of one element is
+// sent as the element alone
+func wireValue(v interface{}) interface{} {
+	if set, ok := v.(ovsdb.OvsSet); ok && len(set.GoSet) == 1 {
+		return set.GoSet[0]
+	}
+	return v
+}
+
+// DBFileInfo describes a database file opened with OpenFile
+type DBFileInfo struct {
+	Path      string `json:"path"`
+	Database  string `json:"database"`
+	Version   string `json:"version"`
+	Clustered bool   `json:"clustered"`
+	// ServerID is the Raft id of the cluster member that wrote a clustered file
+	ServerID     string `json:"serverId,omitempty"`
+	Transactions int    `json:"transactions"`
+	// Position is how many transactions the database served holds, see SeekFile; it is
+	// Transactions for the latest state
+	Position   int       `json:"position"`
+	LastChange time.Time `json:"lastChange"` // date of the last dated transaction, if any
+	Warning    string    `json:"warning,omitempty"`
+}
+
+// dbFile is a database file served in place of a server
+type dbFile struct {
+	info  DBFileInfo
+	log   *dbLog
+	state *fileState
+}
+
+// OpenFile serves a standalone or clustered database file, as ovsdb-server writes them,
+// in place of a server. The database is read-only and holds every transaction of the
+// file; GetSchema, GetTableData and the rest work as when connected, and its tables are
+// never updated.
+func (c *OVSDBClient) OpenFile(path string) (*DBFileInfo, error) {
+	log, err := readDBFile(path)
+	if err != nil {
+		return nil, err
+	}
+	state, err := log.replay(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	info := DBFileInfo{
+		Path:         path,
+		Database:     state.schema.Name,
+		Version:      state.schema.Version,
+		Clustered:    log.clustered,
+		ServerID:     log.serverID,
+		Transactions: len(log.transactions),
+		Position:     len(log.transactions),
+		Warning:      log.warning,
+	}
+	for _, tx := range log.transactions {
+		if !tx.date.IsZero() {
+			info.LastChange = tx.date
+		}
+	}
+
+	c.file.Store(&dbFile{info: info, log: log, state: state})
+	c.dbName = state.schema.Name
+	c.endpoint = "file:" + path
+	c.session = newRPCSession()
+	c.ReadOnly = true
+	c.setState(StateEvent{State: StateConnected})
+	return &info, nil
+}
+
+// FileInfo describes the database file being served, or returns nil when connected to a
+// server
+func (c *OVSDBClient) FileInfo() *DBFileInfo {
+	file := c.file.Load()
+	if file == nil {
+		return nil
+	}
+	info := file.info
+	return &info
+}
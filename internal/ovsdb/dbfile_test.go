@@ -0,0 +1,211 @@
+package ovsdb
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// record frames data as a record of a database file, which ends with a newline that the
+// length and checksum cover
+func record(magic, data string) string {
+	data += "\n"
+	sum := sha1.Sum([]byte(data))
+	return fmt.Sprintf("%s %d %s\n%s", magic, len(data), hex.EncodeToString(sum[:]), data)
+}
+
+func TestReadDBFileDamaged(t *testing.T) {
+	schema := `{"name":"Test","version":"1.0.0","tables":{"T":{"columns":{"name":{"type":"string"}}}}}`
+	insert := `{"T":{"11111111-1111-4111-8111-111111111111":{"name":"a"}},"_date":1700000000000}`
+	raftHeader := `{"server_id":"s1","prev_index":1,"prev_data":[` + schema + `,null]}`
+	tests := []struct {
+		name    string
+		content string
+		err     string // part of the error, empty when the file is read
+		warning string
+	}{
+		{"empty file", "", "file is empty", ""},
+		{"not a database", "hello world\n", "not an OVSDB database file", ""},
+		{"header cut short", "OVSDB JSON 120", "not an OVSDB database file", ""},
+		{"schema cut short", "OVSDB JSON 120 0123456789abcdef0123456789abcdef01234567\n{\"name\":", "record 1 has a bad length", ""},
+		{"bad length", "OVSDB JSON -1 0123456789abcdef0123456789abcdef01234567\n{}\n", "record 1 has a bad length", ""},
+		{"length beyond the file", "OVSDB JSON 999999999999 0123456789abcdef0123456789abcdef01234567\n{}\n", "record 1 has a bad length", ""},
+		{"bad checksum", "OVSDB JSON 2 0123456789abcdef0123456789abcdef01234567\n{}\n", "record 1 has a bad checksum", ""},
+		{"clustered header cut short", "CLUSTER 40 0123456789abcdef0123456789abcdef01234567\n{}", "record 1 has a bad length", ""},
+		{"corrupt schema", record("OVSDB JSON", `{"name":`), "failed to decode schema", ""},
+		{"missing Raft header", record("CLUSTER", `{}`), "missing Raft header", ""},
+		{"transaction cut short", record("OVSDB JSON", schema) + "OVSDB JSON 200 0123456789abcdef0123456789abcdef01234567\n{}", "", "record 2 has a bad length"},
+		{"mixed magic", record("OVSDB JSON", schema) + record("CLUSTER", insert), "", "record 2 has a bad header"},
+		{"corrupt transaction", record("OVSDB JSON", schema) + record("OVSDB JSON", `[1]`), "", "record 2:"},
+		{"intact", record("OVSDB JSON", schema) + record("OVSDB JSON", insert), "", ""},
+		{"intact clustered", record("CLUSTER", raftHeader), "", ""},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.db", i))
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		log, err := readDBFile(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if tt.warning == "" && log.warning != "" || !strings.Contains(log.warning, tt.warning) {
+			t.Errorf("%s: got warning %q, want %q", tt.name, log.warning, tt.warning)
+		}
+		if _, err := log.replay(-1); err != nil {
+			t.Errorf("%s: failed to replay: %v", tt.name, err)
+		}
+	}
+}
+
+func TestSeekFileCheckpoints(t *testing.T) {
+	var content strings.Builder
+	content.WriteString(record("OVSDB JSON", `{"name":"Test","version":"1.0.0","tables":{"T":{"columns":{"name":{"type":"string"}}}}}`))
+	for i := 1; i < 2*checkpointInterval+500; i++ {
+		uuid := fmt.Sprintf("%08d-1111-4111-8111-111111111111", i%7)
+		row := fmt.Sprintf(`{"name":"%d"}`, i)
+		if i%5 == 0 {
+			row = "null"
+		}
+		content.WriteString(record("OVSDB JSON", fmt.Sprintf(`{"T":{%q:%s}}`, uuid, row)))
+	}
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := readDBFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &OVSDBClient{}
+	if _, err := c.OpenFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.FileTransactions(); err != nil {
+		t.Fatal(err)
+	}
+	// Forward, backward across checkpoints, just past checkpoints, whose rows the later
+	// transactions must not have changed, and back to the latest
+	for _, n := range []int{1, 1500, 1700, 2003, 1200, checkpointInterval + 2, 3, 0} {
+		if _, err := c.SeekFile(n); err != nil {
+			t.Fatalf("SeekFile(%d): %v", n, err)
+		}
+		state, err := want.replay(n)
+		if n == 0 {
+			state, err = want.replay(-1)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.file.Load().state.tables; !reflect.DeepEqual(got, state.tables) {
+			t.Errorf("SeekFile(%d) serves %v, want %v", n, got, state.tables)
+		}
+	}
+}
+
+// fileSchema has a column of each shape a diff changes differently
+const fileSchema = `{"name":"Test","version":"1.0.0","tables":{"T":{"columns":{
+	"name":{"type":"string"},
+	"ports":{"type":{"key":"string","min":0,"max":"unlimited"}},
+	"opts":{"type":{"key":"string","value":"string","min":0,"max":"unlimited"}}}}}}`
+
+const fileRowA, fileRowB, fileRowC = "11111111-1111-4111-8111-111111111111", "22222222-2222-4222-8222-222222222222", "33333333-3333-4333-8333-333333333333"
+
+// standaloneFile inserts a and b, then changes a by a diff and deletes b
+var standaloneFile = record("OVSDB JSON", fileSchema) +
+	record("OVSDB JSON", `{"T":{"`+fileRowA+`":{"name":"a","ports":["set",["p1","p2"]],"opts":["map",[["k","v"]]]}},"_date":1700000000000,"_comment":"create a"}`) +
+	record("OVSDB JSON", `{"T":{"`+fileRowB+`":{"name":"b"}},"_date":1700000060000}`) +
+	record("OVSDB JSON", `{"T":{"`+fileRowA+`":{"ports":["set",["p2","p3"]],"opts":["map",[["k","w"],["x","y"]]]},"`+fileRowB+`":null},"_date":1700000120000,"_is_diff":true}`)
+
+// clusteredFile starts from a snapshot holding a, has an entry overridden by a new leader
+// that inserts c, and changes a by a diff
+var clusteredFile = record("CLUSTER", `{"server_id":"s1","cluster_id":"c1","local_address":"tcp:10.0.0.1:6643","prev_term":1,"prev_index":5,"prev_servers":{},"prev_data":[`+fileSchema+`,{"T":{"`+fileRowA+`":{"name":"a"}}}]}`) +
+	record("CLUSTER", `{"term":1,"index":6,"data":[null,{"T":{"`+fileRowB+`":{"name":"b"}},"_comment":"never committed"}]}`) +
+	record("CLUSTER", `{"term":2,"vote":"s2"}`) +
+	record("CLUSTER", `{"term":2,"index":6,"data":[null,{"T":{"`+fileRowC+`":{"name":"c"}},"_comment":"create c","_date":1700000000000}]}`) +
+	record("CLUSTER", `{"term":2,"index":7,"servers":{"s1":"tcp:10.0.0.1:6643","s2":"tcp:10.0.0.2:6643"}}`) +
+	record("CLUSTER", `{"term":2,"index":8,"data":[null,{"T":{"`+fileRowA+`":{"ports":["set",["p1"]]}},"_is_diff":true}]}`) +
+	record("CLUSTER", `{"term":2,"commit_index":8}`)
+
+// openFile writes content to a database file and serves it from a new client
+func openFile(t *testing.T, content string) (*OVSDBClient, *DBFileInfo) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &OVSDBClient{}
+	info, err := c.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, info
+}
+
+// fileRows returns the plain rows of table T by name
+func fileRows(t *testing.T, c *OVSDBClient) map[string]map[string]interface{} {
+	t.Helper()
+	rows, err := c.GetTableData(context.Background(), "", "T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		plain := make(map[string]interface{}, len(row))
+		for column, value := range row {
+			if column != "_uuid" {
+				plain[column] = value.Plain()
+			}
+		}
+		byName[plain["name"].(string)] = plain
+	}
+	return byName
+}
+
+func TestOpenFile(t *testing.T) {
+	c, info := openFile(t, standaloneFile)
+	if info.Database != "Test" || info.Version != "1.0.0" || info.Clustered || info.Transactions != 4 || info.Position != 4 ||
+		!info.LastChange.Equal(time.UnixMilli(1700000120000)) || info.Warning != "" {
+		t.Errorf("file info = %+v", info)
+	}
+	want := map[string]map[string]interface{}{
+		"a": {"name": "a", "ports": []interface{}{"p1", "p3"}, "opts": map[string]interface{}{"k": "w", "x": "y"}},
+	}
+	if got := fileRows(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	if _, err := c.Transact(context.Background(), "", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "T", Row: ovsdb.Row{"name": "c"}}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("insert into a file returned %v", err)
+	}
+
+	c, info = openFile(t, clusteredFile)
+	if !info.Clustered || info.ServerID != "s1" || info.Database != "Test" || info.Transactions != 3 {
+		t.Errorf("clustered file info = %+v", info)
+	}
+	want = map[string]map[string]interface{}{
+		"a": {"name": "a", "ports": []interface{}{"p1"}, "opts": map[string]interface{}{}},
+		"c": {"name": "c", "ports": []interface{}{}, "opts": map[string]interface{}{}},
+	}
+	if got := fileRows(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("clustered rows = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package ovsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// exactRow is an ovsdb.Row decoded with its integers intact. libovsdb decodes every number
+// as a float64, which changes integers beyond 2^53; here integers become ints and only
+// numbers with a fraction or an exponent become float64.
+type exactRow ovsdb.Row
+
+func (r *exactRow) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+	row := make(exactRow, len(raw))
+	for column, v := range raw {
+		value, err := exactValue(v)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+		row[column] = value
+	}
+	*r = row
+	return nil
+}
+
+// row returns the decoded row, nil for a row that was absent
+func (r *exactRow) row() *ovsdb.Row {
+	return (*ovsdb.Row)(r)
+}
+
+// exactValue converts a value of the OVSDB wire format, decoded with json.Number, as
+// libovsdb does: uuids become ovsdb.UUID, sets ovsdb.OvsSet and maps ovsdb.OvsMap
+func exactValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return int(i), nil
+		}
+		return val.Float64()
+	case []interface{}:
+		if len(val) != 2 {
+			return nil, fmt.Errorf("invalid value %v", val)
+		}
+		kind, _ := val[0].(string)
+		switch kind {
+		case "uuid", "named-uuid":
+			uuid, ok := val[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s %v", kind, val[1])
+			}
+			return ovsdb.UUID{GoUUID: uuid}, nil
+		case "set":
+			elems, ok := val[1].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid set %v", val[1])
+			}
+			set := make([]interface{}, 0, len(elems))
+			for _, elem := range elems {
+				atom, err := exactValue(elem)
+				if err != nil {
+					return nil, err
+				}
+				set = append(set, atom)
+			}
+			return ovsdb.OvsSet{GoSet: set}, nil
+		case "map":
+			pairs, ok := val[1].([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid map %v", val[1])
+			}
+			goMap := make(map[interface{}]interface{}, len(pairs))
+			for _, p := range pairs {
+				pair, ok := p.([]interface{})
+				if !ok || len(pair) != 2 {
+					return nil, fmt.Errorf("invalid map pair %v", p)
+				}
+				key, err := exactValue(pair[0])
+				if err != nil {
+					return nil, err
+				}
+				value, err := exactValue(pair[1])
+				if err != nil {
+					return nil, err
+				}
+				goMap[key] = value
+			}
+			return ovsdb.OvsMap{GoMap: goMap}, nil
+		}
+		return nil, fmt.Errorf("invalid value %v", val)
+	default:
+		return v, nil
+	}
+}
+
+// exactResult is an ovsdb.OperationResult whose rows are decoded as exactRow
+type exactResult struct {
+	Count   int        `json:"count,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Details string     `json:"details,omitempty"`
+	UUID    ovsdb.UUID `json:"uuid,omitempty"`
+	Rows    []exactRow `json:"rows,omitempty"`
+}
+
+func (r exactResult) result() ovsdb.OperationResult {
+	result := ovsdb.OperationResult{Count: r.Count, Error: r.Error, Details: r.Details, UUID: r.UUID}
+	if r.Rows != nil {
+		result.Rows = make([]ovsdb.Row, 0, len(r.Rows))
+		for _, row := range r.Rows {
+			result.Rows = append(result.Rows, ovsdb.Row(row))
+		}
+	}
+	return result
+}
+
+// exactUpdates is ovsdb.TableUpdates, the "update" notification and monitor reply, with
+// rows decoded as exactRow
+type exactUpdates map[string]map[string]*struct {
+	New *exactRow `json:"new,omitempty"`
+	Old *exactRow `json:"old,omitempty"`
+}
+
+func (u exactUpdates) tableUpdates() ovsdb.TableUpdates {
+	updates := make(ovsdb.TableUpdates, len(u))
+	for table, rows := range u {
+		update := make(ovsdb.TableUpdate, len(rows))
+		for uuid, row := range rows {
+			if row != nil {
+				update[uuid] = &ovsdb.RowUpdate{New: row.New.row(), Old: row.Old.row()}
+			}
+		}
+		updates[table] = update
+	}
+	return updates
+}
+
+// exactUpdates2 is ovsdb.TableUpdates2, the "update2" and "update3" notifications and
+// monitor_cond reply, with rows decoded as exactRow
+type exactUpdates2 map[string]map[string]*exactRowUpdate2
+
+// exactRowUpdate2 is one row of an exactUpdates2
+type exactRowUpdate2 struct {
+	Initial *exactRow
+	Insert  *exactRow
+	Modify  *exactRow
+	Delete  *exactRow
+}
+
+// UnmarshalJSON tells a deleted row by its "delete" member, which ovsdb-server sends as
+// null and would otherwise leave Delete nil
+func (u *exactRowUpdate2) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	for name, target := range map[string]**exactRow{
+		"initial": &u.Initial,
+		"insert":  &u.Insert,
+		"modify":  &u.Modify,
+		"delete":  &u.Delete,
+	} {
+		value, ok := members[name]
+		if !ok {
+			continue
+		}
+		row := exactRow{}
+		if !isNull(value) {
+			if err := json.Unmarshal(value, &row); err != nil {
+				return err
+			}
+		}
+		*target = &row
+	}
+	return nil
+}
+
+func (u exactUpdates2) tableUpdates() ovsdb.TableUpdates2 {
+	updates := make(ovsdb.TableUpdates2, len(u))
+	for table, rows := range u {
+		update := make(ovsdb.TableUpdate2, len(rows))
+		for uuid, row := range rows {
+			if row != nil {
+				update[uuid] = &ovsdb.RowUpdate2{
+					Initial: row.Initial.row(),
+					Insert:  row.Insert.row(),
+					Modify:  row.Modify.row(),
+					Delete:  row.Delete.row(),
+				}
+			}
+		}
+		updates[table] = update
+	}
+	return updates
+}
+
+// exactCondSinceReply is ovsdb.MonitorCondSinceReply, the [found, last-txn-id, updates]
+// reply of monitor_cond_since, with rows decoded as exactRow
+type exactCondSinceReply struct {
+	found   bool
+	lastTxn string
+	updates exactUpdates2
+}
+
+func (r *exactCondSinceReply) UnmarshalJSON(data []byte) error {
+	var members []json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	if len(members) != 3 {
+		return fmt.Errorf("expected 3 members in monitor_cond_since reply, got %d", len(members))
+	}
+	if err := json.Unmarshal(members[0], &r.found); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(members[1], &r.lastTxn); err != nil {
+		return err
+	}
+	return json.Unmarshal(members[2], &r.updates)
+}
@@ -0,0 +1,477 @@
+package ovsdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"gopkg.in/yaml.v3"
+)
+
+// Export formats
+const (
+	ExportJSON = "json" // typed values, as GetTableData returns them
+	ExportCSV  = "csv"  // one file per table; several tables are zipped
+	ExportYAML = "yaml"
+	ExportDump = "dump" // the text format of "ovsdb-client dump"
+)
+
+// How CSV cells hold sets and maps
+const (
+	FlattenJSON    = "json"    // a JSON array or object (default)
+	FlattenJoin    = "join"    // elements, or key=value pairs, joined by the separator
+	FlattenColumns = "columns" // maps only: one column per key, named column:key
+)
+
+// CSVOptions selects how sets and maps are flattened into CSV cells
+type CSVOptions struct {
+	Sets      string `json:"sets,omitempty"`      // json or join
+	Maps      string `json:"maps,omitempty"`      // json, join or columns
+	Separator string `json:"separator,omitempty"` // for join (default: a space)
+}
+
+// ExportOptions selects what to export and in which format
+type ExportOptions struct {
+	Format   string
+	Database string
+	Tables   []string // empty for every table of the database
+	// Conditions, Columns and Sort export a single table as it is shown; without Sort, rows
+	// are ordered by _uuid
+	Conditions []Condition
+	Columns    []string
+	Sort       []SortKey
+	CSV        CSVOptions
+}
+
+// ExportExtension returns the file extension of an export
+func ExportExtension(opts ExportOptions) string {
+	switch opts.Format {
+	case ExportCSV:
+		if len(opts.Tables) != 1 {
+			return "zip"
+		}
+		return "csv"
+	case ExportDump:
+		return "txt"
+	default:
+		return opts.Format
+	}
+}
+
+// exportTable is one table of an export, its rows sorted
+type exportTable struct {
+	name    string
+	schema  *ovsdb.TableSchema
+	columns []string // _uuid included
+	rows    []ovsdb.Row
+}
+
+// Export writes tables of a database to w. Every table is read by the same transaction, so
+// the export of a whole database is consistent.
+func (c *OVSDBClient) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	switch opts.Format {
+	case ExportJSON, ExportCSV, ExportYAML, ExportDump:
+	default:
+		return fmt.Errorf("unknown export format %q", opts.Format)
+	}
+	schema, err := c.schema(ctx, opts.Database)
+	if err != nil {
+		return err
+	}
+	tables, err := c.exportTables(ctx, schema, opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case ExportJSON:
+		return writeExportJSON(w, schema, tables)
+	case ExportYAML:
+		return writeExportYAML(w, schema, tables)
+	case ExportDump:
+		return writeExportDump(w, tables)
+	}
+	if len(opts.Tables) == 1 {
+		return writeExportCSV(w, tables[0], opts.CSV)
+	}
+	archive := zip.NewWriter(w)
+	for _, table := range tables {
+		f, err := archive.Create(table.name + ".csv")
+		if err != nil {
+			return fmt.Errorf("failed to add %s to the archive: %w", table.name, err)
+		}
+		if err := writeExportCSV(f, table, opts.CSV); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// exportTables selects the tables to export in one transaction
+func (c *OVSDBClient) exportTables(ctx context.Context, schema *ovsdb.DatabaseSchema, opts ExportOptions) ([]exportTable, error) {
+	names := opts.Tables
+	if len(names) == 0 {
+		for name := range schema.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) > 1 && (len(opts.Conditions) > 0 || len(opts.Columns) > 0 || len(opts.Sort) > 0) {
+		return nil, fmt.Errorf("conditions, columns and sort apply to a single table")
+	}
+
+	tables := make([]exportTable, 0, len(names))
+	ops := make([]ovsdb.Operation, 0, len(names))
+	for _, name := range names {
+		table := schema.Table(name)
+		if table == nil {
+			return nil, fmt.Errorf("table %s not found in %s", name, schema.Name)
+		}
+		where, err := whereClause(table, opts.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		columns, err := exportColumns(table, opts.Columns)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range opts.Sort {
+			if table.Column(key.Column) == nil {
+				return nil, fmt.Errorf("sort column %s not found", key.Column)
+			}
+		}
+		tables = append(tables, exportTable{name: name, schema: table, columns: columns})
+		ops = append(ops, ovsdb.Operation{Op: ovsdb.OperationSelect, Table: name, Where: where})
+	}
+
+	results, err := c.Transact(ctx, opts.Database, ops...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+	if len(results) < len(tables) {
+		return nil, fmt.Errorf("expected %d results, got %d", len(tables), len(results))
+	}
+	for i := range tables {
+		if results[i].Error != "" {
+			return nil, fmt.Errorf("failed to read %s: %s - %s", tables[i].name, results[i].Error, results[i].Details)
+		}
+		tables[i].rows = sortedRows(results[i].Rows, opts.Sort)
+	}
+	return tables, nil
+}
+
+// exportColumns returns the columns to export: the requested ones after _uuid, or else
+// every column in name order as "ovsdb-client dump" has them
+func exportColumns(table *ovsdb.TableSchema, requested []string) ([]string, error) {
+	if len(requested) > 0 {
+		columns := []string{"_uuid"}
+		for _, column := range requested {
+			if table.Column(column) == nil {
+				return nil, fmt.Errorf("column %s not found", column)
+			}
+			if column != "_uuid" {
+				columns = append(columns, column)
+			}
+		}
+		return columns, nil
+	}
+	columns := []string{"_uuid"}
+	for column := range table.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+// typedColumns converts the exported columns of a row
+func (t *exportTable) typedColumns(row ovsdb.Row) TypedRow {
+	typed := make(TypedRow, len(t.columns))
+	for _, column := range t.columns {
+		typed[column] = typedValue(columnSchema(t.schema, column), valueOrDefault(t.schema.Column(column), row[column]))
+	}
+	return typed
+}
+
+func writeExportJSON(w io.Writer, schema *ovsdb.DatabaseSchema, tables []exportTable) error {
+	document := struct {
+		Database string                `json:"database"`
+		Version  string                `json:"version,omitempty"`
+		Tables   map[string][]TypedRow `json:"tables"`
+	}{Database: schema.Name, Version: schema.Version, Tables: make(map[string][]TypedRow, len(tables))}
+	for i := range tables {
+		rows := make([]TypedRow, 0, len(tables[i].rows))
+		for _, row := range tables[i].rows {
+			rows = append(rows, tables[i].typedColumns(row))
+		}
+		document.Tables[tables[i].name] = rows
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+func writeExportYAML(w io.Writer, schema *ovsdb.DatabaseSchema, tables []exportTable) error {
+	exported := make(map[string][]map[string]interface{}, len(tables))
+	for i := range tables {
+		rows := make([]map[string]interface{}, 0, len(tables[i].rows))
+		for _, row := range tables[i].rows {
+			plain := make(map[string]interface{}, len(tables[i].columns))
+			for column, value := range tables[i].typedColumns(row) {
+				plain[column] = value.Plain()
+			}
+			rows = append(rows, plain)
+		}
+		exported[tables[i].name] = rows
+	}
+	document := map[string]interface{}{"database": schema.Name, "tables": exported}
+	if schema.Version != "" {
+		document["version"] = schema.Version
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("failed to write YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+func writeExportCSV(w io.Writer, table exportTable, opts CSVOptions) error {
+	separator := opts.Separator
+	if separator == "" {
+		separator = " "
+	}
+	// Map columns flattened into columns get one per key found in any row
+	mapKeys := make(map[string][]string)
+	if opts.Maps == FlattenColumns {
+		for _, column := range table.columns {
+			if schema := table.schema.Column(column); schema == nil || schema.Type != ovsdb.TypeMap {
+				continue
+			}
+			seen := make(map[string]bool)
+			for _, row := range table.rows {
+				for _, pair := range typedValue(table.schema.Column(column), row[column]).Map {
+					seen[atomText(pair.Key)] = true
+				}
+			}
+			if len(seen) == 0 {
+				// Keep the column, empty, when no row has a key
+				continue
+			}
+			keys := make([]string, 0, len(seen))
+			for key := range seen {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			mapKeys[column] = keys
+		}
+	}
+
+	out := csv.NewWriter(w)
+	var header []string
+	for _, column := range table.columns {
+		if keys, ok := mapKeys[column]; ok {
+			for _, key := range keys {
+				header = append(header, column+":"+key)
+			}
+			continue
+		}
+		header = append(header, column)
+	}
+	if err := out.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, row := range table.rows {
+		typed := table.typedColumns(row)
+		record := make([]string, 0, len(header))
+		for _, column := range table.columns {
+			value := typed[column]
+			if keys, ok := mapKeys[column]; ok {
+				values := make(map[string]string, len(value.Map))
+				for _, pair := range value.Map {
+					values[atomText(pair.Key)] = atomText(pair.Value)
+				}
+				for _, key := range keys {
+					record = append(record, values[key])
+				}
+				continue
+			}
+			record = append(record, csvCell(value, opts, separator))
+		}
+		if err := out.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// csvCell flattens a value into one CSV cell
+func csvCell(v Value, opts CSVOptions, separator string) string {
+	switch v.Kind {
+	case KindSet:
+		if opts.Sets == FlattenJoin {
+			elems := make([]string, 0, len(v.Set))
+			for _, atom := range v.Set {
+				elems = append(elems, atomText(atom))
+			}
+			return strings.Join(elems, separator)
+		}
+		data, _ := json.Marshal(v.Plain())
+		return string(data)
+	case KindMap:
+		if opts.Maps == FlattenJoin {
+			pairs := make([]string, 0, len(v.Map))
+			for _, pair := range v.Map {
+				pairs = append(pairs, atomText(pair.Key)+"="+atomText(pair.Value))
+			}
+			return strings.Join(pairs, separator)
+		}
+		data, _ := json.Marshal(v.Plain())
+		return string(data)
+	default:
+		if v.Atom == nil {
+			return ""
+		}
+		return atomText(*v.Atom)
+	}
+}
+
+// atomText is an atom as plain text
+func atomText(atom Atom) string {
+	switch value := atom.Value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// writeExportDump writes tables the way "ovsdb-client dump" prints them
+func writeExportDump(w io.Writer, tables []exportTable) error {
+	var buf bytes.Buffer
+	for i, table := range tables {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(&buf, "%s table\n", table.name)
+		cells := make([][]string, 0, len(table.rows)+2)
+		cells = append(cells, table.columns)
+		for _, row := range table.rows {
+			line := make([]string, 0, len(table.columns))
+			for _, column := range table.columns {
+				line = append(line, dumpDatum(columnSchema(table.schema, column), valueOrDefault(table.schema.Column(column), row[column])))
+			}
+			cells = append(cells, line)
+		}
+
+		widths := make([]int, len(table.columns))
+		for _, line := range cells {
+			for x, cell := range line {
+				if n := utf8.RuneCountInString(cell); n > widths[x] {
+					widths[x] = n
+				}
+			}
+		}
+		dashes := make([]string, len(widths))
+		for x, width := range widths {
+			dashes[x] = strings.Repeat("-", width)
+		}
+		cells = append(cells[:1], append([][]string{dashes}, cells[1:]...)...)
+		for _, line := range cells {
+			var text strings.Builder
+			for x, cell := range line {
+				if x > 0 {
+					text.WriteByte(' ')
+				}
+				text.WriteString(cell)
+				text.WriteString(strings.Repeat(" ", widths[x]-utf8.RuneCountInString(cell)))
+			}
+			buf.WriteString(strings.TrimRight(text.String(), " "))
+			buf.WriteByte('\n')
+		}
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+	return nil
+}
+
+// dumpDatum formats a column value as ovsdb-client does: brackets around sets and braces
+// around maps unless the column holds at most one element and has one
+func dumpDatum(column *ovsdb.ColumnSchema, v interface{}) string {
+	value := typedValue(column, v)
+	var elems []string
+	switch value.Kind {
+	case KindSet:
+		for _, atom := range value.Set {
+			elems = append(elems, dumpAtom(atom))
+		}
+	case KindMap:
+		for _, pair := range value.Map {
+			elems = append(elems, dumpAtom(pair.Key)+"="+dumpAtom(pair.Value))
+		}
+	default:
+		if value.Atom != nil {
+			elems = append(elems, dumpAtom(*value.Atom))
+		}
+	}
+	max := 1
+	if column.TypeObj != nil && (column.Type == ovsdb.TypeSet || column.Type == ovsdb.TypeMap) {
+		max = column.TypeObj.Max()
+	}
+	if max == 1 && len(elems) == 1 {
+		return elems[0]
+	}
+	if value.Kind == KindMap {
+		return "{" + strings.Join(elems, ", ") + "}"
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// dumpAtom formats an atom as ovsdb-client does; strings are quoted unless they are made of
+// letters, '_', '-' and '.', start with a letter or '_', and cannot be mistaken for a
+// boolean or a uuid
+func dumpAtom(atom Atom) string {
+	switch value := atom.Value.(type) {
+	case string:
+		if atom.Type == ovsdb.TypeUUID || atom.Type == TypeNamedUUID || !dumpNeedsQuotes(value) {
+			return value
+		}
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(value)
+		return strings.TrimSuffix(buf.String(), "\n")
+	case float64:
+		return strconv.FormatFloat(value, 'g', 15, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func dumpNeedsQuotes(s string) bool {
+	if s == "" || s == "true" || s == "false" || ovsdb.IsValidUUID(s) {
+		return true
+	}
+	for i, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || r == '_' || (i > 0 && (r == '-' || r == '.'))) {
+			return true
+		}
+	}
+	return false
+}
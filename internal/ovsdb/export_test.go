@@ -0,0 +1,179 @@
+package ovsdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"gopkg.in/yaml.v3"
+)
+
+func TestExport(t *testing.T) {
+	sock := startServer(t)
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	var uuids []string
+	for _, row := range []ovsdb.Row{
+		{"name": "br1"},
+		{"name": "br0", "external_ids": ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"owner": "ovn", "a": "b c"}}},
+	} {
+		results, err := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: row})
+		if err != nil || results[0].Error != "" {
+			t.Fatalf("failed to insert %v: %v %+v", row["name"], err, results)
+		}
+		uuids = append([]string{results[0].UUID.GoUUID}, uuids...)
+	}
+	bridges := ExportOptions{
+		Tables:  []string{"Bridge"},
+		Columns: []string{"name", "external_ids", "datapath_type"},
+		Sort:    []SortKey{{Column: "name"}},
+	}
+	export := func(format string, opts ExportOptions) []byte {
+		t.Helper()
+		opts.Format = format
+		var buf bytes.Buffer
+		if err := c.Export(ctx, &buf, opts); err != nil {
+			t.Fatalf("%s export failed: %v", format, err)
+		}
+		return buf.Bytes()
+	}
+	readCSV := func(data []byte) [][]string {
+		t.Helper()
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return records
+	}
+
+	var document struct {
+		Database string                `json:"database"`
+		Version  string                `json:"version"`
+		Tables   map[string][]TypedRow `json:"tables"`
+	}
+	if err := json.Unmarshal(export(ExportJSON, bridges), &document); err != nil {
+		t.Fatal(err)
+	}
+	rows := document.Tables["Bridge"]
+	if document.Database != "Open_vSwitch" || document.Version != "0.0.1" || len(rows) != 2 {
+		t.Fatalf("JSON export = %+v", document)
+	}
+	if len(rows[0]) != 4 || rows[0]["name"].Atom.Value != "br0" || rows[0]["_uuid"].Atom.Value != uuids[0] || len(rows[0]["external_ids"].Map) != 2 {
+		t.Errorf("first exported row = %+v", rows[0])
+	}
+
+	var plain struct {
+		Database string                              `yaml:"database"`
+		Tables   map[string][]map[string]interface{} `yaml:"tables"`
+	}
+	if err := yaml.Unmarshal(export(ExportYAML, bridges), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if rows := plain.Tables["Bridge"]; len(rows) != 2 || rows[0]["name"] != "br0" || rows[0]["external_ids"].(map[string]interface{})["owner"] != "ovn" {
+		t.Errorf("YAML export = %+v", plain)
+	}
+
+	csvTests := []struct {
+		name   string
+		opts   CSVOptions
+		header []string
+		br0    []string
+		br1    []string
+	}{
+		{"json", CSVOptions{}, []string{"_uuid", "name", "external_ids", "datapath_type"},
+			[]string{uuids[0], "br0", `{"a":"b c","owner":"ovn"}`, ""}, []string{uuids[1], "br1", "{}", ""}},
+		{"join", CSVOptions{Maps: FlattenJoin, Separator: ";"}, []string{"_uuid", "name", "external_ids", "datapath_type"},
+			[]string{uuids[0], "br0", "a=b c;owner=ovn", ""}, []string{uuids[1], "br1", "", ""}},
+		{"columns", CSVOptions{Maps: FlattenColumns}, []string{"_uuid", "name", "external_ids:a", "external_ids:owner", "datapath_type"},
+			[]string{uuids[0], "br0", "b c", "ovn", ""}, []string{uuids[1], "br1", "", "", ""}},
+	}
+	for _, tt := range csvTests {
+		opts := bridges
+		opts.CSV = tt.opts
+		want := [][]string{tt.header, tt.br0, tt.br1}
+		if got := readCSV(export(ExportCSV, opts)); !reflect.DeepEqual(got, want) {
+			t.Errorf("CSV with %s maps = %q, want %q", tt.name, got, want)
+		}
+	}
+	opts := bridges
+	opts.Columns = []string{"ports"}
+	opts.CSV = CSVOptions{Sets: FlattenJoin}
+	if got := readCSV(export(ExportCSV, opts)); !reflect.DeepEqual(got[1], []string{uuids[0], ""}) {
+		t.Errorf("CSV of an empty set = %q", got)
+	}
+
+	line := func(cells ...string) string {
+		return strings.TrimRight(fmt.Sprintf("%-36s %-5s %-20s %s", cells[0], cells[1], cells[2], cells[3]), " ") + "\n"
+	}
+	want := "Bridge table\n" +
+		line("_uuid", "name", "external_ids", "datapath_type") +
+		line(strings.Repeat("-", 36), strings.Repeat("-", 5), strings.Repeat("-", 20), strings.Repeat("-", 13)) +
+		line(uuids[0], `"br0"`, `{a="b c", owner=ovn}`, `""`) +
+		line(uuids[1], `"br1"`, "{}", `""`)
+	if got := string(export(ExportDump, bridges)); got != want {
+		t.Errorf("dump export =\n%s\nwant\n%s", got, want)
+	}
+
+	// Conditions narrow the rows exported
+	opts = bridges
+	opts.Conditions = []Condition{{Column: "name", Function: "==", Value: "br1"}}
+	if got := readCSV(export(ExportCSV, opts)); len(got) != 2 || got[1][1] != "br1" {
+		t.Errorf("CSV of br1 = %q", got)
+	}
+
+	// A whole database is a CSV file per table in a zip archive
+	data := export(ExportCSV, ExportOptions{})
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := c.schema(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files, tables []string
+	for _, f := range archive.File {
+		files = append(files, f.Name)
+	}
+	for name := range schema.Tables {
+		tables = append(tables, name+".csv")
+	}
+	sort.Strings(tables)
+	if !reflect.DeepEqual(files, tables) {
+		t.Errorf("archive holds %v, want %v", files, tables)
+	}
+	if dump := string(export(ExportDump, ExportOptions{})); !strings.HasPrefix(dump, "Bridge table\n") || !strings.Contains(dump, "\n\nPort table\n") || !strings.Contains(dump, `"br1"`) {
+		t.Errorf("dump of the database =\n%s", dump)
+	}
+
+	for _, opts := range []ExportOptions{
+		{Format: "xml"},
+		{Format: ExportJSON, Tables: []string{"Other"}},
+		{Format: ExportJSON, Tables: []string{"Bridge"}, Columns: []string{"other"}},
+		{Format: ExportJSON, Tables: []string{"Bridge"}, Sort: []SortKey{{Column: "other"}}},
+		{Format: ExportJSON, Columns: []string{"name"}},
+	} {
+		if err := c.Export(ctx, &bytes.Buffer{}, opts); err == nil {
+			t.Errorf("export %+v succeeded", opts)
+		}
+	}
+	for opts, want := range map[*ExportOptions]string{
+		{Format: ExportCSV}:                             "zip",
+		{Format: ExportCSV, Tables: []string{"Bridge"}}: "csv",
+		{Format: ExportDump}:                            "txt",
+		{Format: ExportYAML}:                            "yaml",
+	} {
+		if got := ExportExtension(*opts); got != want {
+			t.Errorf("extension of %+v = %s, want %s", *opts, got, want)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package ovsdb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// commitCaptures returns the selects, run ahead of ops in the same transaction, of the rows
+// the commit of ops may change on its own: the non-root rows that lose a strong reference,
+// which are garbage collected once no row refers to them, the non-root rows these refer to
+// in turn, and the rows whose weak references to any of them or to deleted rows the commit
+// drops. Which rows these are is read beforehand, so a reference made in between is missed.
+func (c *OVSDBClient) commitCaptures(ctx context.Context, dbName string, schema *ovsdb.DatabaseSchema, ops []ovsdb.Operation) ([]ovsdb.Operation, error) {
+	var reads []ovsdb.Operation
+	var deletes []bool
+	for _, op := range ops {
+		table := schema.Table(op.Table)
+		if table == nil {
+			continue
+		}
+		columns := releasedColumns(schema, table, op)
+		deleted := op.Op == ovsdb.OperationDelete && len(weakReferrers(schema, op.Table)) > 0
+		if len(columns) == 0 && !deleted {
+			continue
+		}
+		reads = append(reads, ovsdb.Operation{
+			Op:      ovsdb.OperationSelect,
+			Table:   op.Table,
+			Where:   op.Where,
+			Columns: append([]string{"_uuid"}, columns...),
+		})
+		deletes = append(deletes, op.Op == ovsdb.OperationDelete)
+	}
+
+	removed := make(map[string]string) // uuid of a row that may be gone, to its table
+	var collectable, queue []reference
+	for len(reads) > 0 {
+		results, err := c.Transact(ctx, dbName, reads...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the rows the transaction changes: %w", err)
+		}
+		for i, read := range reads {
+			if i >= len(results) || results[i].Error != "" {
+				return nil, fmt.Errorf("failed to read the rows of %s the transaction changes", read.Table)
+			}
+			table := schema.Table(read.Table)
+			for _, row := range results[i].Rows {
+				if uuid, ok := row["_uuid"].(ovsdb.UUID); ok && deletes[i] {
+					removed[uuid.GoUUID] = read.Table
+				}
+				queue = append(queue, rowReferences(table, row, ovsdb.Strong)...)
+			}
+		}
+
+		// The rows referred to may be collected, and their own references with them
+		reads, deletes = nil, nil
+		var candidates []reference
+		for _, ref := range queue {
+			if _, ok := removed[ref.uuid]; ok || !nonRoot(schema, ref.table) {
+				continue
+			}
+			removed[ref.uuid] = ref.table
+			candidates = append(candidates, ref)
+			if columns := collectableColumns(schema, schema.Table(ref.table)); len(columns) > 0 {
+				reads = append(reads, ovsdb.Operation{
+					Op:      ovsdb.OperationSelect,
+					Table:   ref.table,
+					Where:   uuidWhere(ref.uuid),
+					Columns: append([]string{"_uuid"}, columns...),
+				})
+				deletes = append(deletes, false)
+			}
+		}
+		queue = nil
+		collectable = append(collectable, candidates...)
+	}
+	return commitSelects(schema, collectable, removed), nil
+}
+
+// commitSelects builds the selects of the rows that may be collected, whole since the undo
+// inserts them again, and of the rows with weak references to rows that may be gone, with
+// only the referring column. A weak reference from a map cannot be looked for with includes,
+// which matches whole pairs, so such a column is read for the whole table.
+func commitSelects(schema *ovsdb.DatabaseSchema, collectable []reference, removed map[string]string) []ovsdb.Operation {
+	var selects []ovsdb.Operation
+	for _, ref := range collectable {
+		selects = append(selects, ovsdb.Operation{Op: ovsdb.OperationSelect, Table: ref.table, Where: uuidWhere(ref.uuid)})
+	}
+	byTable := make(map[string][]string)
+	for uuid, table := range removed {
+		byTable[table] = append(byTable[table], uuid)
+	}
+	for _, uuids := range byTable {
+		sort.Strings(uuids)
+	}
+
+	for _, name := range sortedNames(schema.Tables) {
+		table := schema.Table(name)
+		for _, columnName := range sortedNames(table.Columns) {
+			column := table.Column(columnName)
+			var uuids []string
+			for _, refTable := range uniqueStrings(refTables(column, ovsdb.Weak)) {
+				uuids = append(uuids, byTable[refTable]...)
+			}
+			if len(uuids) == 0 {
+				continue
+			}
+			if column.Type == ovsdb.TypeMap {
+				selects = append(selects, ovsdb.Operation{
+					Op:      ovsdb.OperationSelect,
+					Table:   name,
+					Where:   []ovsdb.Condition{},
+					Columns: []string{"_uuid", columnName},
+				})
+				continue
+			}
+			for _, uuid := range uuids {
+				condition := ovsdb.NewCondition(columnName, ovsdb.ConditionEqual, ovsdb.UUID{GoUUID: uuid})
+				if column.Type == ovsdb.TypeSet {
+					condition = ovsdb.NewCondition(columnName, ovsdb.ConditionIncludes, ovsdb.OvsSet{GoSet: []interface{}{ovsdb.UUID{GoUUID: uuid}}})
+				}
+				selects = append(selects, ovsdb.Operation{
+					Op:      ovsdb.OperationSelect,
+					Table:   name,
+					Where:   []ovsdb.Condition{condition},
+					Columns: []string{"_uuid", columnName},
+				})
+			}
+		}
+	}
+	return selects
+}
+
+// releasedColumns returns the sorted columns an operation may remove strong references to
+// non-root rows from: every such column of a deleted row, the updated ones, and those an
+// element is deleted from
+func releasedColumns(schema *ovsdb.DatabaseSchema, table *ovsdb.TableSchema, op ovsdb.Operation) []string {
+	var columns []string
+	switch op.Op {
+	case ovsdb.OperationDelete:
+		return collectableColumns(schema, table)
+	case ovsdb.OperationUpdate:
+		for name := range op.Row {
+			if collects(schema, table.Column(name)) {
+				columns = append(columns, name)
+			}
+		}
+	case ovsdb.OperationMutate:
+		for _, m := range op.Mutations {
+			if m.Mutator == ovsdb.MutateOperationDelete && collects(schema, table.Column(m.Column)) {
+				columns = append(columns, m.Column)
+			}
+		}
+	}
+	columns = uniqueStrings(columns)
+	sort.Strings(columns)
+	return columns
+}
+
+// collectableColumns returns the sorted columns of a table with strong references to
+// non-root tables
+func collectableColumns(schema *ovsdb.DatabaseSchema, table *ovsdb.TableSchema) []string {
+	var columns []string
+	for _, name := range sortedNames(table.Columns) {
+		if collects(schema, table.Column(name)) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// collects reports whether a column holds strong references to a non-root table, whose rows
+// the commit collects once nothing refers to them
+func collects(schema *ovsdb.DatabaseSchema, column *ovsdb.ColumnSchema) bool {
+	for _, refTable := range refTables(column, ovsdb.Strong) {
+		if nonRoot(schema, refTable) {
+			return true
+		}
+	}
+	return false
+}
+
+// weakReferrers returns the sorted tables with columns holding weak references to a table
+func weakReferrers(schema *ovsdb.DatabaseSchema, tableName string) []string {
+	var tables []string
+	for _, name := range sortedNames(schema.Tables) {
+		for _, column := range schema.Table(name).Columns {
+			if slices.Contains(refTables(column, ovsdb.Weak), tableName) {
+				tables = append(tables, name)
+				break
+			}
+		}
+	}
+	return tables
+}
+
+// nonRoot reports whether a table is outside the root set, whose rows are garbage collected
+func nonRoot(schema *ovsdb.DatabaseSchema, table string) bool {
+	root, err := schema.IsRoot(table)
+	return err == nil && !root
+}
+
+// uniqueStrings drops repeated strings, keeping the first of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// refTables returns the tables the key and value of a column refer to with references of
+// the given type
+func refTables(column *ovsdb.ColumnSchema, refType ovsdb.RefType) []string {
+	if column == nil || column.TypeObj == nil {
+		return nil
+	}
+	var tables []string
+	for _, base := range []*ovsdb.BaseType{column.TypeObj.Key, column.TypeObj.Value} {
+		if refTable, kind := baseRef(base); refTable != "" && kind == refType {
+			tables = append(tables, refTable)
+		}
+	}
+	return tables
+}
+
+// baseRef returns the table a base type refers to and the type of the reference; an empty
+// table when it is not a reference
+func baseRef(base *ovsdb.BaseType) (string, ovsdb.RefType) {
+	if base == nil || base.Type != ovsdb.TypeUUID {
+		return "", ""
+	}
+	refTable, err := base.RefTable()
+	if err != nil || refTable == "" {
+		return "", ""
+	}
+	refType, err := base.RefType()
+	if err != nil {
+		return "", ""
+	}
+	return refTable, refType
+}
+
+// rowReferences lists the uuids a row refers to with references of the given type
+func rowReferences(table *ovsdb.TableSchema, row ovsdb.Row, refType ovsdb.RefType) []reference {
+	var refs []reference
+	for name, value := range row {
+		column := table.Column(name)
+		if column == nil || column.TypeObj == nil {
+			continue
+		}
+		add := func(base *ovsdb.BaseType, elem interface{}) {
+			refTable, kind := baseRef(base)
+			if uuid, ok := elem.(ovsdb.UUID); ok && refTable != "" && kind == refType {
+				refs = append(refs, reference{table: refTable, uuid: uuid.GoUUID})
+			}
+		}
+		if m, ok := value.(ovsdb.OvsMap); ok {
+			for k, v := range m.GoMap {
+				add(column.TypeObj.Key, k)
+				add(column.TypeObj.Value, v)
+			}
+			continue
+		}
+		for _, elem := range setElements(value) {
+			add(column.TypeObj.Key, elem)
+		}
+	}
+	return refs
+}
+
+// dropWeakReferences returns a row without its weak references to the removed rows, as the
+// commit leaves it, and whether anything was dropped. A map loses the pairs whose key or
+// value refers to a removed row.
+func dropWeakReferences(table *ovsdb.TableSchema, row ovsdb.Row, removed map[string]bool) (ovsdb.Row, bool) {
+	isRemoved := func(base *ovsdb.BaseType, elem interface{}) bool {
+		refTable, kind := baseRef(base)
+		uuid, ok := elem.(ovsdb.UUID)
+		return ok && refTable != "" && kind == ovsdb.Weak && removed[uuid.GoUUID]
+	}
+	var after ovsdb.Row
+	for name, value := range row {
+		column := table.Column(name)
+		if column == nil || column.TypeObj == nil {
+			continue
+		}
+		var kept interface{}
+		dropped := false
+		switch column.Type {
+		case ovsdb.TypeMap:
+			current, _ := value.(ovsdb.OvsMap)
+			goMap := make(map[interface{}]interface{}, len(current.GoMap))
+			for k, v := range current.GoMap {
+				if isRemoved(column.TypeObj.Key, k) || isRemoved(column.TypeObj.Value, v) {
+					dropped = true
+					continue
+				}
+				goMap[k] = v
+			}
+			kept = ovsdb.OvsMap{GoMap: goMap}
+		case ovsdb.TypeSet:
+			var set []interface{}
+			for _, elem := range setElements(value) {
+				if isRemoved(column.TypeObj.Key, elem) {
+					dropped = true
+					continue
+				}
+				set = append(set, elem)
+			}
+			kept = ovsdb.OvsSet{GoSet: set}
+		}
+		if !dropped {
+			continue
+		}
+		if after == nil {
+			after = make(ovsdb.Row, len(row))
+			for column, value := range row {
+				after[column] = value
+			}
+		}
+		after[name] = kept
+	}
+	if after == nil {
+		return row, false
+	}
+	return after, true
+}
+
+// collectionCandidates returns the non-root rows the commit may have garbage collected: rows
+// that lost a strong reference in the transaction, inserted rows, and the rows these refer
+// to in turn. Rows that existed before are only known from the snapshots.
+func collectionCandidates(schema *ovsdb.DatabaseSchema, tx *appliedTransaction, changes map[string]*rowChange, order []string) []reference {
+	snapshot := snapshotRows(tx)
+
+	var queue []reference
+	for _, uuid := range order {
+		change := changes[uuid]
+		table := schema.Table(change.table)
+		if change.before == nil {
+			if change.after != nil && nonRoot(schema, change.table) {
+				queue = append(queue, reference{table: change.table, uuid: uuid})
+			}
+			continue
+		}
+		kept := make(map[string]bool)
+		if change.after != nil {
+			for _, ref := range rowReferences(table, change.after, ovsdb.Strong) {
+				kept[ref.uuid] = true
+			}
+		}
+		for _, ref := range rowReferences(table, change.before, ovsdb.Strong) {
+			if !kept[ref.uuid] {
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var candidates []reference
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if seen[ref.uuid] || !nonRoot(schema, ref.table) {
+			continue
+		}
+		seen[ref.uuid] = true
+		var row ovsdb.Row
+		if change, ok := changes[ref.uuid]; ok {
+			if change.after == nil {
+				// Deleted by the transaction itself
+				continue
+			}
+			row = change.after
+		} else if row, ok = snapshot[ref.uuid]; !ok {
+			continue
+		}
+		candidates = append(candidates, ref)
+		queue = append(queue, rowReferences(schema.Table(ref.table), row, ovsdb.Strong)...)
+	}
+	return candidates
+}
+
+// snapshotRows indexes the rows of the snapshots by uuid
+func snapshotRows(tx *appliedTransaction) map[string]ovsdb.Row {
+	rows := make(map[string]ovsdb.Row)
+	for _, snapshot := range tx.snapshots {
+		for _, row := range snapshot {
+			if uuid, ok := row["_uuid"].(ovsdb.UUID); ok {
+				rows[uuid.GoUUID] = row
+			}
+		}
+	}
+	return rows
+}
+
+// commitChanges adds to changes what the commit did on its own: the collected rows, which
+// are gone, and the rows whose weak references to removed rows were dropped. Rows are
+// appended to order, which is returned.
+func commitChanges(schema *ovsdb.DatabaseSchema, tx *appliedTransaction, changes map[string]*rowChange, order []string, collected []reference) []string {
+	snapshot := snapshotRows(tx)
+	for _, ref := range collected {
+		if change, ok := changes[ref.uuid]; ok {
+			change.after = nil
+			continue
+		}
+		changes[ref.uuid] = &rowChange{table: ref.table, before: snapshot[ref.uuid]}
+		order = append(order, ref.uuid)
+	}
+
+	removed := make(map[string]bool)
+	for uuid, change := range changes {
+		if change.after == nil {
+			removed[uuid] = true
+		}
+	}
+	if len(removed) == 0 {
+		return order
+	}
+	for _, uuid := range order {
+		if change := changes[uuid]; change.after != nil {
+			change.after, _ = dropWeakReferences(schema.Table(change.table), change.after, removed)
+		}
+	}
+	for _, name := range sortedNames(tx.snapshots) {
+		table := schema.Table(name)
+		for _, row := range tx.snapshots[name] {
+			uuid, ok := row["_uuid"].(ovsdb.UUID)
+			if !ok {
+				continue
+			}
+			if _, ok := changes[uuid.GoUUID]; ok {
+				continue
+			}
+			if after, dropped := dropWeakReferences(table, row, removed); dropped {
+				changes[uuid.GoUUID] = &rowChange{table: name, before: row, after: after}
+				order = append(order, uuid.GoUUID)
+			}
+		}
+	}
+	return order
+}
+
+// collectedRows reads back which of the candidates no longer exist after the commit
+func (c *OVSDBClient) collectedRows(ctx context.Context, dbName string, candidates []reference) ([]reference, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	ops := make([]ovsdb.Operation, 0, len(candidates))
+	for _, ref := range candidates {
+		ops = append(ops, ovsdb.Operation{
+			Op:      ovsdb.OperationSelect,
+			Table:   ref.table,
+			Where:   uuidWhere(ref.uuid),
+			Columns: []string{"_uuid"},
+		})
+	}
+	results, err := c.Transact(ctx, dbName, ops...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up garbage collected rows: %w", err)
+	}
+	var collected []reference
+	for i, ref := range candidates {
+		if i >= len(results) || results[i].Error != "" {
+			return nil, fmt.Errorf("failed to look up row %s in %s", ref.uuid, ref.table)
+		}
+		if len(results[i].Rows) == 0 {
+			collected = append(collected, ref)
+		}
+	}
+	return collected, nil
+}
+
+// sortedNames returns the sorted keys of a map
+func sortedNames[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,158 @@
+package ovsdb
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPrompt describes a host key that is not yet in known_hosts and needs confirmation
+type HostKeyPrompt struct {
+	Host        string // host:port as dialed
+	Address     string // resolved remote address
+	KeyType     string
+	Fingerprint string // SHA256 fingerprint in the same format as ssh-keygen -l
+}
+
+// knownHostsMu serialises appends to known_hosts files across concurrent connections
+var knownHostsMu sync.Mutex
+
+// knownHostsPath returns the known_hosts file used for host key verification
+func (c *ConnectionConfig) knownHostsPath() (string, error) {
+	if c.KnownHostsFile != "" {
+		return c.KnownHostsFile, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ssh", "known_hosts"), nil
+}
+
+// hostKeyCallback verifies host keys against known_hosts. Unknown hosts are accepted only after
+// ConfirmHostKey approves them, and are then recorded; a key that differs from a recorded one
+// is always rejected.
+func (c *ConnectionConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	path, err := c.knownHostsPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		check, err := loadKnownHosts(path)
+		if err != nil {
+			return err
+		}
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key mismatch for %s (%s %s), refusing to connect: %w",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), err)
+		}
+
+		prompt := HostKeyPrompt{
+			Host:        hostname,
+			Address:     remote.String(),
+			KeyType:     key.Type(),
+			Fingerprint: ssh.FingerprintSHA256(key),
+		}
+		if c.ConfirmHostKey == nil || !c.ConfirmHostKey(prompt) {
+			return fmt.Errorf("host key for %s (%s %s) was not trusted", hostname, prompt.KeyType, prompt.Fingerprint)
+		}
+		if err := appendKnownHost(path, hostname, key); err != nil {
+			return fmt.Errorf("failed to record host key for %s: %w", hostname, err)
+		}
+		return nil
+	}, nil
+}
+
+// hostKeyAlgorithms returns the key algorithms already recorded for the host, so the server is
+// asked for a key type we can verify rather than whichever it prefers. Returns nil for unknown hosts.
+func (c *ConnectionConfig) hostKeyAlgorithms(addr string) []string {
+	path, err := c.knownHostsPath()
+	if err != nil {
+		return nil
+	}
+	check, err := loadKnownHosts(path)
+	if err != nil {
+		return nil
+	}
+	// Checking a throwaway key makes knownhosts report every key recorded for the host
+	var keyErr *knownhosts.KeyError
+	if err := check(addr, &net.TCPAddr{}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algos []string
+	seen := make(map[string]bool)
+	for _, known := range keyErr.Want {
+		for _, algo := range algorithmsForKeyType(known.Key.Type()) {
+			if !seen[algo] {
+				seen[algo] = true
+				algos = append(algos, algo)
+			}
+		}
+	}
+	return algos
+}
+
+// loadKnownHosts parses the known_hosts file; a missing file is treated as empty
+func loadKnownHosts(path string) (ssh.HostKeyCallback, error) {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	}
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts %s: %w", path, err)
+	}
+	return check, nil
+}
+
+// appendKnownHost records an accepted host key in known_hosts
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// algorithmsForKeyType maps a key type to the host key algorithms that can produce it
+func algorithmsForKeyType(keyType string) []string {
+	switch keyType {
+	case ssh.KeyAlgoRSA:
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	case ssh.CertAlgoRSAv01:
+		return []string{ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01, ssh.CertAlgoRSAv01}
+	default:
+		return []string{keyType}
+	}
+}
+
+// probeKey is a public key that never matches a known_hosts entry
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "probe" }
+func (probeKey) Marshal() []byte                     { return []byte("probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
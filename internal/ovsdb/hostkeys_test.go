@@ -0,0 +1,96 @@
+package ovsdb
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	var prompts []HostKeyPrompt
+	trust := false
+	config := &ConnectionConfig{
+		KnownHostsFile: path,
+		ConfirmHostKey: func(p HostKeyPrompt) bool {
+			prompts = append(prompts, p)
+			return trust
+		},
+	}
+	check, err := config.hostKeyCallback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, remote := "ovsdb.example:2222", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2222}
+	key := newHostKey(t)
+
+	// An unknown key that is not confirmed is refused and not recorded
+	if err := check(host, remote, key); err == nil || !strings.Contains(err.Error(), "was not trusted") {
+		t.Fatalf("unconfirmed key: got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("known_hosts was written for a rejected key")
+	}
+	want := HostKeyPrompt{Host: host, Address: remote.String(), KeyType: ssh.KeyAlgoED25519, Fingerprint: ssh.FingerprintSHA256(key)}
+	if len(prompts) != 1 || prompts[0] != want {
+		t.Errorf("prompts = %+v, want %+v", prompts, want)
+	}
+	if algos := config.hostKeyAlgorithms(host); algos != nil {
+		t.Errorf("hostKeyAlgorithms of an unknown host = %v", algos)
+	}
+
+	// Trust on first use records the key, which is then accepted without asking
+	trust = true
+	if err := check(host, remote, key); err != nil {
+		t.Fatalf("confirmed key: %v", err)
+	}
+	trust = false
+	if err := check(host, remote, key); err != nil {
+		t.Errorf("recorded key: %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Errorf("asked %d times, want 2", len(prompts))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), "[ovsdb.example]:2222 ssh-ed25519 ") {
+		t.Errorf("known_hosts = %q, %v", data, err)
+	}
+	if algos := config.hostKeyAlgorithms(host); !reflect.DeepEqual(algos, []string{ssh.KeyAlgoED25519}) {
+		t.Errorf("hostKeyAlgorithms = %v", algos)
+	}
+
+	// A different key for a recorded host is refused outright, even when trust is offered
+	trust = true
+	if err := check(host, remote, newHostKey(t)); err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Errorf("changed key: got %v", err)
+	}
+	if len(prompts) != 2 {
+		t.Errorf("asked to trust a changed key")
+	}
+
+	// Without a way to ask, unknown hosts are refused
+	config.ConfirmHostKey = nil
+	if err := check("other.example:22", remote, key); err == nil {
+		t.Errorf("accepted an unknown host without confirmation")
+	}
+}
@@ -0,0 +1,450 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// Monitor methods, in order of preference
+const (
+	methodMonitorCondSince = "monitor_cond_since"
+	methodMonitorCond      = "monitor_cond"
+	methodMonitor          = "monitor"
+)
+
+// zeroTxnID asks monitor_cond_since for the whole table
+const zeroTxnID = "00000000-0000-0000-0000-000000000000"
+
+// TableDelta is one batch of changes to a monitored table, reported through OnTableUpdate
+type TableDelta struct {
+	Database string
+	Table    string
+	// Reset means Inserted is the complete table and replaces the rows known so far, as
+	// after a reconnect that could not resume from the last transaction
+	Reset    bool
+	Inserted []TypedRow
+	Modified []TypedRow // complete rows after the change
+	Deleted  []string   // _uuid of the deleted rows
+}
+
+// tableMonitor is the row cache of one monitored table, keyed by _uuid
+type tableMonitor struct {
+	id      string // monitor id, unique across databases
+	db      string
+	table   string
+	schema  *ovsdb.TableSchema
+	rows    map[string]ovsdb.Row
+	lastTxn string // last transaction seen, for monitor_cond_since
+	ready   bool   // whether the monitor reply has been applied
+	version uint64 // counts the changes to rows, to tell when pages sorted from it are stale
+	// Notifications that arrive before the monitor reply is applied are replayed after it
+	pending []func(*TableDelta)
+}
+
+// MonitorTable starts monitoring a table of any database and returns its rows. Later
+// changes are reported through OnTableUpdate, and the monitor is resumed after a
+// reconnect, from the last transaction when the server supports monitor_cond_since.
+func (c *OVSDBClient) MonitorTable(ctx context.Context, dbName string, table string) ([]TypedRow, error) {
+	if c.file.Load() != nil {
+		// A database file never changes
+		return c.GetTableData(ctx, dbName, table)
+	}
+	if c.isPrimary(dbName) {
+		dbName = c.dbName
+	}
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	tableSchema, ok := schema.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found in %s", table, dbName)
+	}
+
+	c.monitorMu.Lock()
+	defer c.monitorMu.Unlock()
+	s := c.session
+	id := monitorID(dbName, table)
+
+	s.mu.Lock()
+	tm, ok := s.tables[id]
+	if ok && tm.ready {
+		rows := typedRows(tm.schema, tm.snapshot())
+		s.mu.Unlock()
+		return rows, nil
+	}
+	if !ok {
+		tm = &tableMonitor{id: id, db: dbName, table: table, schema: &tableSchema, rows: make(map[string]ovsdb.Row), lastTxn: zeroTxnID}
+		s.tables[id] = tm
+	}
+	s.mu.Unlock()
+
+	if err := c.startMonitor(ctx, tm, false); err != nil {
+		s.mu.Lock()
+		delete(s.tables, id)
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return typedRows(tm.schema, tm.snapshot()), nil
+}
+
+// CancelMonitor stops monitoring a table
+func (c *OVSDBClient) CancelMonitor(ctx context.Context, dbName string, table string) error {
+	if c.isPrimary(dbName) {
+		dbName = c.dbName
+	}
+	c.monitorMu.Lock()
+	defer c.monitorMu.Unlock()
+	s := c.session
+	if s == nil {
+		return nil
+	}
+	id := monitorID(dbName, table)
+	s.mu.Lock()
+	_, ok := s.tables[id]
+	delete(s.tables, id)
+	rpc := s.rpc
+	s.mu.Unlock()
+	if !ok || rpc == nil {
+		return nil
+	}
+	var reply []interface{}
+	if err := rpc.CallWithContext(ctx, "monitor_cancel", ovsdb.NewMonitorCancelArgs(id), &reply); err != nil {
+		return fmt.Errorf("failed to cancel monitor of %s: %w", table, err)
+	}
+	return nil
+}
+
+// monitorID names the monitor of a table; the database is part of it because several
+// databases share the session connection
+func monitorID(dbName, table string) string {
+	return dbName + "/" + table
+}
+
+// startMonitor sends the monitor request for a table, falling back from monitor_cond_since
+// to monitor_cond and monitor on servers that do not know the newer methods
+func (c *OVSDBClient) startMonitor(ctx context.Context, tm *tableMonitor, resume bool) error {
+	rpc, err := c.sessionConn(ctx)
+	if err != nil {
+		return err
+	}
+	s := c.session
+
+	s.mu.Lock()
+	tm.ready = false
+	tm.pending = nil
+	lastTxn := tm.lastTxn
+	methods := []string{methodMonitorCondSince, methodMonitorCond, methodMonitor}
+	for i, method := range methods {
+		if method == s.method {
+			methods = methods[i:]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	// Columns and select are spelled out; some servers do not apply the defaults
+	columns := make([]string, 0, len(tm.schema.Columns))
+	for column := range tm.schema.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	requests := map[string]ovsdb.MonitorRequest{tm.table: {
+		Columns: columns,
+		Select:  ovsdb.NewDefaultMonitorSelect(),
+	}}
+	var apply func(*TableDelta)
+	var method string
+	for _, method = range methods {
+		switch method {
+		case methodMonitorCondSince:
+			var reply exactCondSinceReply
+			err = rpc.CallWithContext(ctx, method, ovsdb.NewMonitorCondSinceArgs(tm.db, tm.id, requests, lastTxn), &reply)
+			apply = func(delta *TableDelta) {
+				if !reply.found {
+					tm.reset(delta)
+				}
+				tm.applyUpdates2(reply.updates.tableUpdates()[tm.table], delta)
+				tm.lastTxn = reply.lastTxn
+			}
+		case methodMonitorCond:
+			var reply exactUpdates2
+			err = rpc.CallWithContext(ctx, method, ovsdb.NewMonitorArgs(tm.db, tm.id, requests), &reply)
+			apply = func(delta *TableDelta) {
+				tm.reset(delta)
+				tm.applyUpdates2(reply.tableUpdates()[tm.table], delta)
+			}
+		case methodMonitor:
+			var reply exactUpdates
+			err = rpc.CallWithContext(ctx, method, ovsdb.NewMonitorArgs(tm.db, tm.id, requests), &reply)
+			apply = func(delta *TableDelta) {
+				tm.reset(delta)
+				tm.applyUpdates(reply.tableUpdates()[tm.table], delta)
+			}
+		}
+		// An error reply most likely means the server does not know the method
+		var serverErr rpc2.ServerError
+		if !errors.As(err, &serverErr) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to monitor %s: %w", tm.table, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.method = method
+	delta := &TableDelta{Database: tm.db, Table: tm.table}
+	apply(delta)
+	for _, update := range tm.pending {
+		update(delta)
+	}
+	tm.pending = nil
+	tm.ready = true
+	// The first reply is returned by MonitorTable; only a resumed monitor reports it
+	if resume {
+		c.emitDelta(delta)
+	}
+	return nil
+}
+
+// handleUpdate applies an update, update2 or update3 notification to its table
+func (c *OVSDBClient) handleUpdate(s *rpcSession, method string, params []json.RawMessage) error {
+	if len(params) < 2 {
+		return fmt.Errorf("invalid %s notification", method)
+	}
+	var id string
+	if err := json.Unmarshal(params[0], &id); err != nil {
+		return fmt.Errorf("invalid monitor id: %w", err)
+	}
+	// Some servers send monitor_cond_since updates as update2 with the transaction id
+	if method == methodMonitorCond && len(params) == 3 {
+		method = methodMonitorCondSince
+	}
+
+	var updates exactUpdates
+	var updates2 exactUpdates2
+	var lastTxn string
+	switch method {
+	case methodMonitor:
+		if err := json.Unmarshal(params[1], &updates); err != nil {
+			return err
+		}
+	case methodMonitorCond:
+		if err := json.Unmarshal(params[1], &updates2); err != nil {
+			return err
+		}
+	case methodMonitorCondSince:
+		if len(params) < 3 {
+			return fmt.Errorf("invalid %s notification", method)
+		}
+		if err := json.Unmarshal(params[1], &lastTxn); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(params[2], &updates2); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tm, ok := s.tables[id]
+	if !ok {
+		// Cancelled in the meantime
+		return nil
+	}
+	update := func(delta *TableDelta) {
+		if updates != nil {
+			tm.applyUpdates(updates.tableUpdates()[tm.table], delta)
+			return
+		}
+		tm.applyUpdates2(updates2.tableUpdates()[tm.table], delta)
+		if lastTxn != "" {
+			tm.lastTxn = lastTxn
+		}
+	}
+	if !tm.ready {
+		tm.pending = append(tm.pending, update)
+		return nil
+	}
+	delta := &TableDelta{Database: tm.db, Table: tm.table}
+	update(delta)
+	c.emitDelta(delta)
+	return nil
+}
+
+func (c *OVSDBClient) emitDelta(delta *TableDelta) {
+	if c.OnTableUpdate == nil {
+		return
+	}
+	if !delta.Reset && len(delta.Inserted) == 0 && len(delta.Modified) == 0 && len(delta.Deleted) == 0 {
+		return
+	}
+	c.OnTableUpdate(*delta)
+}
+
+// reset forgets every cached row; the rows applied next make up the whole table
+func (tm *tableMonitor) reset(delta *TableDelta) {
+	tm.rows = make(map[string]ovsdb.Row)
+	tm.version++
+	delta.Reset = true
+	delta.Inserted, delta.Modified, delta.Deleted = nil, nil, nil
+}
+
+// applyUpdates applies a monitor (version 1) update, where "new" holds the complete row
+func (tm *tableMonitor) applyUpdates(updates ovsdb.TableUpdate, delta *TableDelta) {
+	for uuid, update := range updates {
+		switch {
+		case update.New == nil:
+			tm.remove(uuid, delta)
+		default:
+			_, exists := tm.rows[uuid]
+			tm.rows[uuid] = *update.New
+			tm.record(uuid, !exists, delta)
+		}
+	}
+}
+
+// applyUpdates2 applies a monitor_cond update, where "modify" holds only the difference
+func (tm *tableMonitor) applyUpdates2(updates ovsdb.TableUpdate2, delta *TableDelta) {
+	for uuid, update := range updates {
+		switch {
+		case update.Initial != nil:
+			tm.rows[uuid] = *update.Initial
+			tm.record(uuid, true, delta)
+		case update.Insert != nil:
+			tm.rows[uuid] = *update.Insert
+			tm.record(uuid, true, delta)
+		case update.Modify != nil:
+			row, ok := tm.rows[uuid]
+			if !ok {
+				continue
+			}
+			modified := make(ovsdb.Row, len(row))
+			for column, value := range row {
+				modified[column] = value
+			}
+			for column, diff := range *update.Modify {
+				modified[column] = applyDiff(tm.schema.Column(column), row[column], diff)
+			}
+			tm.rows[uuid] = modified
+			tm.record(uuid, false, delta)
+		case update.Delete != nil:
+			tm.remove(uuid, delta)
+		}
+	}
+}
+
+func (tm *tableMonitor) record(uuid string, inserted bool, delta *TableDelta) {
+	tm.version++
+	row := typedRow(tm.schema, tm.row(uuid))
+	if inserted || delta.Reset {
+		delta.Inserted = append(delta.Inserted, row)
+	} else {
+		delta.Modified = append(delta.Modified, row)
+	}
+}
+
+func (tm *tableMonitor) remove(uuid string, delta *TableDelta) {
+	if _, ok := tm.rows[uuid]; !ok {
+		return
+	}
+	delete(tm.rows, uuid)
+	tm.version++
+	delta.Deleted = append(delta.Deleted, uuid)
+}
+
+// row returns a copy of a cached row with its _uuid, which monitor updates leave out
+func (tm *tableMonitor) row(uuid string) ovsdb.Row {
+	row := make(ovsdb.Row, len(tm.rows[uuid])+1)
+	for column, value := range tm.rows[uuid] {
+		row[column] = value
+	}
+	row["_uuid"] = ovsdb.UUID{GoUUID: uuid}
+	return row
+}
+
+func (tm *tableMonitor) snapshot() []ovsdb.Row {
+	rows := make([]ovsdb.Row, 0, len(tm.rows))
+	for uuid := range tm.rows {
+		rows = append(rows, tm.row(uuid))
+	}
+	return rows
+}
+
+// applyDiff applies an update2 "modify" value. Sets carry the elements to toggle, maps the
+// pairs to add, change or remove (a pair equal to the current one removes it), and any
+// other column the new value.
+func applyDiff(column *ovsdb.ColumnSchema, current, diff interface{}) interface{} {
+	if column == nil {
+		return diff
+	}
+	switch column.Type {
+	case ovsdb.TypeSet:
+		toggled := make(map[interface{}]bool)
+		for _, elem := range setElements(diff) {
+			toggled[elem] = !toggled[elem]
+		}
+		var result []interface{}
+		for _, elem := range setElements(current) {
+			if toggled[elem] {
+				delete(toggled, elem)
+				continue
+			}
+			result = append(result, elem)
+		}
+		for _, elem := range setElements(diff) {
+			if toggled[elem] {
+				delete(toggled, elem)
+				result = append(result, elem)
+			}
+		}
+		// Some servers send the new value of an optional column rather than the difference,
+		// which leaves the old and the new element; the new one, appended last, wins
+		if column.TypeObj != nil && column.TypeObj.Max() == 1 && len(result) > 1 {
+			result = result[len(result)-1:]
+		}
+		return ovsdb.OvsSet{GoSet: result}
+	case ovsdb.TypeMap:
+		result := make(map[interface{}]interface{})
+		if m, ok := current.(ovsdb.OvsMap); ok {
+			for k, v := range m.GoMap {
+				result[k] = v
+			}
+		}
+		if m, ok := diff.(ovsdb.OvsMap); ok {
+			for k, v := range m.GoMap {
+				if old, exists := result[k]; exists && old == v {
+					delete(result, k)
+				} else {
+					result[k] = v
+				}
+			}
+		}
+		return ovsdb.OvsMap{GoMap: result}
+	default:
+		return diff
+	}
+}
+
+// setElements returns the elements of a set column, which arrives as a bare value when it
+// holds exactly one element
+func setElements(v interface{}) []interface{} {
+	switch s := v.(type) {
+	case nil:
+		return nil
+	case ovsdb.OvsSet:
+		return s.GoSet
+	default:
+		return []interface{}{v}
+	}
+}
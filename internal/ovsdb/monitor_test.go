@@ -0,0 +1,164 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// testTable is a table schema with a column of each shape applyDiff distinguishes
+func testTable(t *testing.T) *ovsdb.TableSchema {
+	t.Helper()
+	var table ovsdb.TableSchema
+	err := json.Unmarshal([]byte(`{"columns": {
+		"name": {"type": "string"},
+		"ports": {"type": {"key": "string", "min": 0, "max": "unlimited"}},
+		"controller": {"type": {"key": "string", "min": 0, "max": 1}},
+		"external_ids": {"type": {"key": "string", "value": "string", "min": 0, "max": "unlimited"}}
+	}}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &table
+}
+
+func TestApplyDiff(t *testing.T) {
+	table := testTable(t)
+	set := func(elems ...interface{}) ovsdb.OvsSet { return ovsdb.OvsSet{GoSet: elems} }
+	tests := []struct {
+		name    string
+		column  string
+		current interface{}
+		diff    interface{}
+		want    interface{}
+	}{
+		{"set toggles elements", "ports", set("a", "b"), set("b", "c"), set("a", "c")},
+		{"set of one element", "ports", "a", set("a"), set()},
+		{"optional cleared", "controller", set("tcp:1"), set("tcp:1"), set()},
+		{"optional replaced by its new value", "controller", set("tcp:1"), set("tcp:2"), set("tcp:2")},
+		{"optional given as old and new", "controller", set("tcp:1"), set("tcp:1", "tcp:2"), set("tcp:2")},
+		{
+			"map pairs added, changed and removed", "external_ids",
+			ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"a": "1", "b": "2"}},
+			ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"a": "9", "b": "2", "c": "3"}},
+			ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"a": "9", "c": "3"}},
+		},
+		{"atom", "name", "br0", "br1", "br1"},
+		{"unknown column", "other", "x", "y", "y"},
+	}
+	for _, tt := range tests {
+		got := applyDiff(table.Column(tt.column), tt.current, tt.diff)
+		if s, ok := got.(ovsdb.OvsSet); ok && len(s.GoSet) == 0 {
+			got = set()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: applyDiff = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyUpdates2(t *testing.T) {
+	tm := &tableMonitor{table: "Bridge", schema: testTable(t), rows: make(map[string]ovsdb.Row)}
+	delta := &TableDelta{}
+	tm.applyUpdates2(ovsdb.TableUpdate2{
+		"u1": {Initial: &ovsdb.Row{"name": "br0"}},
+		"u2": {Insert: &ovsdb.Row{"name": "br1"}},
+	}, delta)
+	if len(delta.Inserted) != 2 || len(delta.Modified) != 0 || len(tm.rows) != 2 {
+		t.Fatalf("initial rows gave %+v", delta)
+	}
+
+	before := tm.rows["u1"]
+	version := tm.version
+	delta = &TableDelta{}
+	tm.applyUpdates2(ovsdb.TableUpdate2{
+		"u1": {Modify: &ovsdb.Row{"external_ids": ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"k": "v"}}}},
+		"u2": {Delete: &ovsdb.Row{}},
+		"u3": {Modify: &ovsdb.Row{"name": "unknown"}},
+	}, delta)
+	if len(delta.Modified) != 1 || !reflect.DeepEqual(delta.Deleted, []string{"u2"}) || len(delta.Inserted) != 0 {
+		t.Fatalf("changes gave %+v", delta)
+	}
+	row := delta.Modified[0]
+	if row["_uuid"].Atom.Value != "u1" || row["name"].Atom.Value != "br0" || row["external_ids"].Plain().(map[string]interface{})["k"] != "v" {
+		t.Errorf("modified row = %+v", row)
+	}
+	if _, ok := before["external_ids"]; ok {
+		t.Error("modify changed the row cached before it")
+	}
+	if tm.version != version+2 {
+		t.Errorf("version went from %d to %d after two changes", version, tm.version)
+	}
+
+	// After a reset the rows that follow are the whole table
+	delta = &TableDelta{}
+	tm.reset(delta)
+	tm.applyUpdates2(ovsdb.TableUpdate2{"u4": {Initial: &ovsdb.Row{"name": "br4"}}}, delta)
+	if !delta.Reset || len(delta.Inserted) != 1 || len(tm.rows) != 1 {
+		t.Errorf("reset gave %+v with %d rows", delta, len(tm.rows))
+	}
+}
+
+// waitDelta returns the next change to a monitored table
+func waitDelta(t *testing.T, deltas <-chan TableDelta) TableDelta {
+	t.Helper()
+	select {
+	case delta := <-deltas:
+		return delta
+	case <-time.After(10 * time.Second):
+		t.Fatal("no table update")
+		return TableDelta{}
+	}
+}
+
+func TestMonitorTable(t *testing.T) {
+	sock := startServer(t)
+	deltas := make(chan TableDelta, 100)
+	c := &OVSDBClient{OnTableUpdate: func(delta TableDelta) { deltas <- delta }}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	transact := func(op ovsdb.Operation) {
+		t.Helper()
+		results, err := c.Transact(ctx, "", op)
+		if err != nil || results[0].Error != "" {
+			t.Fatalf("%s failed: %v %+v", op.Op, err, results)
+		}
+	}
+	where := []ovsdb.Condition{{Column: "name", Function: ovsdb.ConditionEqual, Value: "br0"}}
+
+	transact(ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": "br0"}})
+	rows, err := c.MonitorTable(ctx, "", "Bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["name"].Atom.Value != "br0" {
+		t.Fatalf("monitor returned %+v", rows)
+	}
+
+	transact(ovsdb.Operation{Op: ovsdb.OperationMutate, Table: "Bridge", Where: where, Mutations: []ovsdb.Mutation{{
+		Column: "external_ids", Mutator: ovsdb.MutateOperationInsert, Value: ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"owner": "test"}},
+	}}})
+	delta := waitDelta(t, deltas)
+	if delta.Table != "Bridge" || delta.Database != "Open_vSwitch" || len(delta.Modified) != 1 {
+		t.Fatalf("mutate gave %+v", delta)
+	}
+	if ids := delta.Modified[0]["external_ids"].Plain().(map[string]interface{}); ids["owner"] != "test" || delta.Modified[0]["name"].Atom.Value != "br0" {
+		t.Errorf("modified row = %+v", delta.Modified[0])
+	}
+	uuid := delta.Modified[0]["_uuid"].Atom.Value
+
+	// A second request is served from the cache
+	rows, err = c.MonitorTable(ctx, "", "Bridge")
+	if err != nil || len(rows) != 1 || rows[0]["external_ids"].Plain().(map[string]interface{})["owner"] != "test" {
+		t.Fatalf("cached monitor returned %+v, %v", rows, err)
+	}
+
+	transact(ovsdb.Operation{Op: ovsdb.OperationDelete, Table: "Bridge", Where: where})
+	if delta := waitDelta(t, deltas); len(delta.Deleted) != 1 || delta.Deleted[0] != uuid {
+		t.Fatalf("delete gave %+v", delta)
+	}
+}
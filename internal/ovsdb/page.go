@@ -0,0 +1,384 @@
+package ovsdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// SortKey orders table rows by one column
+type SortKey struct {
+	Column     string `json:"column"`
+	Descending bool   `json:"descending,omitempty"`
+}
+
+// PageRequest selects a window of a table's rows
+type PageRequest struct {
+	Database string
+	Table    string
+	Columns  []string // empty for every column; _uuid is always included
+	Sort     []SortKey
+	Offset   int
+	Limit    int // 0 for every row after Offset
+}
+
+// TablePage is a window of a table's rows together with the number of rows in the table
+type TablePage struct {
+	Rows  []TypedRow `json:"rows"`
+	Total int        `json:"total"`
+}
+
+// resultSet is the outcome of the last select of a table, kept for paging through it
+type resultSet struct {
+	columns string // the selected columns, comma separated; empty for every column
+	order   string // the sort order of the last page read from it
+	rows    []ovsdb.Row
+}
+
+// sortedResult is the rows of a table in one sort order, valid while their source, a
+// table monitor or a result set, is unchanged
+type sortedResult struct {
+	source  interface{}
+	version uint64 // the monitor's version when the rows were sorted
+	rows    []ovsdb.Row
+}
+
+// maxSortOrders bounds the sort orders cached for each table
+const maxSortOrders = 8
+
+// GetTablePage returns one page of a table, sorted and projected as requested. Monitored
+// tables are paged from the monitor cache; others from the result of the last select,
+// which a request for the first page in the same order refreshes. The sorted order is
+// kept until the rows change.
+func (c *OVSDBClient) GetTablePage(ctx context.Context, req PageRequest) (*TablePage, error) {
+	if c.isPrimary(req.Database) {
+		req.Database = c.dbName
+	}
+	table, err := c.tableSchema(ctx, req.Database, req.Table)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range req.Columns {
+		if table.Column(column) == nil {
+			return nil, fmt.Errorf("column %s not found", column)
+		}
+	}
+	for _, key := range req.Sort {
+		if table.Column(key.Column) == nil {
+			return nil, fmt.Errorf("sort column %s not found", key.Column)
+		}
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return nil, fmt.Errorf("invalid page offset %d and limit %d", req.Offset, req.Limit)
+	}
+
+	rows, err := c.pageRows(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TablePage{Rows: []TypedRow{}, Total: len(rows)}
+	if req.Offset >= len(rows) {
+		return page, nil
+	}
+	end := len(rows)
+	if req.Limit > 0 && req.Offset+req.Limit < end {
+		end = req.Offset + req.Limit
+	}
+	for _, row := range rows[req.Offset:end] {
+		page.Rows = append(page.Rows, typedRow(table, projectRow(row, req.Columns)))
+	}
+	return page, nil
+}
+
+// pageRows returns every row of the table, in the requested order, with at least the
+// requested and sort columns
+func (c *OVSDBClient) pageRows(ctx context.Context, req PageRequest) ([]ovsdb.Row, error) {
+	id := monitorID(req.Database, req.Table)
+	order := sortOrder(req.Sort)
+	s := c.session
+	s.mu.Lock()
+	if tm, ok := s.tables[id]; ok && tm.ready {
+		version := tm.version
+		s.mu.Unlock()
+		if rows := c.cachedOrder(id, order, tm, version); rows != nil {
+			return rows, nil
+		}
+		s.mu.Lock()
+		version, rows := tm.version, tm.snapshot()
+		s.mu.Unlock()
+		return c.cacheOrder(id, order, tm, version, sortedRows(rows, req.Sort)), nil
+	}
+	s.mu.Unlock()
+
+	var columns []string
+	if len(req.Columns) > 0 {
+		needed := map[string]bool{"_uuid": true}
+		for _, column := range req.Columns {
+			needed[column] = true
+		}
+		for _, key := range req.Sort {
+			needed[key.Column] = true
+		}
+		for column := range needed {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+	}
+	key := strings.Join(columns, ",")
+
+	c.pageMu.Lock()
+	cached, ok := c.results[id]
+	refresh := !ok || cached.columns != key || (req.Offset == 0 && cached.order == order)
+	if !refresh {
+		cached.order = order
+	}
+	c.pageMu.Unlock()
+
+	if refresh {
+		rows, err := c.selectRaw(ctx, req.Database, ovsdb.Operation{
+			Op:      ovsdb.OperationSelect,
+			Table:   req.Table,
+			Where:   []ovsdb.Condition{},
+			Columns: columns,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cached = &resultSet{columns: key, order: order, rows: rows}
+		c.pageMu.Lock()
+		if c.results == nil {
+			c.results = make(map[string]*resultSet)
+		}
+		c.results[id] = cached
+		c.pageMu.Unlock()
+	}
+	if rows := c.cachedOrder(id, order, cached, 0); rows != nil {
+		return rows, nil
+	}
+	// sortedRows sorts a copy, the result set may be read concurrently
+	return c.cacheOrder(id, order, cached, 0, sortedRows(cached.rows, req.Sort)), nil
+}
+
+// sortOrder names a sort order, as the key of the sorted rows cached for it
+func sortOrder(keys []SortKey) string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.Column
+		if key.Descending {
+			names[i] = "-" + key.Column
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// cachedOrder returns the rows of a table sorted in an order, if they were sorted since
+// their source last changed
+func (c *OVSDBClient) cachedOrder(id, order string, source interface{}, version uint64) []ovsdb.Row {
+	c.pageMu.Lock()
+	defer c.pageMu.Unlock()
+	sorted, ok := c.sorted[id][order]
+	if !ok || sorted.source != source || sorted.version != version {
+		return nil
+	}
+	return sorted.rows
+}
+
+// cacheOrder keeps the rows of a table sorted in an order, dropping the orders sorted
+// from an older version of the rows, and returns them
+func (c *OVSDBClient) cacheOrder(id, order string, source interface{}, version uint64, rows []ovsdb.Row) []ovsdb.Row {
+	c.pageMu.Lock()
+	defer c.pageMu.Unlock()
+	if c.sorted == nil {
+		c.sorted = make(map[string]map[string]*sortedResult)
+	}
+	orders := c.sorted[id]
+	if orders == nil {
+		orders = make(map[string]*sortedResult)
+		c.sorted[id] = orders
+	}
+	for name, sorted := range orders {
+		if sorted.source != source || sorted.version != version || len(orders) >= maxSortOrders {
+			delete(orders, name)
+		}
+	}
+	orders[order] = &sortedResult{source: source, version: version, rows: rows}
+	return rows
+}
+
+// resetPages forgets the result sets and sorted rows kept for GetTablePage
+func (c *OVSDBClient) resetPages() {
+	c.pageMu.Lock()
+	c.results = nil
+	c.sorted = nil
+	c.pageMu.Unlock()
+}
+
+// sortedRows returns rows ordered by the sort keys, then by _uuid so that pages are stable
+// whatever order the rows arrived in. Only the sort columns are compared.
+func sortedRows(rows []ovsdb.Row, keys []SortKey) []ovsdb.Row {
+	values := make([][]interface{}, len(rows))
+	order := make([]int, len(rows))
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(keys)+1)
+		for k, key := range keys {
+			values[i][k] = normalizeValue(row[key.Column])
+		}
+		values[i][len(keys)] = normalizeValue(row["_uuid"])
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rowLess(values[order[i]], values[order[j]], keys)
+	})
+	sorted := make([]ovsdb.Row, len(rows))
+	for i, index := range order {
+		sorted[i] = rows[index]
+	}
+	return sorted
+}
+
+// rowLess reports whether a row sorts before another, given the values of their sort
+// columns followed by their _uuid
+func rowLess(a, b []interface{}, keys []SortKey) bool {
+	for i, key := range keys {
+		cmp := compareValues(a[i], b[i])
+		if cmp == 0 {
+			continue
+		}
+		if key.Descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	return compareValues(a[len(keys)], b[len(keys)]) < 0
+}
+
+// compareValues orders normalized column values: empty sets first, then booleans, numbers,
+// strings, and sets or maps by their elements. Sets of one element compare as that element.
+func compareValues(a, b interface{}) int {
+	a, b = unwrapSingle(a), unwrapSingle(b)
+	if ra, rb := valueRank(a), valueRank(b); ra != rb {
+		return ra - rb
+	}
+	switch av := a.(type) {
+	case bool:
+		bv := b.(bool)
+		switch {
+		case av == bv:
+			return 0
+		case !av:
+			return -1
+		default:
+			return 1
+		}
+	case float64:
+		return compareFloat(av, toFloat(b))
+	case int:
+		if bv, ok := b.(int); ok {
+			return compareInt(av, bv)
+		}
+		return compareFloat(float64(av), toFloat(b))
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if cmp := compareValues(av[i], bv[i]); cmp != 0 {
+				return cmp
+			}
+		}
+		return len(av) - len(bv)
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+func unwrapSingle(v interface{}) interface{} {
+	if set, ok := v.([]interface{}); ok {
+		switch len(set) {
+		case 0:
+			return nil
+		case 1:
+			return set[0]
+		}
+	}
+	return v
+}
+
+func valueRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64, int:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+// toInt returns an integer atom, which may have been decoded as a float64
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// projectRow keeps the requested columns of a row, and its _uuid
+func projectRow(row ovsdb.Row, columns []string) ovsdb.Row {
+	if len(columns) == 0 {
+		return row
+	}
+	projected := make(ovsdb.Row, len(columns)+1)
+	projected["_uuid"] = row["_uuid"]
+	for _, column := range columns {
+		if value, ok := row[column]; ok {
+			projected[column] = value
+		}
+	}
+	return projected
+}
@@ -0,0 +1,128 @@
+package ovsdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestCompareValues(t *testing.T) {
+	// Each value sorts before the next
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		-1,
+		0.5,
+		1,
+		[]interface{}{2.0},
+		"a",
+		[]interface{}{"b"},
+		"c",
+		[]interface{}{"a", "b"},
+		[]interface{}{"a", "c"},
+		[]interface{}{"a", "c", "d"},
+	}
+	for i := range ordered {
+		for j := range ordered {
+			if got := compareValues(ordered[i], ordered[j]); compareInt(got, 0) != compareInt(i, j) {
+				t.Errorf("compareValues(%v, %v) = %d", ordered[i], ordered[j], got)
+			}
+		}
+	}
+	// An empty set is an absent optional
+	if got := compareValues([]interface{}{}, nil); got != 0 {
+		t.Errorf("empty set compared to nil = %d", got)
+	}
+}
+
+func TestGetTablePage(t *testing.T) {
+	sock := startServer(t)
+	deltas := make(chan TableDelta, 10)
+	c := &OVSDBClient{OnTableUpdate: func(delta TableDelta) { deltas <- delta }}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	insert := func(name, datapath string) {
+		t.Helper()
+		results, err := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": name, "datapath_type": datapath}})
+		if err != nil || results[0].Error != "" {
+			t.Fatalf("failed to insert %s: %v %+v", name, err, results)
+		}
+	}
+	for _, name := range []string{"br3", "br1", "br4", "br0", "br2"} {
+		datapath := "system"
+		if name == "br1" || name == "br4" {
+			datapath = "netdev"
+		}
+		insert(name, datapath)
+	}
+	names := func(page *TablePage) []interface{} {
+		var names []interface{}
+		for _, row := range page.Rows {
+			names = append(names, row["name"].Atom.Value)
+		}
+		return names
+	}
+	page := func(req PageRequest) *TablePage {
+		t.Helper()
+		req.Table = "Bridge"
+		p, err := c.GetTablePage(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	byName := []SortKey{{Column: "name"}}
+	p := page(PageRequest{Columns: []string{"name"}, Sort: byName, Offset: 1, Limit: 2})
+	if p.Total != 5 || !reflect.DeepEqual(names(p), []interface{}{"br1", "br2"}) {
+		t.Errorf("second page by name = %v of %d", names(p), p.Total)
+	}
+	for _, row := range p.Rows {
+		if len(row) != 2 || row["_uuid"].Atom == nil {
+			t.Errorf("projected row = %+v", row)
+		}
+	}
+	p = page(PageRequest{Sort: []SortKey{{Column: "datapath_type", Descending: true}, {Column: "name"}}})
+	if want := []interface{}{"br0", "br2", "br3", "br1", "br4"}; !reflect.DeepEqual(names(p), want) {
+		t.Errorf("rows by datapath type = %v, want %v", names(p), want)
+	}
+	if p := page(PageRequest{Offset: 5}); p.Total != 5 || len(p.Rows) != 0 {
+		t.Errorf("page past the end = %+v", p)
+	}
+	for _, req := range []PageRequest{
+		{Table: "Bridge", Columns: []string{"other"}},
+		{Table: "Bridge", Sort: []SortKey{{Column: "other"}}},
+		{Table: "Bridge", Offset: -1},
+		{Table: "Other"},
+	} {
+		if _, err := c.GetTablePage(ctx, req); err == nil {
+			t.Errorf("request %+v succeeded", req)
+		}
+	}
+
+	// Unmonitored tables are paged from the last select, which a request for the first page
+	// in the same order refreshes
+	insert("br5", "system")
+	if p := page(PageRequest{Sort: byName, Offset: 2, Limit: 2}); p.Total != 5 {
+		t.Errorf("later page saw %d rows, not those of the last select", p.Total)
+	}
+	if p := page(PageRequest{Sort: byName, Limit: 2}); p.Total != 6 {
+		t.Errorf("first page saw %d rows after an insert", p.Total)
+	}
+
+	// Monitored tables are paged from the monitor cache, sorted again once it changes
+	if _, err := c.MonitorTable(ctx, "", "Bridge"); err != nil {
+		t.Fatal(err)
+	}
+	if p := page(PageRequest{Sort: byName, Offset: 4}); !reflect.DeepEqual(names(p), []interface{}{"br4", "br5"}) {
+		t.Errorf("monitored rows from offset 4 = %v", names(p))
+	}
+	insert("br45", "system")
+	waitDelta(t, deltas)
+	if p := page(PageRequest{Sort: byName, Offset: 4}); p.Total != 7 || !reflect.DeepEqual(names(p), []interface{}{"br4", "br45", "br5"}) {
+		t.Errorf("monitored rows from offset 4 after an insert = %v of %d", names(p), p.Total)
+	}
+}
@@ -0,0 +1,232 @@
+package ovsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// Condition is a where-clause on one column. Value is a plain value: a string for a uuid,
+// a list for a set and an object for a map. Numbers and
+// booleans may also be given as strings, as typed into a filter box.
+type Condition struct {
+	Column   string      `json:"column"`
+	Function string      `json:"function"`
+	Value    interface{} `json:"value"`
+}
+
+// whereClause translates conditions into OVSDB conditions, encoding every value for the
+// type of its column
+func whereClause(table *ovsdb.TableSchema, conditions []Condition) ([]ovsdb.Condition, error) {
+	where := make([]ovsdb.Condition, 0, len(conditions))
+	for _, cond := range conditions {
+		column := table.Column(cond.Column)
+		if column == nil {
+			return nil, fmt.Errorf("column %s not found", cond.Column)
+		}
+		function := ovsdb.ConditionFunction(cond.Function)
+		switch function {
+		case ovsdb.ConditionEqual, ovsdb.ConditionNotEqual, ovsdb.ConditionIncludes, ovsdb.ConditionExcludes:
+		case ovsdb.ConditionLessThan, ovsdb.ConditionLessThanOrEqual, ovsdb.ConditionGreaterThan, ovsdb.ConditionGreaterThanOrEqual:
+			// RFC 7047 only orders single integers and reals
+			if base := baseType(column); column.Type == ovsdb.TypeSet || column.Type == ovsdb.TypeMap ||
+				(base.Type != ovsdb.TypeInteger && base.Type != ovsdb.TypeReal) {
+				return nil, fmt.Errorf("function %s is not valid for column %s of type %s", function, cond.Column, typeName(column))
+			}
+		default:
+			return nil, fmt.Errorf("unknown function %q for column %s", cond.Function, cond.Column)
+		}
+		value, err := encodeValue(column, cond.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for column %s of type %s: %w", cond.Column, typeName(column), err)
+		}
+		where = append(where, ovsdb.NewCondition(cond.Column, function, value))
+	}
+	return where, nil
+}
+
+// encodeValue converts a value decoded from JSON into the wire form of a column
+func encodeValue(column *ovsdb.ColumnSchema, v interface{}) (interface{}, error) {
+	switch column.Type {
+	case ovsdb.TypeMap:
+		pairs, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %T", v)
+		}
+		goMap := make(map[interface{}]interface{}, len(pairs))
+		for k, val := range pairs {
+			key, err := encodeAtom(column.TypeObj.Key, k)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k, err)
+			}
+			value, err := encodeAtom(column.TypeObj.Value, val)
+			if err != nil {
+				return nil, fmt.Errorf("value of %q: %w", k, err)
+			}
+			goMap[key] = value
+		}
+		return ovsdb.OvsMap{GoMap: goMap}, nil
+	case ovsdb.TypeSet:
+		var elems []interface{}
+		switch e := v.(type) {
+		case nil:
+		case []interface{}:
+			elems = e
+		default:
+			// A single element stands for a set of one
+			elems = []interface{}{e}
+		}
+		set := make([]interface{}, 0, len(elems))
+		for _, elem := range elems {
+			atom, err := encodeAtom(column.TypeObj.Key, elem)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, atom)
+		}
+		return ovsdb.OvsSet{GoSet: set}, nil
+	default:
+		return encodeAtom(baseType(column), v)
+	}
+}
+
+// encodeAtom converts a single atomic value and checks it against the enum, if any
+func encodeAtom(base *ovsdb.BaseType, v interface{}) (interface{}, error) {
+	var atom interface{}
+	switch base.Type {
+	case ovsdb.TypeInteger:
+		i, err := integerAtom(v)
+		if err != nil {
+			return nil, err
+		}
+		atom = i
+	case ovsdb.TypeReal:
+		switch n := v.(type) {
+		case float64:
+			atom = n
+		case int:
+			atom = float64(n)
+		case int64:
+			atom = float64(n)
+		case json.Number:
+			f, err := n.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a number", n)
+			}
+			atom = f
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", n)
+			}
+			atom = f
+		default:
+			return nil, fmt.Errorf("expected a number, got %T", v)
+		}
+	case ovsdb.TypeBoolean:
+		switch b := v.(type) {
+		case bool:
+			atom = b
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(b))
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", b)
+			}
+			atom = parsed
+		default:
+			return nil, fmt.Errorf("expected a boolean, got %T", v)
+		}
+	case ovsdb.TypeString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %T", v)
+		}
+		atom = s
+	case ovsdb.TypeUUID:
+		switch u := v.(type) {
+		case ovsdb.UUID:
+			atom = u
+		case string:
+			if !ovsdb.IsValidUUID(u) {
+				return nil, fmt.Errorf("%q is not a uuid", u)
+			}
+			atom = ovsdb.UUID{GoUUID: u}
+		default:
+			return nil, fmt.Errorf("expected a uuid, got %T", v)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", base.Type)
+	}
+
+	if len(base.Enum) > 0 {
+		for _, allowed := range base.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(atom) {
+				return atom, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not one of %v", atom, base.Enum)
+	}
+	return atom, nil
+}
+
+// integerAtom converts an integer given as a number or a string. Numbers decoded as
+// float64 are refused beyond maxSafeInteger, where they may no longer hold the integer that
+// was typed; larger integers must arrive as json.Number, int64 or a string.
+func integerAtom(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return int(i), nil
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("%s is not an integer", n)
+		}
+		return integerAtom(f)
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("%v is not an integer", n)
+		}
+		if math.Abs(n) > maxSafeInteger {
+			return 0, fmt.Errorf("%v is out of the range of exact integers", n)
+		}
+		return int(n), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", n)
+		}
+		return int(i), nil
+	default:
+		return 0, fmt.Errorf("expected an integer, got %T", v)
+	}
+}
+
+// baseType returns the type of a column's keys, or of its only value
+func baseType(column *ovsdb.ColumnSchema) *ovsdb.BaseType {
+	if column.TypeObj == nil || column.TypeObj.Key == nil {
+		// _uuid and _version have no type object
+		return &ovsdb.BaseType{Type: column.Type}
+	}
+	return column.TypeObj.Key
+}
+
+// typeName describes a column type for error messages
+func typeName(column *ovsdb.ColumnSchema) string {
+	switch column.Type {
+	case ovsdb.TypeMap:
+		return fmt.Sprintf("map of %s to %s", column.TypeObj.Key.Type, column.TypeObj.Value.Type)
+	case ovsdb.TypeSet:
+		return fmt.Sprintf("set of %s", column.TypeObj.Key.Type)
+	default:
+		return baseType(column).Type
+	}
+}
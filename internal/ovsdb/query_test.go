@@ -0,0 +1,128 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestWhereClause(t *testing.T) {
+	var table ovsdb.TableSchema
+	err := json.Unmarshal([]byte(`{"columns": {
+		"name": {"type": "string"},
+		"ofport": {"type": {"key": "integer", "min": 0, "max": 1}},
+		"tag": {"type": {"key": {"type": "integer", "minInteger": 0, "maxInteger": 4095}}},
+		"weight": {"type": "real"},
+		"enabled": {"type": "boolean"},
+		"fail_mode": {"type": {"key": {"type": "string", "enum": ["set", ["standalone", "secure"]]}}},
+		"ports": {"type": {"key": {"type": "uuid", "refTable": "Port"}, "min": 0, "max": "unlimited"}},
+		"external_ids": {"type": {"key": "string", "value": "string", "min": 0, "max": "unlimited"}}
+	}}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const uuid = "0c8a4c3e-5f5e-4b6f-8a6c-1f2d3e4f5a6b"
+	set := func(elems ...interface{}) ovsdb.OvsSet { return ovsdb.OvsSet{GoSet: elems} }
+
+	tests := []struct {
+		name string
+		cond Condition
+		want interface{} // wire value, or the text of the error
+	}{
+		{"string", Condition{"name", "==", "br0"}, "br0"},
+		{"integer from a number", Condition{"tag", "<", 10.0}, 10},
+		{"integer from text", Condition{"tag", ">=", " 42 "}, 42},
+		{"integer from json.Number", Condition{"tag", "==", json.Number("9007199254740993")}, 9007199254740993},
+		{"optional integer", Condition{"ofport", "==", 1.0}, set(1)},
+		{"real from text", Condition{"weight", ">", "0.5"}, 0.5},
+		{"real from an integer", Condition{"weight", "<=", json.Number("2")}, 2.0},
+		{"boolean from text", Condition{"enabled", "==", "true"}, true},
+		{"enum", Condition{"fail_mode", "!=", "secure"}, "secure"},
+		{"uuid set from one element", Condition{"ports", "includes", uuid}, set(ovsdb.UUID{GoUUID: uuid})},
+		{"uuid set from a list", Condition{"ports", "excludes", []interface{}{uuid}}, set(ovsdb.UUID{GoUUID: uuid})},
+		{"empty set", Condition{"ports", "==", nil}, ovsdb.OvsSet{GoSet: []interface{}{}}},
+		{"map", Condition{"external_ids", "includes", map[string]interface{}{"owner": "ovn"}}, ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"owner": "ovn"}}},
+		{"_uuid", Condition{"_uuid", "==", uuid}, ovsdb.UUID{GoUUID: uuid}},
+
+		{"unknown column", Condition{"other", "==", "x"}, "column other not found"},
+		{"unknown function", Condition{"name", "~", "x"}, `unknown function "~"`},
+		{"ordering a string", Condition{"name", "<", "x"}, "not valid for column name of type string"},
+		{"ordering a set", Condition{"ofport", "<", 1.0}, "not valid for column ofport of type set of integer"},
+		{"fraction for an integer", Condition{"tag", "==", 1.5}, "1.5 is not an integer"},
+		{"inexact integer", Condition{"tag", "==", 9007199254740993.0}, "out of the range of exact integers"},
+		{"inexact json.Number", Condition{"tag", "==", json.Number("1e300")}, "out of the range of exact integers"},
+		{"integer text", Condition{"tag", "==", "ten"}, `"ten" is not an integer`},
+		{"boolean text", Condition{"enabled", "==", "yes"}, `"yes" is not a boolean`},
+		{"value outside the enum", Condition{"fail_mode", "==", "open"}, "open is not one of"},
+		{"malformed uuid", Condition{"ports", "includes", "port1"}, `"port1" is not a uuid`},
+		{"map from a list", Condition{"external_ids", "==", []interface{}{"owner"}}, "expected an object"},
+		{"number for a string", Condition{"name", "==", 1.0}, "expected a string, got float64"},
+	}
+	for _, tt := range tests {
+		where, err := whereClause(&table, []Condition{tt.cond})
+		if text, ok := tt.want.(string); ok && err != nil {
+			if !strings.Contains(err.Error(), text) {
+				t.Errorf("%s: error %q does not mention %q", tt.name, err, text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := ovsdb.NewCondition(tt.cond.Column, ovsdb.ConditionFunction(tt.cond.Function), tt.want)
+		if !reflect.DeepEqual(where, []ovsdb.Condition{want}) {
+			t.Errorf("%s: where = %#v, want %#v", tt.name, where, want)
+		}
+	}
+}
+
+func TestIntegerAtom(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want int
+		ok   bool
+	}{
+		{3, 3, true},
+		{int64(1) << 60, 1 << 60, true},
+		{json.Number("-12"), -12, true},
+		{json.Number("1.0"), 1, true},
+		{json.Number("1.5"), 0, false},
+		{json.Number("x"), 0, false},
+		{float64(maxSafeInteger), maxSafeInteger, true},
+		{-float64(maxSafeInteger), -maxSafeInteger, true},
+		{float64(maxSafeInteger) + 1, 0, false},
+		{"1152921504606846976", 1 << 60, true},
+		{"99999999999999999999", 0, false},
+		{true, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := integerAtom(tt.v)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("integerAtom(%#v) = %d, %v", tt.v, got, err)
+		}
+	}
+}
+
+func TestGetTableDataConditions(t *testing.T) {
+	sock := startServer(t)
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	for _, name := range []string{"br0", "br1"} {
+		if _, err := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": name}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := c.GetTableData(ctx, "", "Bridge", Condition{Column: "name", Function: "!=", Value: "br0"})
+	if err != nil || len(rows) != 1 || rows[0]["name"].Atom.Value != "br1" {
+		t.Errorf("rows = %+v, %v", rows, err)
+	}
+	if _, err := c.GetTableData(ctx, "", "Bridge", Condition{Column: "name", Function: "<", Value: "br0"}); err == nil {
+		t.Error("ordering condition on a string column was sent")
+	}
+}
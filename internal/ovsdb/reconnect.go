@@ -0,0 +1,237 @@
+package ovsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	ovsdbclient "github.com/ovn-kubernetes/libovsdb/client"
+)
+
+// Connection states reported through OVSDBClient.OnStateChange
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateFailed       = "failed"
+	StateDisconnected = "disconnected"
+)
+
+const (
+	// probeInterval is how often an idle connection is checked with an OVSDB echo
+	probeInterval = 30 * time.Second
+	// probeTimeout bounds a single echo; a server that does not answer in time is treated as gone
+	probeTimeout = 10 * time.Second
+	// reconnectAttemptTimeout bounds a single reconnect attempt, including the tunnel re-dial
+	reconnectAttemptTimeout = 30 * time.Second
+	// reconnectMaxElapsed is how long reconnecting goes on before the connection is reported failed
+	reconnectMaxElapsed = 5 * time.Minute
+)
+
+// StateEvent describes a change of connection state
+type StateEvent struct {
+	State    string
+	Endpoint string // the endpoint as requested, not the local tunnel endpoint
+	Attempt  int    // reconnect attempt, starting at 1; zero otherwise
+	Err      error  // the failure that caused the transition, if any
+}
+
+// setState records the current state and reports it to OnStateChange
+func (c *OVSDBClient) setState(event StateEvent) {
+	c.stateMu.Lock()
+	event.Endpoint = c.endpoint
+	c.state = event.State
+	c.stateMu.Unlock()
+	if c.OnStateChange != nil {
+		c.OnStateChange(event)
+	}
+}
+
+// State returns the current connection state
+func (c *OVSDBClient) State() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.state == "" {
+		return StateDisconnected
+	}
+	return c.state
+}
+
+// supervise watches the connection until stop is closed. A dropped connection, or one that
+// stops answering echo probes, is reconnected with exponential backoff through the same
+// local endpoints, so an SSH tunnel re-dials its hop chain on the first new stream, and
+// members whose transport could not be established are tried again. In leader-only mode
+// libovsdb drops the connection when the member loses leadership, and reconnecting moves
+// to the new leader.
+func (c *OVSDBClient) supervise(client ovsdbclient.Client, stop chan struct{}) {
+	// libovsdb only delivers the notification to a listening receiver, so one goroutine
+	// listens at all times and the supervisor picks it up when it is ready
+	go func() {
+		for {
+			select {
+			case <-client.DisconnectNotify():
+				c.stateMu.Lock()
+				select {
+				case <-c.mainDown:
+				default:
+					close(c.mainDown)
+				}
+				c.stateMu.Unlock()
+				c.notifyLost()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			err := client.Echo(ctx)
+			cancel()
+			if err == nil {
+				continue
+			}
+			// Dropping the connection makes libovsdb report it lost
+			client.Disconnect()
+			continue
+		case <-c.lost:
+		}
+
+		if err := c.reconnect(client, stop); err != nil {
+			if err != errStopped {
+				c.setState(StateEvent{State: StateFailed, Err: err})
+			}
+			return
+		}
+		// Losses noticed while reconnecting concern the old connections; a loss of the new
+		// ones that is dropped here is still caught by the next echo probe
+		select {
+		case <-c.lost:
+		default:
+		}
+		// After a leadership change the client may be on another member
+		c.stateMu.Lock()
+		c.endpoint = c.activeEndpoint()
+		c.stateMu.Unlock()
+		c.setState(StateEvent{State: StateConnected})
+	}
+}
+
+// notifyLost wakes the supervisor after a connection, the main one or the monitor one, was lost
+func (c *OVSDBClient) notifyLost() {
+	select {
+	case c.lost <- struct{}{}:
+	default:
+	}
+}
+
+// errStopped reports that the client was disconnected while reconnecting
+var errStopped = errors.New("client disconnected")
+
+// reconnect retries Connect, and resumes the monitors, until it succeeds, stop is closed, or reconnectMaxElapsed passes
+func (c *OVSDBClient) reconnect(client ovsdbclient.Client, stop chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = 500 * time.Millisecond
+	policy.MaxInterval = 30 * time.Second
+	policy.MaxElapsedTime = reconnectMaxElapsed
+
+	attempt := 0
+	var lastErr error
+	err := backoff.Retry(func() error {
+		if ctx.Err() != nil {
+			return backoff.Permanent(errStopped)
+		}
+		attempt++
+		c.setState(StateEvent{State: StateReconnecting, Attempt: attempt, Err: lastErr})
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, reconnectAttemptTimeout)
+		defer attemptCancel()
+		err := c.awaitTeardown(attemptCtx, client)
+		if err == nil {
+			if c.mainLost() {
+				c.retryMembers(attemptCtx, client)
+			}
+			err = client.Connect(attemptCtx)
+		}
+		if err == ovsdbclient.ErrAlreadyConnected {
+			err = nil
+		}
+		if err == nil {
+			if c.mainLost() {
+				c.stateMu.Lock()
+				c.mainDown = make(chan struct{})
+				c.stateMu.Unlock()
+			}
+			// Monitors and other databases use their own connection, to the member now in use
+			err = c.resumeSession(attemptCtx)
+		}
+		if err != nil {
+			c.membersMu.Lock()
+			detail := memberErrors(c.members)
+			c.membersMu.Unlock()
+			if detail != "" {
+				err = fmt.Errorf("%w (%s)", err, detail)
+			}
+			lastErr = err
+		}
+		return err
+	}, backoff.WithContext(policy, ctx))
+	if ctx.Err() != nil {
+		return errStopped
+	}
+	if err != nil {
+		return fmt.Errorf("gave up reconnecting after %d attempts: %w", attempt, err)
+	}
+	return nil
+}
+
+// awaitTeardown returns once the main connection either answers or has been torn down by
+// libovsdb. A loss noticed on the monitor connection can come first, and a teardown still
+// under way would clear the schema of the connection made in its place.
+func (c *OVSDBClient) awaitTeardown(ctx context.Context, client ovsdbclient.Client) error {
+	if c.mainLost() {
+		return nil
+	}
+	c.stateMu.Lock()
+	down := c.mainDown
+	c.stateMu.Unlock()
+	if client.Echo(ctx) == nil {
+		return nil
+	}
+	client.Disconnect()
+	select {
+	case <-down:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to close the lost connection: %w", ctx.Err())
+	}
+}
+
+// mainLost reports whether libovsdb has torn down the main connection since it was made
+func (c *OVSDBClient) mainLost() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	select {
+	case <-c.mainDown:
+		return true
+	default:
+		return false
+	}
+}
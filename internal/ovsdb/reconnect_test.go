@@ -0,0 +1,172 @@
+package ovsdb
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+	"golang.org/x/crypto/ssh"
+)
+
+// waitState reads state events until one reports state, failing the test on any other
+// terminal state or when none comes in time
+func waitState(t *testing.T, states <-chan StateEvent, state string) StateEvent {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-states:
+			if event.State == state {
+				return event
+			}
+			if event.State == StateFailed || event.State == StateDisconnected {
+				t.Fatalf("got state %s (%v) while waiting for %s", event.State, event.Err, state)
+			}
+		case <-timeout:
+			t.Fatalf("no %s state", state)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	sock := startServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := forward(t, l, "unix", sock)
+
+	states := make(chan StateEvent, 100)
+	deltas := make(chan TableDelta, 100)
+	c := &OVSDBClient{
+		OnStateChange: func(event StateEvent) { states <- event },
+		OnTableUpdate: func(delta TableDelta) { deltas <- delta },
+	}
+	ctx := context.Background()
+	endpoint := "tcp:" + l.Addr().String()
+	if err := c.Connect(ctx, ConnectionConfig{}, endpoint, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	waitState(t, states, StateConnected)
+	if _, err := c.MonitorTable(ctx, "", "Bridge"); err != nil {
+		t.Fatal(err)
+	}
+
+	drop()
+	if event := waitState(t, states, StateReconnecting); event.Attempt != 1 || event.Endpoint != endpoint {
+		t.Errorf("first reconnecting event = %+v", event)
+	}
+	waitState(t, states, StateConnected)
+
+	// The monitor is resumed on the new connection
+	if _, err := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": "br0"}}); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(10 * time.Second)
+	for inserted := false; !inserted; {
+		select {
+		case delta := <-deltas:
+			for _, row := range delta.Inserted {
+				inserted = inserted || row["name"].Atom.Value == "br0"
+			}
+		case <-timeout:
+			t.Fatal("no update for the bridge inserted after reconnecting")
+		}
+	}
+}
+
+func TestReconnectStopped(t *testing.T) {
+	sock := startServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drop := forward(t, l, "unix", sock)
+
+	states := make(chan StateEvent, 100)
+	c := &OVSDBClient{OnStateChange: func(event StateEvent) { states <- event }}
+	if err := c.Connect(context.Background(), ConnectionConfig{}, "tcp:"+l.Addr().String(), ""); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, states, StateConnected)
+
+	// With the server gone for good, Disconnect ends the backoff without reporting a failure
+	l.Close()
+	drop()
+	waitState(t, states, StateReconnecting)
+	c.Disconnect()
+	waitState(t, states, StateDisconnected)
+	select {
+	case event := <-states:
+		if event.State == StateFailed {
+			t.Errorf("reconnecting failed after Disconnect: %v", event.Err)
+		}
+	case <-time.After(time.Second):
+	}
+}
+
+func TestReconnectSSHTunnel(t *testing.T) {
+	sock := startServer(t)
+	s := startSSHServer(t, nil)
+	var asked []string
+	config := sshTestConfig(t, s, map[string]string{AuthPassword: "secret"}, &asked)
+	config.Auth = []AuthMethod{{Type: AuthPassword}}
+
+	states := make(chan StateEvent, 100)
+	c := &OVSDBClient{OnStateChange: func(event StateEvent) { states <- event }}
+	ctx := context.Background()
+	if err := c.Connect(ctx, config, "unix:"+sock, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	waitState(t, states, StateConnected)
+
+	// The tunnel's local endpoint stays, and its hop chain is dialed again
+	s.dropAll()
+	waitState(t, states, StateReconnecting)
+	waitState(t, states, StateConnected)
+	if _, err := c.GetTableData(ctx, "", "Bridge"); err != nil {
+		t.Errorf("failed to read after reconnecting: %v", err)
+	}
+	if len(asked) != 2 {
+		t.Errorf("asked for %d passwords, want one per SSH connection", len(asked))
+	}
+}
+
+func TestSSHSessionDialsOnce(t *testing.T) {
+	s := startSSHServer(t, nil)
+	var asked []string
+	config := sshTestConfig(t, s, map[string]string{AuthPassword: "secret"}, &asked)
+	config.Auth = []AuthMethod{{Type: AuthPassword}}
+	session := &sshSession{config: config}
+
+	// Streams that arrive while the hop chain is dialed share that dial
+	clients := make([]*ssh.Client, 8)
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clients[i], errs[i] = session.current()
+		}()
+	}
+	wg.Wait()
+	for i := range clients {
+		if errs[i] != nil || clients[i] != clients[0] {
+			t.Fatalf("stream %d got client %p, %v; want the shared %p", i, clients[i], errs[i], clients[0])
+		}
+	}
+	if len(asked) != 1 {
+		t.Errorf("dialed %d times, want once", len(asked))
+	}
+
+	session.close()
+	if _, err := session.current(); err != errTunnelStopped {
+		t.Errorf("current after close = %v, want errTunnelStopped", err)
+	}
+}
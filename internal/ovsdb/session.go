@@ -0,0 +1,274 @@
+package ovsdb
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/cenkalti/rpc2/jsonrpc"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// rpcSession is a JSON-RPC connection of our own to the member in use. libovsdb only serves
+// the database it was connected for, only monitors tables of a generated model and decodes
+// numbers as float64, so the table monitors, every transaction and every request for
+// another database of the server go through here.
+type rpcSession struct {
+	mu      sync.Mutex // guards everything below; never held across an RPC call
+	rpc     *rpc2.Client
+	method  string                   // monitor method the server accepted; empty until the first monitor
+	tables  map[string]*tableMonitor // keyed by monitor id
+	schemas map[string]*ovsdb.DatabaseSchema
+}
+
+func newRPCSession() *rpcSession {
+	return &rpcSession{
+		tables:  make(map[string]*tableMonitor),
+		schemas: make(map[string]*ovsdb.DatabaseSchema),
+	}
+}
+
+// isPrimary reports whether a database name refers to the database libovsdb is connected for
+func (c *OVSDBClient) isPrimary(dbName string) bool {
+	return dbName == "" || dbName == c.dbName
+}
+
+// schema returns the schema of any database of the current member, fetching it once
+func (c *OVSDBClient) schema(ctx context.Context, dbName string) (*ovsdb.DatabaseSchema, error) {
+	if file := c.file.Load(); file != nil {
+		if !c.isPrimary(dbName) {
+			return nil, fmt.Errorf("database %s not found in file", dbName)
+		}
+		return file.state.schema, nil
+	}
+	if c.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if c.isPrimary(dbName) {
+		schema := c.client.Schema()
+		return &schema, nil
+	}
+
+	s := c.session
+	s.mu.Lock()
+	cached, ok := s.schemas[dbName]
+	s.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	rpc, err := c.sessionConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var schema ovsdb.DatabaseSchema
+	if err := rpc.CallWithContext(ctx, "get_schema", []interface{}{dbName}, &schema); err != nil {
+		return nil, fmt.Errorf("failed to get schema of %s: %w", dbName, err)
+	}
+	s.mu.Lock()
+	s.schemas[dbName] = &schema
+	s.mu.Unlock()
+	return &schema, nil
+}
+
+// Transact runs operations against any database of the current member, through the
+// session connection, whose replies keep integers exact; on a clustered follower the
+// server forwards them to the leader. Every transaction that writes is recorded in the audit
+// log, if one is set, including those ReadOnly rejects. A database file answers transactions
+// itself and nothing is recorded.
+func (c *OVSDBClient) Transact(ctx context.Context, dbName string, ops ...ovsdb.Operation) ([]ovsdb.OperationResult, error) {
+	return c.transactSteps(ctx, dbName, ops, nil)
+}
+
+// transactSteps is Transact for a transaction built from edits, whose capture selects only
+// read rows for the undo and are left out of the audit record
+func (c *OVSDBClient) transactSteps(ctx context.Context, dbName string, ops []ovsdb.Operation, steps []txStep) ([]ovsdb.OperationResult, error) {
+	if file := c.file.Load(); file != nil {
+		return file.state.transact(dbName, ops)
+	}
+	if c.client == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	start := time.Now()
+	var results []ovsdb.OperationResult
+	var err error
+	if c.ReadOnly {
+		err = checkReadOnly(ops)
+	}
+	if err == nil {
+		results, err = c.transact(ctx, dbName, ops)
+	}
+	if steps == nil {
+		c.audit(dbName, ops, results, err, start)
+	} else {
+		tx := splitCaptures(ops, steps, results)
+		c.audit(dbName, tx.ops, tx.results, err, start)
+	}
+	return results, err
+}
+
+// transact sends a transaction to the database
+func (c *OVSDBClient) transact(ctx context.Context, dbName string, ops []ovsdb.Operation) ([]ovsdb.OperationResult, error) {
+	if c.isPrimary(dbName) {
+		dbName = c.dbName
+	}
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	if !schema.ValidateOperations(ops...) {
+		return nil, fmt.Errorf("invalid operations for %s", dbName)
+	}
+	rpc, err := c.sessionConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var reply []exactResult
+	if err := rpc.CallWithContext(ctx, "transact", ovsdb.NewTransactArgs(dbName, ops...), &reply); err != nil {
+		return nil, fmt.Errorf("failed to transact on %s: %w", dbName, err)
+	}
+	results := make([]ovsdb.OperationResult, 0, len(reply))
+	for _, r := range reply {
+		results = append(results, r.result())
+	}
+	return results, nil
+}
+
+// ErrReadOnly is returned for transactions that would write through a read-only client
+var ErrReadOnly = errors.New("connection is read-only")
+
+// checkReadOnly rejects every operation but those that only read
+func checkReadOnly(ops []ovsdb.Operation) error {
+	for _, op := range ops {
+		switch op.Op {
+		case ovsdb.OperationSelect, ovsdb.OperationWait, ovsdb.OperationComment:
+		default:
+			return fmt.Errorf("%w: %s operations are not allowed", ErrReadOnly, op.Op)
+		}
+	}
+	return nil
+}
+
+// sessionConn returns the session connection, dialing the current member if needed
+func (c *OVSDBClient) sessionConn(ctx context.Context) (*rpc2.Client, error) {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rpc != nil {
+		return s.rpc, nil
+	}
+
+	conn, err := dialEndpoint(ctx, c.client.CurrentEndpoint(), c.clientTLS())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session connection: %w", err)
+	}
+	rpc := rpc2.NewClientWithCodec(jsonrpc.NewJSONCodec(conn))
+	// Blocking keeps notifications in the order the server sent them
+	rpc.SetBlocking(true)
+	rpc.Handle("echo", func(_ *rpc2.Client, args []interface{}, reply *[]interface{}) error {
+		*reply = args
+		return nil
+	})
+	rpc.Handle("update", func(_ *rpc2.Client, args []json.RawMessage, reply *[]interface{}) error {
+		*reply = []interface{}{}
+		return c.handleUpdate(s, methodMonitor, args)
+	})
+	rpc.Handle("update2", func(_ *rpc2.Client, args []json.RawMessage, reply *[]interface{}) error {
+		*reply = []interface{}{}
+		return c.handleUpdate(s, methodMonitorCond, args)
+	})
+	rpc.Handle("update3", func(_ *rpc2.Client, args []json.RawMessage, reply *[]interface{}) error {
+		*reply = []interface{}{}
+		return c.handleUpdate(s, methodMonitorCondSince, args)
+	})
+	go rpc.Run()
+	go func() {
+		<-rpc.DisconnectNotify()
+		s.mu.Lock()
+		current := s.rpc == rpc
+		if current {
+			s.rpc = nil
+		}
+		s.mu.Unlock()
+		// An unexpected loss is handled like a lost connection, which resumes the monitors
+		if current {
+			c.notifyLost()
+		}
+	}()
+	s.rpc = rpc
+	return rpc, nil
+}
+
+// close drops the connection without reporting it lost
+func (s *rpcSession) close() {
+	s.mu.Lock()
+	rpc := s.rpc
+	s.rpc = nil
+	s.mu.Unlock()
+	if rpc != nil {
+		rpc.Close()
+	}
+}
+
+// resumeSession moves the session to a new connection to the current member, which may
+// serve other schema versions, and re-issues every monitor
+func (c *OVSDBClient) resumeSession(ctx context.Context) error {
+	c.monitorMu.Lock()
+	defer c.monitorMu.Unlock()
+	s := c.session
+	if s == nil {
+		return nil
+	}
+	s.close()
+	s.mu.Lock()
+	s.schemas = make(map[string]*ovsdb.DatabaseSchema)
+	tables := make([]*tableMonitor, 0, len(s.tables))
+	for _, tm := range s.tables {
+		tables = append(tables, tm)
+	}
+	s.mu.Unlock()
+	for _, tm := range tables {
+		if err := c.startMonitor(ctx, tm, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeSession drops the session connection and forgets every monitor and schema
+func (c *OVSDBClient) closeSession() {
+	c.monitorMu.Lock()
+	defer c.monitorMu.Unlock()
+	s := c.session
+	if s == nil {
+		return
+	}
+	s.close()
+	s.mu.Lock()
+	s.method = ""
+	s.tables = make(map[string]*tableMonitor)
+	s.schemas = make(map[string]*ovsdb.DatabaseSchema)
+	s.mu.Unlock()
+}
+
+// dialEndpoint opens a connection to an OVSDB endpoint as libovsdb does
+func dialEndpoint(ctx context.Context, endpoint string, tlsConfig *tls.Config) (net.Conn, error) {
+	var dialer net.Dialer
+	switch {
+	case strings.HasPrefix(endpoint, "unix:"):
+		return dialer.DialContext(ctx, "unix", strings.TrimPrefix(endpoint, "unix:"))
+	case strings.HasPrefix(endpoint, "tcp:"):
+		return dialer.DialContext(ctx, "tcp", strings.TrimPrefix(endpoint, "tcp:"))
+	case strings.HasPrefix(endpoint, "ssl:"):
+		tlsDialer := tls.Dialer{Config: tlsConfig}
+		return tlsDialer.DialContext(ctx, "tcp", strings.TrimPrefix(endpoint, "ssl:"))
+	}
+	return nil, fmt.Errorf("unsupported endpoint type: %s", endpoint)
+}
@@ -0,0 +1,45 @@
+package ovsdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestReadOnly(t *testing.T) {
+	sock := startServer(t)
+	c := &OVSDBClient{ReadOnly: true}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+
+	for _, op := range []string{ovsdb.OperationSelect, ovsdb.OperationWait, ovsdb.OperationComment} {
+		if err := checkReadOnly([]ovsdb.Operation{{Op: op}}); err != nil {
+			t.Errorf("%s refused: %v", op, err)
+		}
+	}
+	for _, op := range []string{ovsdb.OperationInsert, ovsdb.OperationUpdate, ovsdb.OperationMutate, ovsdb.OperationDelete, ovsdb.OperationAbort, ovsdb.OperationCommit, "assert"} {
+		err := checkReadOnly([]ovsdb.Operation{{Op: ovsdb.OperationSelect}, {Op: op}})
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s allowed: %v", op, err)
+		}
+	}
+
+	// A write is refused before it is sent, reads go through
+	insert := ovsdb.Operation{Op: ovsdb.OperationInsert, Table: "Bridge", Row: ovsdb.Row{"name": "br0"}}
+	if _, err := c.Transact(ctx, "", ovsdb.Operation{Op: ovsdb.OperationSelect, Table: "Bridge", Where: []ovsdb.Condition{}}, insert); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("transaction with an insert returned %v", err)
+	}
+	edits := []Edit{{Op: "insert", Table: "Bridge", Row: map[string]Value{"name": atomValue("string", "br0")}}}
+	if _, err := c.ApplyTransaction(ctx, "", edits); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("applied edits returned %v", err)
+	}
+	if _, err := c.PreviewTransaction(ctx, "", edits); err != nil {
+		t.Errorf("preview of edits failed: %v", err)
+	}
+	rows, err := c.GetTableData(ctx, "", "Bridge")
+	if err != nil || len(rows) != 0 {
+		t.Errorf("read after the refused writes = %+v, %v", rows, err)
+	}
+}
@@ -0,0 +1,248 @@
+package ovsdb
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth guards against Include loops in ssh_config
+const maxIncludeDepth = 16
+
+// SSHConfig is a parsed OpenSSH client configuration (~/.ssh/config and its Includes).
+// Only the keywords needed to build a tunnel are interpreted: HostName, Port, User,
+// IdentityFile and ProxyJump. Match blocks other than "Match all" are ignored.
+type SSHConfig struct {
+	directives []sshDirective
+}
+
+// SSHHostConfig is the effective configuration for one host alias
+type SSHHostConfig struct {
+	Alias         string   `json:"alias"`
+	HostName      string   `json:"hostName"`
+	Port          int      `json:"port"`
+	User          string   `json:"user"`
+	IdentityFiles []string `json:"identityFiles,omitempty"`
+	ProxyJump     []string `json:"proxyJump,omitempty"`
+}
+
+type sshDirective struct {
+	patterns []string // Host patterns the directive applies to, nil when global
+	key      string   // lower-cased keyword
+	value    string
+}
+
+// DefaultSSHConfigPath returns the path of the user's ssh_config
+func DefaultSSHConfigPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ssh", "config"), nil
+}
+
+// LoadSSHConfig parses an ssh_config file. A missing file yields an empty configuration.
+func LoadSSHConfig(file string) (*SSHConfig, error) {
+	cfg := &SSHConfig{}
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err := cfg.parseFile(file, filepath.Dir(file), nil, 0); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// parseFile reads one file; relative Include paths are resolved against baseDir,
+// the directory of the top-level config, as OpenSSH does for ~/.ssh
+func (s *SSHConfig) parseFile(file, baseDir string, patterns []string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("ssh_config includes nested too deeply at %s", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		key, value := splitSSHConfigLine(scanner.Text())
+		if key == "" {
+			continue
+		}
+		switch key {
+		case "host":
+			patterns = strings.Fields(value)
+		case "match":
+			// Only "Match all" is understood; any other criteria disable the block
+			if strings.EqualFold(strings.TrimSpace(value), "all") {
+				patterns = []string{"*"}
+			} else {
+				patterns = []string{}
+			}
+		case "include":
+			for _, pattern := range strings.Fields(value) {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("%s:%d: invalid Include pattern: %w", file, lineNum, err)
+				}
+				sort.Strings(matches)
+				for _, match := range matches {
+					if err := s.parseFile(match, baseDir, patterns, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			s.directives = append(s.directives, sshDirective{patterns: patterns, key: key, value: value})
+		}
+	}
+	return scanner.Err()
+}
+
+// splitSSHConfigLine splits "Keyword value" or "Keyword=value", dropping comments and quotes
+func splitSSHConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:idx])
+	value := strings.TrimSpace(line[idx:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	value = strings.Trim(value, `"`)
+	return key, value
+}
+
+// Resolve returns the effective configuration for a host alias. As in OpenSSH the first
+// value obtained for a keyword wins, except IdentityFile which accumulates.
+func (s *SSHConfig) Resolve(alias string) SSHHostConfig {
+	resolved := SSHHostConfig{Alias: alias}
+	seen := make(map[string]bool)
+	for _, d := range s.directives {
+		if d.patterns != nil && !matchHostPatterns(d.patterns, alias) {
+			continue
+		}
+		if d.key == "identityfile" {
+			resolved.IdentityFiles = append(resolved.IdentityFiles, d.value)
+			continue
+		}
+		if seen[d.key] {
+			continue
+		}
+		seen[d.key] = true
+		switch d.key {
+		case "hostname":
+			resolved.HostName = d.value
+		case "port":
+			if port, err := strconv.Atoi(d.value); err == nil {
+				resolved.Port = port
+			}
+		case "user":
+			resolved.User = d.value
+		case "proxyjump":
+			if !strings.EqualFold(d.value, "none") {
+				for _, jump := range strings.Split(d.value, ",") {
+					if jump = strings.TrimSpace(jump); jump != "" {
+						resolved.ProxyJump = append(resolved.ProxyJump, jump)
+					}
+				}
+			}
+		}
+	}
+
+	if resolved.HostName == "" {
+		resolved.HostName = alias
+	} else {
+		resolved.HostName = strings.ReplaceAll(resolved.HostName, "%h", alias)
+	}
+	if resolved.Port == 0 {
+		resolved.Port = 22
+	}
+	if resolved.User == "" {
+		if u, err := user.Current(); err == nil {
+			resolved.User = u.Username
+		}
+	}
+	for i, file := range resolved.IdentityFiles {
+		resolved.IdentityFiles[i] = expandSSHTokens(file, resolved)
+	}
+	return resolved
+}
+
+// Aliases returns the concrete (non-wildcard, non-negated) host names declared in Host lines
+func (s *SSHConfig) Aliases() []string {
+	seen := make(map[string]bool)
+	var aliases []string
+	for _, d := range s.directives {
+		for _, pattern := range d.patterns {
+			if pattern == "" || strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			aliases = append(aliases, pattern)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// matchHostPatterns applies OpenSSH Host matching: any positive match, and no negated match
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host)); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// expandSSHTokens expands ~ and the %d, %h, %p, %r and %u tokens used in IdentityFile
+func expandSSHTokens(value string, host SSHHostConfig) string {
+	value = expandHome(value)
+	homeDir, _ := os.UserHomeDir()
+	localUser := ""
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+	}
+	replacer := strings.NewReplacer(
+		"%%", "%",
+		"%d", homeDir,
+		"%h", host.HostName,
+		"%p", strconv.Itoa(host.Port),
+		"%r", host.User,
+		"%u", localUser,
+	)
+	return replacer.Replace(value)
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homeDir, strings.TrimPrefix(p, "~"))
+		}
+	}
+	return p
+}
@@ -0,0 +1,153 @@
+package ovsdb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeSSHConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSSHConfigResolve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	config := writeSSHConfig(t, dir, "config", `
+# Cluster nodes
+Host ovn-east-* !ovn-east-bastion
+    HostName %h.example.net
+    User core
+    IdentityFile ~/.ssh/id_%r
+    ProxyJump ovn-east-bastion
+
+Host ovn-east-bastion
+    HostName=203.0.113.10
+    Port 2222
+    ProxyJump none
+
+Include conf.d/*.conf
+
+Match exec "true"
+    User ignored
+
+Host *
+    User fallback
+    Port 22
+    IdentityFile "~/.ssh/id_ed25519"
+`)
+	writeSSHConfig(t, dir, "conf.d/lab.conf", `
+Host lab
+    HostName 10.0.0.5
+    ProxyJump jump1, jump2
+`)
+
+	cfg, err := LoadSSHConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		alias string
+		want  SSHHostConfig
+	}{
+		{"ovn-east-1", SSHHostConfig{
+			Alias:         "ovn-east-1",
+			HostName:      "ovn-east-1.example.net",
+			Port:          22,
+			User:          "core",
+			IdentityFiles: []string{filepath.Join(dir, ".ssh/id_core"), filepath.Join(dir, ".ssh/id_ed25519")},
+			ProxyJump:     []string{"ovn-east-bastion"},
+		}},
+		{"ovn-east-bastion", SSHHostConfig{
+			Alias:         "ovn-east-bastion",
+			HostName:      "203.0.113.10",
+			Port:          2222,
+			User:          "fallback",
+			IdentityFiles: []string{filepath.Join(dir, ".ssh/id_ed25519")},
+		}},
+		{"lab", SSHHostConfig{
+			Alias:         "lab",
+			HostName:      "10.0.0.5",
+			Port:          22,
+			User:          "fallback",
+			IdentityFiles: []string{filepath.Join(dir, ".ssh/id_ed25519")},
+			ProxyJump:     []string{"jump1", "jump2"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := cfg.Resolve(tt.alias); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Resolve(%s) = %+v, want %+v", tt.alias, got, tt.want)
+		}
+	}
+
+	if got, want := cfg.Aliases(), []string{"lab", "ovn-east-bastion"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Aliases() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadSSHConfigMissingFile(t *testing.T) {
+	cfg, err := LoadSSHConfig(filepath.Join(t.TempDir(), "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliases := cfg.Aliases(); len(aliases) != 0 {
+		t.Fatalf("empty config has aliases %q", aliases)
+	}
+}
+
+func TestLoadSSHConfigIncludeLoop(t *testing.T) {
+	dir := t.TempDir()
+	config := writeSSHConfig(t, dir, "config", "Include config\n")
+	if _, err := LoadSSHConfig(config); err == nil {
+		t.Fatal("an Include loop was accepted")
+	}
+}
+
+func TestSplitSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line, key, value string
+	}{
+		{"", "", ""},
+		{"   # comment", "", ""},
+		{"HostName example.net", "hostname", "example.net"},
+		{"\tPort=2222", "port", "2222"},
+		{"User = core", "user", "core"},
+		{`IdentityFile "~/.ssh/my key"`, "identityfile", "~/.ssh/my key"},
+		{"Compression", "compression", ""},
+	}
+	for _, tt := range tests {
+		key, value := splitSSHConfigLine(tt.line)
+		if key != tt.key || value != tt.value {
+			t.Errorf("splitSSHConfigLine(%q) = %q, %q, want %q, %q", tt.line, key, value, tt.key, tt.value)
+		}
+	}
+}
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"*"}, "anything", true},
+		{[]string{"ovn-*"}, "OVN-east", true},
+		{[]string{"ovn-?"}, "ovn-12", false},
+		{[]string{"ovn-*", "!ovn-bastion"}, "ovn-bastion", false},
+		{[]string{"!ovn-bastion"}, "lab", false},
+		{[]string{"lab", "ovn-*"}, "lab", true},
+	}
+	for _, tt := range tests {
+		if got := matchHostPatterns(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("matchHostPatterns(%q, %s) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package ovsdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshKeepaliveInterval is how often an established SSH connection is probed
+	sshKeepaliveInterval = 15 * time.Second
+	// sshKeepaliveTimeout is how long a keepalive may go unanswered before the connection is dropped
+	sshKeepaliveTimeout = 10 * time.Second
+)
+
+// errTunnelStopped is returned by dials after the tunnel has been stopped
+var errTunnelStopped = errors.New("tunnel stopped")
+
+// sshSession owns the SSH connection behind a tunnel. A connection that closes or stops
+// answering keepalives is dropped, and the hop chain is dialed again on the next stream,
+// so the tunnel's local endpoint stays valid across SSH outages.
+type sshSession struct {
+	config ConnectionConfig
+
+	mu      sync.Mutex
+	client  *ssh.Client
+	dial    *sshDial // the dial in progress, if any
+	lastErr error
+	stopped bool
+}
+
+// sshDial is one dial of the hop chain, whose outcome is shared by every stream waiting for it
+type sshDial struct {
+	done   chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+// current returns the live SSH client, dialing the hop chain if there is none. The dial
+// runs without the lock, so drop, err and close are not held up by a slow hop, and streams
+// that arrive meanwhile wait for its outcome rather than dialing again.
+func (s *sshSession) current() (*ssh.Client, error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil, errTunnelStopped
+	}
+	if s.client != nil {
+		client := s.client
+		s.mu.Unlock()
+		return client, nil
+	}
+	if dial := s.dial; dial != nil {
+		s.mu.Unlock()
+		<-dial.done
+		return dial.client, dial.err
+	}
+	dial := &sshDial{done: make(chan struct{})}
+	s.dial = dial
+	s.mu.Unlock()
+
+	client, err := s.config.dialSSH(context.Background())
+
+	s.mu.Lock()
+	s.dial = nil
+	switch {
+	case s.stopped:
+		if client != nil {
+			client.Close()
+		}
+		client, err = nil, errTunnelStopped
+	case err != nil:
+		s.lastErr = err
+	default:
+		s.client = client
+		s.lastErr = nil
+		go s.keepalive(client)
+	}
+	dial.client, dial.err = client, err
+	s.mu.Unlock()
+	close(dial.done)
+	return client, err
+}
+
+// dialer returns the dialFunc used by the local forwarder
+func (s *sshSession) dialer(network, addr string) dialFunc {
+	return func() (io.ReadWriteCloser, error) {
+		client, err := s.current()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := client.Dial(network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		// A refused dial on a healthy connection is the remote end's answer; only a dead
+		// connection is worth dialing again
+		if sendKeepalive(client) == nil {
+			s.setErr(err)
+			return nil, err
+		}
+		s.drop(client, err)
+		if client, err = s.current(); err != nil {
+			return nil, err
+		}
+		conn, err = client.Dial(network, addr)
+		if err != nil {
+			s.setErr(err)
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// keepalive probes the client until it closes, and closes it when a probe fails
+func (s *sshSession) keepalive(client *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(sshKeepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			s.drop(client, fmt.Errorf("ssh connection closed"))
+			return
+		case <-ticker.C:
+			if err := sendKeepalive(client); err != nil {
+				s.drop(client, err)
+				return
+			}
+		}
+	}
+}
+
+// sendKeepalive sends an OpenSSH keepalive request and waits for any reply
+func sendKeepalive(client *ssh.Client) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		errCh <- err
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("ssh keepalive failed: %w", err)
+		}
+		return nil
+	case <-time.After(sshKeepaliveTimeout):
+		return fmt.Errorf("ssh keepalive timed out after %s", sshKeepaliveTimeout)
+	}
+}
+
+// drop forgets a broken client so the next stream dials a new one
+func (s *sshSession) drop(client *ssh.Client, err error) {
+	s.mu.Lock()
+	if s.client == client {
+		s.client = nil
+		if !s.stopped {
+			s.lastErr = err
+		}
+	}
+	s.mu.Unlock()
+	client.Close()
+}
+
+func (s *sshSession) setErr(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// err reports the most recent SSH failure
+func (s *sshSession) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// close closes the current client and stops further dials
+func (s *sshSession) close() {
+	s.mu.Lock()
+	client := s.client
+	s.client = nil
+	s.stopped = true
+	s.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
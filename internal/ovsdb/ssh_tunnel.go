@@ -3,11 +3,7 @@ package ovsdb
 import (
 	"context"
 	"fmt"
-	"io"
-	"math/rand"
 	"net"
-	"os"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -15,230 +11,248 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
-// Tunnel represents an active SSH tunnel
-type Tunnel struct {
-	LocalEndpoint string
-	Stop          func()
-}
-
-// ConnectionConfig holds the configuration for SSH connection
+// ConnectionConfig holds the configuration for SSH connection, or for the relay command used instead
 type ConnectionConfig struct {
 	Host               string
 	Port               int
 	User               string
-	KeyFile            string
-	JumpHosts          []string // list of jump hosts in format "user@host:port"
-	LocalForwarderType string   // "tcp", "unix", or "auto" (default: "auto")
+	KeyFile            string                  // used when Auth is empty, for compatibility with older profiles
+	Auth               []AuthMethod            // methods for the target host, also used by jump hosts without JumpAuth
+	JumpAuth           map[string][]AuthMethod // per jump host methods, keyed by the JumpHosts entry
+	JumpHosts          []string                // list of jump hosts in format "user@host:port"
+	LocalForwarderType string                  // "tcp", "unix", or "auto" (default: "auto")
+	TLS                *TLSConfig              // certificates for "ssl:" endpoints, used with or without a tunnel
+	KnownHostsFile     string                  // known_hosts used to verify every hop (default: ~/.ssh/known_hosts)
+	SSHConfigFile      string                  // ssh_config used to resolve host aliases (default: ~/.ssh/config)
+	Command            *CommandConfig          // relay command used instead of an SSH tunnel when set
+	// ConfirmHostKey is asked whether to trust a host key missing from known_hosts.
+	// Accepted keys are recorded; when nil, unknown hosts are rejected.
+	ConfirmHostKey func(HostKeyPrompt) bool
+	// Prompt asks the user for passphrases, passwords and keyboard-interactive answers
+	Prompt func(AuthPrompt) (string, error)
 }
 
 // EstablishTunnel establishes an SSH tunnel to the remote OVSDB endpoint and returns a Tunnel struct
-// that the OVSDB client can connect to. Supports TCP and Unix domain socket endpoints, and proxy jumps.
+// that the OVSDB client can connect to. Supports TCP, SSL and Unix domain socket endpoints, and proxy jumps.
+// SSL endpoints are forwarded as plain TCP and the TLS session runs end to end through the tunnel.
 func EstablishTunnel(config ConnectionConfig, remoteEndpoint string) (*Tunnel, error) {
-	client, err := config.dialSSH(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to establish SSH connection: %w", err)
-	}
-
-	forwarderType := config.LocalForwarderType
-	if forwarderType == "" {
-		forwarderType = "auto"
-	}
-
 	var remoteAddr string
 	if strings.HasPrefix(remoteEndpoint, "tcp:") {
 		remoteAddr = strings.TrimPrefix(remoteEndpoint, "tcp:")
+	} else if strings.HasPrefix(remoteEndpoint, "ssl:") {
+		remoteAddr = strings.TrimPrefix(remoteEndpoint, "ssl:")
 	} else if strings.HasPrefix(remoteEndpoint, "unix:") {
 		remoteAddr = strings.TrimPrefix(remoteEndpoint, "unix:")
 	} else {
 		return nil, fmt.Errorf("unsupported endpoint type: %s", remoteEndpoint)
 	}
 
-	if forwarderType == "tcp" {
-		return establishTCPTunnel(client, remoteAddr, remoteEndpoint)
-	} else if forwarderType == "unix" {
-		return establishUnixTunnel(client, remoteAddr, remoteEndpoint)
-	} else if forwarderType == "auto" {
-		if runtime.GOOS == "windows" {
-			return establishTCPTunnel(client, remoteAddr, remoteEndpoint)
-		} else {
-			if strings.HasPrefix(remoteEndpoint, "tcp:") {
-				return establishTCPTunnel(client, remoteAddr, remoteEndpoint)
-			} else {
-				return establishUnixTunnel(client, remoteAddr, remoteEndpoint)
-			}
+	forwarderType := config.LocalForwarderType
+	if forwarderType == "" {
+		forwarderType = "auto"
+	}
+	// libovsdb only speaks TLS over TCP, so SSL endpoints always get a TCP forwarder
+	if strings.HasPrefix(remoteEndpoint, "ssl:") {
+		if forwarderType == "unix" {
+			return nil, fmt.Errorf("ssl endpoints cannot use the unix forwarder")
 		}
+		forwarderType = "tcp"
+	}
+
+	session := &sshSession{config: config}
+	if _, err := session.current(); err != nil {
+		return nil, fmt.Errorf("failed to establish SSH connection: %w", err)
+	}
+
+	network := "tcp"
+	if strings.HasPrefix(remoteEndpoint, "unix:") {
+		network = "unix"
+	}
+	tunnel, err := establishLocalForwarder(forwarderType, remoteEndpoint, session.dialer(network, remoteAddr))
+	if err != nil {
+		session.close()
+		return nil, err
 	}
-	return nil, fmt.Errorf("unsupported forwarder type: %s", forwarderType)
+	stop := tunnel.Stop
+	tunnel.Stop = func() {
+		stop()
+		session.close()
+	}
+	tunnel.Err = session.err
+	return tunnel, nil
+}
+
+// sshTransport carries OVSDB traffic through an SSH tunnel
+type sshTransport struct {
+	config ConnectionConfig
+}
+
+func (t sshTransport) Establish(ctx context.Context, remoteEndpoint string) (*Tunnel, error) {
+	return EstablishTunnel(t.config, remoteEndpoint)
 }
 
 // dialSSH establishes an SSH client connection, supporting chained proxy jumps
 func (c *ConnectionConfig) dialSSH(ctx context.Context) (*ssh.Client, error) {
-	key, err := loadPrivateKey(c.KeyFile)
+	hops, err := c.hops()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := c.hostKeyCallback()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load private key: %w", err)
+		return nil, err
 	}
 
 	var client *ssh.Client
-	for _, jump := range c.JumpHosts {
-		jumpUser, jumpHost, jumpPort := parseJumpHost(jump)
-		addr := fmt.Sprintf("%s:%d", jumpHost, jumpPort)
-		config := &ssh.ClientConfig{
-			User: jumpUser,
-			Auth: []ssh.AuthMethod{
-				ssh.PublicKeys(key),
-			},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: Use known_hosts for security
-			Timeout:         10 * time.Second,
-		}
-		if client == nil {
-			client, err = ssh.Dial("tcp", addr, config)
-		} else {
-			conn, err := client.Dial("tcp", addr)
-			if err != nil {
-				return nil, fmt.Errorf("failed to dial jump host %s: %w", jump, err)
-			}
-			clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create client conn to jump host %s: %w", jump, err)
-			}
-			client = ssh.NewClient(clientConn, chans, reqs)
-		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to connect to jump host %s: %w", jump, err)
+	var chain []*ssh.Client
+	closeChain := func() {
+		for i := len(chain) - 1; i >= 0; i-- {
+			chain[i].Close()
 		}
 	}
-
-	// Connect to final target
-	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
-	config := &ssh.ClientConfig{
-		User: c.User,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(key),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // TODO: Use known_hosts for security
-		Timeout:         10 * time.Second,
-	}
-	if client == nil {
-		client, err = ssh.Dial("tcp", addr, config)
-	} else {
-		conn, err := client.Dial("tcp", addr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to dial target %s: %w", addr, err)
-		}
-		clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	for _, hop := range hops {
+		next, err := c.dialHop(client, hop.user, hop.addr, hop.methods, hostKeyCallback)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create client conn to target %s: %w", addr, err)
+			closeChain()
+			if hop.spec != "" {
+				return nil, fmt.Errorf("failed to connect to jump host %s: %w", hop.spec, err)
+			}
+			return nil, fmt.Errorf("failed to connect to target: %w", err)
 		}
-		client = ssh.NewClient(clientConn, chans, reqs)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to target: %w", err)
+		client = next
+		chain = append(chain, next)
 	}
+	// The jump connections only carry the target's connection, so they go away with it
+	go func() {
+		client.Wait()
+		closeChain()
+	}()
 	return client, nil
 }
 
-// establishTCPTunnel sets up a local TCP listener and forwards traffic to the remote endpoint via SSH
-func establishTCPTunnel(client *ssh.Client, remoteAddr string, remoteEndpoint string) (*Tunnel, error) {
-	localListener, err := net.Listen("tcp", "localhost:0")
+// sshHop is one SSH connection in the chain leading to the tunnel target
+type sshHop struct {
+	spec    string // JumpHosts or ProxyJump entry; empty for the target
+	user    string
+	addr    string
+	methods []AuthMethod
+}
+
+// hops resolves the target and jump hosts through ssh_config. Explicit settings win over
+// ssh_config, except that the default port 22 yields to a configured Port. Without JumpHosts
+// the target's ProxyJump is used.
+func (c *ConnectionConfig) hops() ([]sshHop, error) {
+	sshConfig, err := c.loadSSHConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on local TCP: %w", err)
+		return nil, err
 	}
 
-	go func() {
-		defer localListener.Close()
-		for {
-			localConn, err := localListener.Accept()
-			if err != nil {
-				return
-			}
-			var remoteConn net.Conn
-			if strings.HasPrefix(remoteEndpoint, "tcp:") {
-				remoteConn, err = client.Dial("tcp", remoteAddr)
-			} else {
-				remoteConn, err = client.Dial("unix", remoteAddr)
-			}
-			if err != nil {
-				localConn.Close()
-				continue
-			}
-			go func() {
-				defer localConn.Close()
-				defer remoteConn.Close()
-				io.Copy(localConn, remoteConn)
-			}()
-			go func() {
-				defer localConn.Close()
-				defer remoteConn.Close()
-				io.Copy(remoteConn, localConn)
-			}()
-		}
-	}()
-
-	return &Tunnel{
-		LocalEndpoint: "tcp:" + localListener.Addr().String(),
-		Stop:          func() { localListener.Close() },
-	}, nil
-}
+	target := sshConfig.Resolve(c.Host)
+	user := c.User
+	if user == "" {
+		user = target.User
+	}
+	port := c.Port
+	if port == 0 || port == 22 {
+		port = target.Port
+	}
+	jumps := c.JumpHosts
+	if len(jumps) == 0 {
+		jumps = target.ProxyJump
+	}
 
-// establishUnixTunnel sets up a local Unix domain socket listener and forwards traffic to the remote endpoint via SSH
-func establishUnixTunnel(client *ssh.Client, remoteAddr string, remoteEndpoint string) (*Tunnel, error) {
-	localPath := fmt.Sprintf("/tmp/ovsdb-tunnel-%d.sock", rand.Int63())
-	localListener, err := net.Listen("unix", localPath)
+	hops, err := c.expandJumps(sshConfig, jumps, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to listen on local Unix socket: %w", err)
+		return nil, err
 	}
+	return append(hops, sshHop{
+		user:    user,
+		addr:    net.JoinHostPort(target.HostName, strconv.Itoa(port)),
+		methods: c.authMethodsFor("", target.IdentityFiles),
+	}), nil
+}
 
-	go func() {
-		defer localListener.Close()
-		defer os.Remove(localPath) // Clean up socket file
-		for {
-			localConn, err := localListener.Accept()
-			if err != nil {
-				return
-			}
-			var remoteConn net.Conn
-			if strings.HasPrefix(remoteEndpoint, "tcp:") {
-				remoteConn, err = client.Dial("tcp", remoteAddr)
-			} else {
-				remoteConn, err = client.Dial("unix", remoteAddr)
-			}
+// expandJumps resolves jump hosts; like "ssh -J", the first jump host's own ProxyJump is honoured
+func (c *ConnectionConfig) expandJumps(sshConfig *SSHConfig, jumps []string, depth int) ([]sshHop, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("ProxyJump chain is too long or loops")
+	}
+	var hops []sshHop
+	for i, jump := range jumps {
+		jumpUser, jumpHost, jumpPort := parseJumpHost(jump)
+		resolved := sshConfig.Resolve(jumpHost)
+		if i == 0 && len(resolved.ProxyJump) > 0 {
+			prefix, err := c.expandJumps(sshConfig, resolved.ProxyJump, depth+1)
 			if err != nil {
-				localConn.Close()
-				continue
+				return nil, err
 			}
-			go func() {
-				defer localConn.Close()
-				defer remoteConn.Close()
-				io.Copy(localConn, remoteConn)
-			}()
-			go func() {
-				defer localConn.Close()
-				defer remoteConn.Close()
-				io.Copy(remoteConn, localConn)
-			}()
+			hops = append(hops, prefix...)
 		}
-	}()
+		if jumpUser == "" {
+			jumpUser = resolved.User
+		}
+		if jumpPort == 22 {
+			jumpPort = resolved.Port
+		}
+		hops = append(hops, sshHop{
+			spec:    jump,
+			user:    jumpUser,
+			addr:    net.JoinHostPort(resolved.HostName, strconv.Itoa(jumpPort)),
+			methods: c.authMethodsFor(jump, resolved.IdentityFiles),
+		})
+	}
+	return hops, nil
+}
 
-	return &Tunnel{
-		LocalEndpoint: "unix:" + localPath,
-		Stop: func() {
-			localListener.Close()
-			os.Remove(localPath)
-		},
-	}, nil
+// loadSSHConfig reads the configured ssh_config, defaulting to ~/.ssh/config
+func (c *ConnectionConfig) loadSSHConfig() (*SSHConfig, error) {
+	file := c.SSHConfigFile
+	if file == "" {
+		var err error
+		if file, err = DefaultSSHConfigPath(); err != nil {
+			return &SSHConfig{}, nil
+		}
+	}
+	cfg, err := LoadSSHConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh config %s: %w", file, err)
+	}
+	return cfg, nil
 }
 
-// loadPrivateKey loads an SSH private key from file
-func loadPrivateKey(file string) (ssh.Signer, error) {
-	b, err := os.ReadFile(file)
+// dialHop opens an SSH connection to addr, directly or through the previous hop when via is set
+func (c *ConnectionConfig) dialHop(via *ssh.Client, user, addr string, methods []AuthMethod, hostKeyCallback ssh.HostKeyCallback) (*ssh.Client, error) {
+	auth, release, err := c.sshAuth(methods, user, addr)
 	if err != nil {
 		return nil, err
 	}
-	return ssh.ParsePrivateKey(b)
+	defer release()
+
+	config := &ssh.ClientConfig{
+		User:              user,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: c.hostKeyAlgorithms(addr),
+		Timeout:           10 * time.Second,
+	}
+	if via == nil {
+		return ssh.Dial("tcp", addr, config)
+	}
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create client conn to %s: %w", addr, err)
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
 }
 
-// parseJumpHost parses a jump host string in format "user@host:port" or "host:port" or "host"
+// parseJumpHost parses a jump host string in format "user@host:port" or "host:port" or "host",
+// optionally prefixed with "ssh://" as allowed in ProxyJump
 func parseJumpHost(s string) (user, host string, port int) {
+	s = strings.TrimPrefix(s, "ssh://")
 	parts := strings.Split(s, "@")
 	if len(parts) == 2 {
 		user = parts[0]
@@ -0,0 +1,107 @@
+package ovsdb
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileTransaction summarizes one transaction of a database file
+type FileTransaction struct {
+	Number  int       `json:"number"`          // position in the file, from 1
+	Index   uint64    `json:"index,omitempty"` // Raft log index of a clustered file
+	Time    time.Time `json:"time"`            // zero when the file does not record it
+	Comment string    `json:"comment,omitempty"`
+	// Schema is set for the transactions that start the database over: the schema of a
+	// standalone file, a Raft snapshot, or a conversion to a new schema
+	Schema  string         `json:"schema,omitempty"`
+	Tables  []TableChanges `json:"tables"`
+	Diff    bool           `json:"diff,omitempty"` // modified rows were logged as differences
+	Changed int            `json:"changed"`        // rows changed across all tables
+}
+
+// TableChanges counts the rows a transaction changed in one table
+type TableChanges struct {
+	Table    string `json:"table"`
+	Inserted int    `json:"inserted"`
+	Modified int    `json:"modified"`
+	Deleted  int    `json:"deleted"`
+}
+
+// FileTransactions lists the transactions of the database file opened with OpenFile,
+// oldest first, with the rows each one changed
+func (c *OVSDBClient) FileTransactions() ([]FileTransaction, error) {
+	file := c.file.Load()
+	if file == nil {
+		return nil, fmt.Errorf("no database file open")
+	}
+	log := file.log
+	log.summariesOnce.Do(func() {
+		log.summaries, log.summariesErr = log.summarize()
+	})
+	return log.summaries, log.summariesErr
+}
+
+// summarize replays the log, keeping checkpoints on the way, and describes each transaction
+func (l *dbLog) summarize() ([]FileTransaction, error) {
+	state := &fileState{}
+	transactions := make([]FileTransaction, 0, len(l.transactions))
+	for i := range l.transactions {
+		tx := &l.transactions[i]
+		tables, err := state.apply(tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay transaction %d: %w", i+1, err)
+		}
+		if (i+1)%checkpointInterval == 0 {
+			l.checkpoint(i+1, state)
+		}
+		summary := FileTransaction{
+			Number:  i + 1,
+			Index:   tx.index,
+			Time:    tx.date,
+			Comment: tx.comment,
+			Tables:  tables,
+			Diff:    tx.isDiff,
+		}
+		if tx.schema != nil {
+			summary.Schema = tx.schema.Name + " " + tx.schema.Version
+		}
+		for _, t := range tables {
+			summary.Changed += t.Inserted + t.Modified + t.Deleted
+		}
+		transactions = append(transactions, summary)
+	}
+	return transactions, nil
+}
+
+// SeekFile serves the database file opened with OpenFile as it was just after transaction
+// number, counted from 1; 0 or less stands for the latest. Every read then sees the
+// database as of that transaction, so scrubbing through FileTransactions shows when a row
+// changed. Only the transactions since the current position, or since the closest
+// checkpoint, are replayed.
+func (c *OVSDBClient) SeekFile(number int) (*DBFileInfo, error) {
+	file := c.file.Load()
+	if file == nil {
+		return nil, fmt.Errorf("no database file open")
+	}
+	log := file.log
+	if number > len(log.transactions) {
+		return nil, fmt.Errorf("transaction %d not found; the file has %d", number, len(log.transactions))
+	}
+	if number <= 0 {
+		number = len(log.transactions)
+	}
+	// Replay forward from the position served now, or from the closest checkpoint
+	state, err := log.replayFrom(file.state, file.info.Position, number)
+	if err != nil {
+		return nil, err
+	}
+	if state.schema.Name != c.dbName {
+		return nil, fmt.Errorf("database %s is named %s as of transaction %d", c.dbName, state.schema.Name, number)
+	}
+
+	info := file.info
+	info.Position = number
+	c.file.Store(&dbFile{info: info, log: log, state: state})
+	c.resetPages()
+	return &info, nil
+}
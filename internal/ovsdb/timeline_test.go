@@ -0,0 +1,66 @@
+package ovsdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileTransactions(t *testing.T) {
+	c, _ := openFile(t, standaloneFile)
+	transactions, err := c.FileTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileTransaction{
+		{Number: 1, Schema: "Test 1.0.0", Tables: []TableChanges{}},
+		{Number: 2, Time: time.UnixMilli(1700000000000), Comment: "create a", Tables: []TableChanges{{Table: "T", Inserted: 1}}, Changed: 1},
+		{Number: 3, Time: time.UnixMilli(1700000060000), Tables: []TableChanges{{Table: "T", Inserted: 1}}, Changed: 1},
+		{Number: 4, Time: time.UnixMilli(1700000120000), Tables: []TableChanges{{Table: "T", Modified: 1, Deleted: 1}}, Diff: true, Changed: 2},
+	}
+	if !reflect.DeepEqual(transactions, want) {
+		t.Errorf("transactions = %+v, want %+v", transactions, want)
+	}
+
+	c, _ = openFile(t, clusteredFile)
+	transactions, err = c.FileTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []uint64
+	for _, tx := range transactions {
+		indexes = append(indexes, tx.Index)
+	}
+	if !reflect.DeepEqual(indexes, []uint64{5, 6, 8}) || transactions[0].Schema != "Test 1.0.0" || transactions[1].Comment != "create c" || !transactions[2].Diff {
+		t.Errorf("clustered transactions = %+v", transactions)
+	}
+
+	if _, err := (&OVSDBClient{}).FileTransactions(); err == nil {
+		t.Error("listed the transactions without a file")
+	}
+}
+
+func TestSeekFile(t *testing.T) {
+	c, _ := openFile(t, standaloneFile)
+
+	// Reads see the database as of the transaction sought
+	if info, err := c.SeekFile(3); err != nil || info.Position != 3 || c.FileInfo().Position != 3 {
+		t.Fatalf("SeekFile(3) = %+v, %v", info, err)
+	}
+	want := map[string]map[string]interface{}{
+		"a": {"name": "a", "ports": []interface{}{"p1", "p2"}, "opts": map[string]interface{}{"k": "v"}},
+		"b": {"name": "b", "ports": []interface{}{}, "opts": map[string]interface{}{}},
+	}
+	if got := fileRows(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows as of transaction 3 = %v, want %v", got, want)
+	}
+	if info, err := c.SeekFile(1); err != nil || len(fileRows(t, c)) != 0 {
+		t.Errorf("SeekFile(1) = %+v, %v with rows", info, err)
+	}
+	if _, err := c.SeekFile(5); err == nil {
+		t.Error("seeking past the last transaction succeeded")
+	}
+	if info, err := c.SeekFile(0); err != nil || info.Position != 4 || len(fileRows(t, c)) != 1 {
+		t.Errorf("SeekFile(0) = %+v, %v", info, err)
+	}
+}
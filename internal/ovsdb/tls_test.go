@@ -0,0 +1,199 @@
+package ovsdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates, as ovs-pki does, into a temporary directory
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{key: key, cert: cert, dir: t.TempDir()}
+}
+
+// caFile writes the CA certificate and returns its path
+func (ca *testCA) caFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(ca.dir, "cacert.pem")
+	if err := os.WriteFile(path, ca.pem(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (ca *testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// issue writes a key and a certificate for name, followed by the CA certificate, and
+// returns their paths
+func (ca *testCA) issue(t *testing.T, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(ca.dir, name+"-privkey.pem")
+	certFile := filepath.Join(ca.dir, name+"-cert.pem")
+	chain := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), ca.pem()...)
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, chain, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile, certFile
+}
+
+// startTLSServer serves the database over TLS with a certificate of the CA, requiring
+// clients to present one too, and returns its ssl: endpoint
+func startTLSServer(t *testing.T, ca *testCA) string {
+	t.Helper()
+	sock := startServer(t)
+	keyFile, certFile := ca.issue(t, "server")
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := x509.NewCertPool()
+	clients.AddCert(ca.cert)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forward(t, l, "unix", sock)
+	return "ssl:" + l.Addr().String()
+}
+
+func TestConnectTLS(t *testing.T) {
+	ca := newTestCA(t)
+	endpoint := startTLSServer(t, ca)
+	keyFile, certFile := ca.issue(t, "client")
+	other := newTestCA(t)
+
+	tests := []struct {
+		name string
+		tls  *TLSConfig
+		err  string // part of the error, empty when the client connects
+	}{
+		{"pinned CA", &TLSConfig{PrivateKey: keyFile, Certificate: certFile, CACert: ca.caFile(t)}, ""},
+		{"other CA", &TLSConfig{PrivateKey: keyFile, Certificate: certFile, CACert: other.caFile(t)}, "failed to connect"},
+		{"no client certificate", &TLSConfig{CACert: ca.caFile(t)}, "failed to connect"},
+		{"no CA", &TLSConfig{PrivateKey: keyFile, Certificate: certFile}, "a CA certificate is required"},
+		{"key without certificate", &TLSConfig{PrivateKey: keyFile, CACert: ca.caFile(t)}, "must be provided together"},
+		{"missing CA file", &TLSConfig{PrivateKey: keyFile, Certificate: certFile, CACert: filepath.Join(t.TempDir(), "none.pem")}, "failed to read CA certificate"},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		c := &OVSDBClient{}
+		err := c.Connect(ctx, ConnectionConfig{TLS: tt.tls}, endpoint, "")
+		cancel()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.err)
+			}
+			if err == nil {
+				c.Disconnect()
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if _, err := c.GetTableData(context.Background(), "", "Bridge"); err != nil {
+			t.Errorf("%s: failed to read over TLS: %v", tt.name, err)
+		}
+		c.Disconnect()
+	}
+}
+
+func TestBootstrapCACert(t *testing.T) {
+	ca := newTestCA(t)
+	endpoint := startTLSServer(t, ca)
+	keyFile, certFile := ca.issue(t, "client")
+	caFile := filepath.Join(t.TempDir(), "cacert.pem")
+
+	c := &OVSDBClient{}
+	config := ConnectionConfig{TLS: &TLSConfig{PrivateKey: keyFile, Certificate: certFile, CACert: caFile, BootstrapCACert: true}}
+	if err := c.Connect(context.Background(), config, endpoint, ""); err != nil {
+		t.Fatal(err)
+	}
+	c.Disconnect()
+	got, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, ca.pem()) {
+		t.Errorf("bootstrapped CA certificate differs from the server's CA")
+	}
+
+	// Once stored, the CA is pinned: a server of another CA is refused
+	other := newTestCA(t)
+	config.TLS.PrivateKey, config.TLS.Certificate = other.issue(t, "client")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx, config, startTLSServer(t, other), ""); err == nil {
+		c.Disconnect()
+		t.Error("connected to a server whose certificate the bootstrapped CA did not issue")
+	}
+}
@@ -0,0 +1,124 @@
+package ovsdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Tunnel represents an active SSH tunnel or other transport exposed as a local endpoint
+type Tunnel struct {
+	LocalEndpoint string
+	Stop          func()
+	// Err reports the most recent failure of the underlying transport, if it tracks one
+	Err func() error
+}
+
+// Transport opens a path to a remote OVSDB endpoint and exposes it as a local endpoint
+// that libovsdb can dial
+type Transport interface {
+	Establish(ctx context.Context, remoteEndpoint string) (*Tunnel, error)
+}
+
+// transport returns the Transport selected by the configuration, or nil for direct connections
+func (c ConnectionConfig) transport() Transport {
+	if c.Command != nil {
+		return c.Command
+	}
+	if c.Host != "" {
+		return sshTransport{config: c}
+	}
+	return nil
+}
+
+// dialFunc opens a new stream to the remote endpoint for each local connection
+type dialFunc func() (io.ReadWriteCloser, error)
+
+// establishLocalForwarder picks the local listener for a forwarder type ("tcp", "unix" or "auto")
+func establishLocalForwarder(forwarderType string, remoteEndpoint string, dial dialFunc) (*Tunnel, error) {
+	switch forwarderType {
+	case "tcp":
+		return establishTCPTunnel(dial, remoteEndpoint)
+	case "unix":
+		return establishUnixTunnel(dial, remoteEndpoint)
+	case "auto":
+		if runtime.GOOS == "windows" || !strings.HasPrefix(remoteEndpoint, "unix:") {
+			return establishTCPTunnel(dial, remoteEndpoint)
+		}
+		return establishUnixTunnel(dial, remoteEndpoint)
+	}
+	return nil, fmt.Errorf("unsupported forwarder type: %s", forwarderType)
+}
+
+// establishTCPTunnel sets up a local TCP listener and forwards traffic to the remote endpoint
+func establishTCPTunnel(dial dialFunc, remoteEndpoint string) (*Tunnel, error) {
+	localListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on local TCP: %w", err)
+	}
+
+	go forwardConnections(localListener, dial)
+
+	scheme := "tcp:"
+	if strings.HasPrefix(remoteEndpoint, "ssl:") {
+		scheme = "ssl:"
+	}
+	return &Tunnel{
+		LocalEndpoint: scheme + localListener.Addr().String(),
+		Stop:          func() { localListener.Close() },
+	}, nil
+}
+
+// establishUnixTunnel sets up a local Unix domain socket listener and forwards traffic to the remote endpoint
+func establishUnixTunnel(dial dialFunc, remoteEndpoint string) (*Tunnel, error) {
+	localPath := fmt.Sprintf("/tmp/ovsdb-tunnel-%d.sock", rand.Int63())
+	localListener, err := net.Listen("unix", localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on local Unix socket: %w", err)
+	}
+
+	go func() {
+		defer os.Remove(localPath) // Clean up socket file
+		forwardConnections(localListener, dial)
+	}()
+
+	return &Tunnel{
+		LocalEndpoint: "unix:" + localPath,
+		Stop: func() {
+			localListener.Close()
+			os.Remove(localPath)
+		},
+	}, nil
+}
+
+// forwardConnections accepts local connections until the listener is closed and pipes
+// each one to a freshly dialed remote stream
+func forwardConnections(localListener net.Listener, dial dialFunc) {
+	defer localListener.Close()
+	for {
+		localConn, err := localListener.Accept()
+		if err != nil {
+			return
+		}
+		remoteConn, err := dial()
+		if err != nil {
+			localConn.Close()
+			continue
+		}
+		go func() {
+			defer localConn.Close()
+			defer remoteConn.Close()
+			io.Copy(localConn, remoteConn)
+		}()
+		go func() {
+			defer localConn.Close()
+			defer remoteConn.Close()
+			io.Copy(remoteConn, localConn)
+		}()
+	}
+}
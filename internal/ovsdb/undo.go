@@ -0,0 +1,549 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// maxUndoEntries bounds the undo history of a client
+const maxUndoEntries = 100
+
+// UndoEntry is a committed transaction in the undo history
+type UndoEntry struct {
+	ID       int       `json:"id"`
+	Time     time.Time `json:"time"`
+	Database string    `json:"database"`
+	Summary  string    `json:"summary"` // the edits, such as "update Logical_Switch, delete ACL"
+	Undone   bool      `json:"undone"`
+
+	inverse []ovsdb.Operation
+}
+
+// rowChange follows one row through a transaction
+type rowChange struct {
+	table  string
+	before ovsdb.Row // nil for a row the transaction inserted
+	after  ovsdb.Row // nil for a row the transaction deleted
+}
+
+// UndoHistory returns the transactions that can be undone, newest first
+func (c *OVSDBClient) UndoHistory() []UndoEntry {
+	c.undoMu.Lock()
+	defer c.undoMu.Unlock()
+	entries := make([]UndoEntry, 0, len(c.undo))
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		entries = append(entries, *c.undo[i])
+	}
+	return entries
+}
+
+// UndoLast reverts the most recent transaction that has not been undone yet
+func (c *OVSDBClient) UndoLast(ctx context.Context) (*TransactionResult, error) {
+	c.undoMu.Lock()
+	id := 0
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		if !c.undo[i].Undone {
+			id = c.undo[i].ID
+			break
+		}
+	}
+	c.undoMu.Unlock()
+	if id == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	return c.UndoTransaction(ctx, id)
+}
+
+// UndoTransaction reverts a transaction of the undo history. Every row it changed must still
+// hold the values the transaction left; otherwise nothing is written and the result holds
+// the Conflict. Rows it deleted are inserted again, with new uuids.
+func (c *OVSDBClient) UndoTransaction(ctx context.Context, id int) (*TransactionResult, error) {
+	c.undoMu.Lock()
+	var entry *UndoEntry
+	for _, e := range c.undo {
+		if e.ID == id {
+			entry = e
+		}
+	}
+	undone := entry != nil && entry.Undone
+	c.undoMu.Unlock()
+	if entry == nil {
+		return nil, fmt.Errorf("transaction %d is not in the undo history", id)
+	}
+	if undone {
+		return nil, fmt.Errorf("transaction %d was already undone", id)
+	}
+
+	comment := fmt.Sprintf("undo of transaction %d: %s", entry.ID, entry.Summary)
+	ops := append([]ovsdb.Operation{{Op: ovsdb.OperationComment, Comment: &comment}}, entry.inverse...)
+	results, err := c.Transact(ctx, entry.Database, ops...)
+	if err != nil {
+		return nil, fmt.Errorf("undo failed: %w", err)
+	}
+	outcome := transactionResult(ops, results)
+	for i, op := range ops {
+		if op.Op == ovsdb.OperationWait && i < len(results) && results[i].Error == "timed out" {
+			if outcome.Conflict, err = c.guardConflict(ctx, entry.Database, ops[i:]); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if outcome.Committed {
+		c.undoMu.Lock()
+		entry.Undone = true
+		c.undoMu.Unlock()
+	}
+	return outcome, nil
+}
+
+// guardConflict reads the current state of the row behind the failed wait opening ops
+func (c *OVSDBClient) guardConflict(ctx context.Context, dbName string, ops []ovsdb.Operation) (*Conflict, error) {
+	wait := ops[0]
+	table, err := c.tableSchema(ctx, dbName, wait.Table)
+	if err != nil {
+		return nil, err
+	}
+	edit := Edit{Table: wait.Table, UUID: wait.Where[0].Value.(ovsdb.UUID).GoUUID}
+	if len(wait.Rows) > 0 {
+		edit.Expect = typedRow(table, wait.Rows[0])
+	}
+	if len(ops) > 1 && ops[1].Op == ovsdb.OperationUpdate {
+		edit.Row = typedRow(table, ops[1].Row)
+	}
+	return c.conflict(ctx, dbName, edit)
+}
+
+// recordUndo works out the inverse of a committed transaction from the rows captured before
+// each of its operations and the rows the commit removed or changed on its own, and adds it
+// to the undo history
+func (c *OVSDBClient) recordUndo(ctx context.Context, dbName string, edits []Edit, tx *appliedTransaction) (int, error) {
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return 0, err
+	}
+	changes, order, err := trackChanges(schema, tx)
+	if err != nil {
+		return 0, fmt.Errorf("cannot undo this transaction: %w", err)
+	}
+	collected, err := c.collectedRows(ctx, dbName, collectionCandidates(schema, tx, changes, order))
+	if err != nil {
+		return 0, fmt.Errorf("cannot undo this transaction: %w", err)
+	}
+	order = commitChanges(schema, tx, changes, order, collected)
+	inverse := inverseOperations(schema, changes, order)
+	if len(inverse) == 0 {
+		return 0, nil
+	}
+
+	summary := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		summary = append(summary, edit.Op+" "+edit.Table)
+	}
+	if c.isPrimary(dbName) {
+		dbName = c.dbName
+	}
+
+	c.undoMu.Lock()
+	defer c.undoMu.Unlock()
+	c.undoSeq++
+	c.undo = append(c.undo, &UndoEntry{
+		ID:       c.undoSeq,
+		Time:     time.Now(),
+		Database: dbName,
+		Summary:  strings.Join(summary, ", "),
+		inverse:  inverse,
+	})
+	if len(c.undo) > maxUndoEntries {
+		c.undo = c.undo[len(c.undo)-maxUndoEntries:]
+	}
+	return c.undoSeq, nil
+}
+
+// trackChanges follows every row the transaction touched from its state before the first
+// operation on it to its state after the last one. Rows are returned in the order they
+// were first touched.
+func trackChanges(schema *ovsdb.DatabaseSchema, tx *appliedTransaction) (map[string]*rowChange, []string, error) {
+	named := make(map[string]string)
+	for i, op := range tx.ops {
+		if op.Op == ovsdb.OperationInsert && op.UUIDName != "" {
+			named[op.UUIDName] = tx.results[i].UUID.GoUUID
+		}
+	}
+
+	changes := make(map[string]*rowChange)
+	var order []string
+	for i, op := range tx.ops {
+		table := schema.Table(op.Table)
+		switch op.Op {
+		case ovsdb.OperationInsert:
+			uuid := tx.results[i].UUID.GoUUID
+			after := make(ovsdb.Row, len(op.Row))
+			for column, value := range op.Row {
+				after[column] = substituteUUIDs(value, named)
+			}
+			changes[uuid] = &rowChange{table: op.Table, after: after}
+			order = append(order, uuid)
+		case ovsdb.OperationUpdate, ovsdb.OperationMutate, ovsdb.OperationDelete:
+			for _, row := range tx.before[i] {
+				uuid, ok := row["_uuid"].(ovsdb.UUID)
+				if !ok {
+					return nil, nil, fmt.Errorf("row of %s captured without its uuid", op.Table)
+				}
+				change, ok := changes[uuid.GoUUID]
+				if !ok {
+					change = &rowChange{table: op.Table, before: row}
+					changes[uuid.GoUUID] = change
+					order = append(order, uuid.GoUUID)
+				}
+				after, err := applyOperation(table, op, row, named)
+				if err != nil {
+					return nil, nil, err
+				}
+				change.after = after
+			}
+		}
+	}
+	return changes, order, nil
+}
+
+// applyOperation returns a row as an update, mutate or delete leaves it; nil once deleted
+func applyOperation(table *ovsdb.TableSchema, op ovsdb.Operation, row ovsdb.Row, named map[string]string) (ovsdb.Row, error) {
+	if op.Op == ovsdb.OperationDelete {
+		return nil, nil
+	}
+	after := make(ovsdb.Row, len(row))
+	for column, value := range row {
+		after[column] = value
+	}
+	for column, value := range op.Row {
+		after[column] = substituteUUIDs(value, named)
+	}
+	for _, m := range op.Mutations {
+		column := table.Column(m.Column)
+		value, err := applyMutation(column, valueOrDefault(column, after[m.Column]), m.Mutator, substituteUUIDs(m.Value, named))
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", m.Column, err)
+		}
+		after[m.Column] = value
+	}
+	return after, nil
+}
+
+// inverseOperations builds the operations that bring every row back to its state before the
+// transaction, each guarded by a wait on the state the transaction left it in. Deleted rows
+// are inserted first, under names that the rows referring to them are restored with.
+func inverseOperations(schema *ovsdb.DatabaseSchema, changes map[string]*rowChange, order []string) []ovsdb.Operation {
+	names := make(map[string]string)
+	for _, uuid := range order {
+		if change := changes[uuid]; change.before != nil && change.after == nil {
+			names[uuid] = "row" + strings.ReplaceAll(uuid, "-", "_")
+		}
+	}
+
+	var inserts, updates, deletes []ovsdb.Operation
+	for _, uuid := range order {
+		change := changes[uuid]
+		table := schema.Table(change.table)
+		switch {
+		case change.before == nil && change.after == nil:
+			// Inserted and deleted again by the same transaction
+		case change.before == nil:
+			deletes = append(deletes,
+				undoGuard(table, change.table, uuid, change.after, dataColumns(change.after)),
+				ovsdb.Operation{Op: ovsdb.OperationDelete, Table: change.table, Where: uuidWhere(uuid)})
+		case change.after == nil:
+			row := make(ovsdb.Row, len(change.before))
+			for _, column := range dataColumns(change.before) {
+				row[column] = substituteUUIDs(change.before[column], names)
+			}
+			inserts = append(inserts, ovsdb.Operation{
+				Op:       ovsdb.OperationInsert,
+				Table:    change.table,
+				UUIDName: names[uuid],
+				Row:      row,
+			})
+		default:
+			columns := changedColumns(table, change.before, change.after)
+			if len(columns) == 0 {
+				continue
+			}
+			row := make(ovsdb.Row, len(columns))
+			for _, column := range columns {
+				row[column] = substituteUUIDs(valueOrDefault(table.Column(column), change.before[column]), names)
+			}
+			updates = append(updates,
+				undoGuard(table, change.table, uuid, change.after, columns),
+				ovsdb.Operation{Op: ovsdb.OperationUpdate, Table: change.table, Where: uuidWhere(uuid), Row: row})
+		}
+	}
+	return append(append(inserts, updates...), deletes...)
+}
+
+// undoGuard builds the wait that fails unless a row still holds the given columns of a state
+func undoGuard(table *ovsdb.TableSchema, tableName string, uuid string, state ovsdb.Row, columns []string) ovsdb.Operation {
+	expected := make(ovsdb.Row, len(columns))
+	for _, column := range columns {
+		expected[column] = valueOrDefault(table.Column(column), state[column])
+	}
+	timeout := 0
+	return ovsdb.Operation{
+		Op:      ovsdb.OperationWait,
+		Table:   tableName,
+		Timeout: &timeout,
+		Where:   uuidWhere(uuid),
+		Columns: columns,
+		Until:   string(ovsdb.WaitConditionEqual),
+		Rows:    []ovsdb.Row{expected},
+	}
+}
+
+func uuidWhere(uuid string) []ovsdb.Condition {
+	return []ovsdb.Condition{ovsdb.NewCondition("_uuid", ovsdb.ConditionEqual, ovsdb.UUID{GoUUID: uuid})}
+}
+
+// dataColumns returns the sorted columns of a row, leaving out _uuid and _version
+func dataColumns(row ovsdb.Row) []string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if !strings.HasPrefix(column, "_") {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// changedColumns returns the sorted columns whose values differ between two states of a row
+func changedColumns(table *ovsdb.TableSchema, before, after ovsdb.Row) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, row := range []ovsdb.Row{before, after} {
+		for _, column := range dataColumns(row) {
+			schema := table.Column(column)
+			if seen[column] || schema == nil {
+				continue
+			}
+			seen[column] = true
+			if valueKey(schema, before[column]) != valueKey(schema, after[column]) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// valueKey returns a form of a column value that is equal for equal values, whatever the
+// order of set elements and of map pairs
+func valueKey(column *ovsdb.ColumnSchema, v interface{}) string {
+	data, _ := json.Marshal(typedValue(column, valueOrDefault(column, v)))
+	return string(data)
+}
+
+// valueOrDefault stands the default value of a column, as RFC 7047 defines it, in for a
+// value the server left out of a row
+func valueOrDefault(column *ovsdb.ColumnSchema, v interface{}) interface{} {
+	if v != nil || column == nil {
+		return v
+	}
+	switch column.Type {
+	case ovsdb.TypeMap:
+		return ovsdb.OvsMap{GoMap: map[interface{}]interface{}{}}
+	case ovsdb.TypeSet:
+		return ovsdb.OvsSet{GoSet: []interface{}{}}
+	}
+	switch baseType(column).Type {
+	case ovsdb.TypeInteger:
+		return 0
+	case ovsdb.TypeReal:
+		return 0.0
+	case ovsdb.TypeBoolean:
+		return false
+	case ovsdb.TypeString:
+		return ""
+	case ovsdb.TypeUUID:
+		return ovsdb.UUID{GoUUID: "00000000-0000-0000-0000-000000000000"}
+	}
+	return v
+}
+
+// substituteUUIDs replaces the uuids of a value that are keys of names with a uuid that
+// names them: a real uuid for a named uuid, or a named uuid for a deleted row
+func substituteUUIDs(v interface{}, names map[string]string) interface{} {
+	if len(names) == 0 {
+		return v
+	}
+	switch val := v.(type) {
+	case ovsdb.UUID:
+		if name, ok := names[val.GoUUID]; ok {
+			return ovsdb.UUID{GoUUID: name}
+		}
+		return val
+	case ovsdb.OvsSet:
+		set := make([]interface{}, 0, len(val.GoSet))
+		for _, elem := range val.GoSet {
+			set = append(set, substituteUUIDs(elem, names))
+		}
+		return ovsdb.OvsSet{GoSet: set}
+	case ovsdb.OvsMap:
+		goMap := make(map[interface{}]interface{}, len(val.GoMap))
+		for k, elem := range val.GoMap {
+			goMap[substituteUUIDs(k, names)] = substituteUUIDs(elem, names)
+		}
+		return ovsdb.OvsMap{GoMap: goMap}
+	default:
+		return v
+	}
+}
+
+// applyMutation computes a column value after a mutation, following RFC 7047
+func applyMutation(column *ovsdb.ColumnSchema, current interface{}, mutator ovsdb.Mutator, value interface{}) (interface{}, error) {
+	switch mutator {
+	case ovsdb.MutateOperationAdd, ovsdb.MutateOperationSubtract, ovsdb.MutateOperationMultiply,
+		ovsdb.MutateOperationDivide, ovsdb.MutateOperationModulo:
+		integer := baseType(column).Type == ovsdb.TypeInteger
+		if column.Type != ovsdb.TypeSet {
+			return arithmetic(mutator, current, value, integer)
+		}
+		elems := setElements(current)
+		set := make([]interface{}, 0, len(elems))
+		for _, elem := range elems {
+			result, err := arithmetic(mutator, elem, value, integer)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, result)
+		}
+		return ovsdb.OvsSet{GoSet: set}, nil
+	case ovsdb.MutateOperationInsert, ovsdb.MutateOperationDelete:
+		if column.Type == ovsdb.TypeMap {
+			current, _ := current.(ovsdb.OvsMap)
+			return mutateMap(current, mutator, value), nil
+		}
+		return mutateSet(setElements(current), mutator, setElements(value)), nil
+	}
+	return nil, fmt.Errorf("unknown mutator %s", mutator)
+}
+
+// arithmetic applies an arithmetic mutator to one number
+func arithmetic(mutator ovsdb.Mutator, a, b interface{}, integer bool) (interface{}, error) {
+	if integer {
+		x, y := toInt(a), toInt(b)
+		switch mutator {
+		case ovsdb.MutateOperationAdd:
+			return x + y, nil
+		case ovsdb.MutateOperationSubtract:
+			return x - y, nil
+		case ovsdb.MutateOperationMultiply:
+			return x * y, nil
+		}
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if mutator == ovsdb.MutateOperationDivide {
+			return x / y, nil
+		}
+		return x % y, nil
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch mutator {
+	case ovsdb.MutateOperationAdd:
+		return x + y, nil
+	case ovsdb.MutateOperationSubtract:
+		return x - y, nil
+	case ovsdb.MutateOperationMultiply:
+		return x * y, nil
+	case ovsdb.MutateOperationDivide:
+		return x / y, nil
+	}
+	return nil, fmt.Errorf("mutator %s is not valid for reals", mutator)
+}
+
+// mutateSet inserts elements missing from a set, or deletes those present
+func mutateSet(elems []interface{}, mutator ovsdb.Mutator, operand []interface{}) ovsdb.OvsSet {
+	given := make(map[string]bool, len(operand))
+	for _, elem := range operand {
+		given[atomKey(elem)] = true
+	}
+	set := make([]interface{}, 0, len(elems)+len(operand))
+	present := make(map[string]bool, len(elems))
+	for _, elem := range elems {
+		if mutator == ovsdb.MutateOperationDelete && given[atomKey(elem)] {
+			continue
+		}
+		present[atomKey(elem)] = true
+		set = append(set, elem)
+	}
+	if mutator == ovsdb.MutateOperationInsert {
+		for _, elem := range operand {
+			if !present[atomKey(elem)] {
+				present[atomKey(elem)] = true
+				set = append(set, elem)
+			}
+		}
+	}
+	return ovsdb.OvsSet{GoSet: set}
+}
+
+// mutateMap inserts pairs whose key is missing from a map, or deletes pairs: those equal to
+// a given pair, or every pair of a key given as a set
+func mutateMap(current ovsdb.OvsMap, mutator ovsdb.Mutator, operand interface{}) ovsdb.OvsMap {
+	keys := make(map[string]interface{}, len(current.GoMap))
+	for k := range current.GoMap {
+		keys[atomKey(k)] = k
+	}
+	goMap := make(map[interface{}]interface{}, len(current.GoMap))
+	for k, v := range current.GoMap {
+		goMap[k] = v
+	}
+	switch val := operand.(type) {
+	case ovsdb.OvsMap:
+		for k, v := range val.GoMap {
+			existing, ok := keys[atomKey(k)]
+			switch {
+			case mutator == ovsdb.MutateOperationInsert && !ok:
+				keys[atomKey(k)] = k
+				goMap[k] = v
+			case mutator == ovsdb.MutateOperationDelete && ok && atomKey(goMap[existing]) == atomKey(v):
+				delete(goMap, existing)
+			}
+		}
+	default:
+		if mutator == ovsdb.MutateOperationDelete {
+			for _, k := range setElements(operand) {
+				if existing, ok := keys[atomKey(k)]; ok {
+					delete(goMap, existing)
+				}
+			}
+		}
+	}
+	return ovsdb.OvsMap{GoMap: goMap}
+}
+
+// atomKey identifies an atom whatever its Go type; integers decoded as float64 match ints
+func atomKey(v interface{}) string {
+	switch a := v.(type) {
+	case ovsdb.UUID:
+		return "uuid:" + a.GoUUID
+	case int:
+		return "number:" + strconv.Itoa(a)
+	case float64:
+		if a == math.Trunc(a) && math.Abs(a) <= maxSafeInteger {
+			return "number:" + strconv.Itoa(int(a))
+		}
+		return "number:" + strconv.FormatFloat(a, 'g', -1, 64)
+	case string:
+		return "string:" + a
+	default:
+		return fmt.Sprint(a)
+	}
+}
@@ -0,0 +1,351 @@
+package ovsdb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+const testSchemaJSON = `{
+	"name": "OVN_Northbound",
+	"version": "7.0.0",
+	"tables": {
+		"Logical_Switch": {
+			"isRoot": true,
+			"columns": {
+				"name": {"type": "string"},
+				"ports": {"type": {"key": {"type": "uuid", "refTable": "Logical_Switch_Port"}, "min": 0, "max": "unlimited"}},
+				"other_config": {"type": {"key": "string", "value": "string", "min": 0, "max": "unlimited"}},
+				"vlans": {"type": {"key": "integer", "min": 0, "max": "unlimited"}},
+				"priority": {"type": "integer"}
+			}
+		},
+		"Logical_Switch_Port": {
+			"columns": {
+				"name": {"type": "string"},
+				"tag": {"type": {"key": {"type": "integer", "minInteger": 1, "maxInteger": 4095}, "min": 0, "max": 1}}
+			}
+		},
+		"Port_Group": {
+			"isRoot": true,
+			"columns": {
+				"name": {"type": "string"},
+				"ports": {"type": {"key": {"type": "uuid", "refTable": "Logical_Switch_Port", "refType": "weak"}, "min": 0, "max": "unlimited"}}
+			}
+		}
+	}
+}`
+
+// UUIDs of rows used across the tests
+const (
+	switchUUID = "5b2d1f0e-7a4c-4d7e-9a51-2c3b4d5e6f70"
+	port1UUID  = "11111111-1111-4111-8111-111111111111"
+	port2UUID  = "22222222-2222-4222-8222-222222222222"
+	groupUUID  = "33333333-3333-4333-8333-333333333333"
+)
+
+func testSchema(t *testing.T) *ovsdb.DatabaseSchema {
+	t.Helper()
+	var schema ovsdb.DatabaseSchema
+	if err := json.Unmarshal([]byte(testSchemaJSON), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func uuidSet(uuids ...string) ovsdb.OvsSet {
+	set := make([]interface{}, 0, len(uuids))
+	for _, uuid := range uuids {
+		set = append(set, ovsdb.UUID{GoUUID: uuid})
+	}
+	return ovsdb.OvsSet{GoSet: set}
+}
+
+func stringMap(pairs ...string) ovsdb.OvsMap {
+	goMap := make(map[interface{}]interface{}, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		goMap[pairs[i]] = pairs[i+1]
+	}
+	return ovsdb.OvsMap{GoMap: goMap}
+}
+
+func TestApplyMutation(t *testing.T) {
+	table := testSchema(t).Table("Logical_Switch")
+	tests := []struct {
+		name    string
+		column  string
+		current interface{}
+		mutator ovsdb.Mutator
+		value   interface{}
+		want    interface{}
+	}{
+		{"insert into set", "ports", uuidSet(port1UUID), ovsdb.MutateOperationInsert, uuidSet(port1UUID, port2UUID), uuidSet(port1UUID, port2UUID)},
+		{"insert single element", "ports", uuidSet(), ovsdb.MutateOperationInsert, ovsdb.UUID{GoUUID: port1UUID}, uuidSet(port1UUID)},
+		{"delete from set", "ports", uuidSet(port1UUID, port2UUID), ovsdb.MutateOperationDelete, uuidSet(port1UUID), uuidSet(port2UUID)},
+		{"delete missing element", "ports", uuidSet(port2UUID), ovsdb.MutateOperationDelete, uuidSet(port1UUID), uuidSet(port2UUID)},
+		{"insert keeps existing key", "other_config", stringMap("a", "1"), ovsdb.MutateOperationInsert, stringMap("a", "2", "b", "3"), stringMap("a", "1", "b", "3")},
+		{"delete matching pair", "other_config", stringMap("a", "1", "b", "2"), ovsdb.MutateOperationDelete, stringMap("a", "1", "b", "9"), stringMap("b", "2")},
+		{"delete keys", "other_config", stringMap("a", "1", "b", "2"), ovsdb.MutateOperationDelete, ovsdb.OvsSet{GoSet: []interface{}{"a"}}, stringMap("b", "2")},
+		{"integer add", "priority", 5, ovsdb.MutateOperationAdd, 3, 8},
+		{"integer decoded as real", "priority", float64(7), ovsdb.MutateOperationDivide, 2, 3},
+		{"integer modulo", "priority", 7, ovsdb.MutateOperationModulo, 4, 3},
+		{"arithmetic on set", "vlans", ovsdb.OvsSet{GoSet: []interface{}{10, 20}}, ovsdb.MutateOperationMultiply, 2, ovsdb.OvsSet{GoSet: []interface{}{20, 40}}},
+	}
+	for _, tt := range tests {
+		column := table.Column(tt.column)
+		got, err := applyMutation(column, tt.current, tt.mutator, tt.value)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if valueKey(column, got) != valueKey(column, tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, valueKey(column, got), valueKey(column, tt.want))
+		}
+	}
+
+	if _, err := applyMutation(table.Column("priority"), 1, ovsdb.MutateOperationDivide, 0); err == nil {
+		t.Error("integer division by zero was accepted")
+	}
+}
+
+func TestInverseOperations(t *testing.T) {
+	schema := testSchema(t)
+	newPortUUID := "44444444-4444-4444-8444-444444444444"
+	changes := map[string]*rowChange{
+		switchUUID: {
+			table:  "Logical_Switch",
+			before: ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: switchUUID}, "name": "sw0", "ports": uuidSet(port1UUID), "other_config": stringMap()},
+			after:  ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: switchUUID}, "name": "sw1", "ports": uuidSet(newPortUUID), "other_config": stringMap()},
+		},
+		port1UUID: {
+			table:  "Logical_Switch_Port",
+			before: ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: port1UUID}, "name": "p1", "tag": ovsdb.OvsSet{GoSet: []interface{}{100}}},
+		},
+		newPortUUID: {
+			table: "Logical_Switch_Port",
+			after: ovsdb.Row{"name": "p2"},
+		},
+	}
+	ops := inverseOperations(schema, changes, []string{switchUUID, port1UUID, newPortUUID})
+
+	var kinds []string
+	for _, op := range ops {
+		kinds = append(kinds, op.Op+" "+op.Table)
+	}
+	want := []string{
+		"insert Logical_Switch_Port",
+		"wait Logical_Switch",
+		"update Logical_Switch",
+		"wait Logical_Switch_Port",
+		"delete Logical_Switch_Port",
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("got operations %q, want %q", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("got operations %q, want %q", kinds, want)
+		}
+	}
+
+	// The deleted port comes back under a name the switch refers to it by
+	insert := ops[0]
+	name := "row11111111_1111_4111_8111_111111111111"
+	if insert.UUIDName != name || insert.Row["name"] != "p1" {
+		t.Errorf("insert is %+v", insert)
+	}
+	if _, ok := insert.Row["_uuid"]; ok {
+		t.Error("reinserted row keeps its _uuid")
+	}
+	wait, update := ops[1], ops[2]
+	if got := wait.Columns; len(got) != 2 || got[0] != "name" || got[1] != "ports" {
+		t.Errorf("wait guards columns %q, want name and ports", got)
+	}
+	if wait.Rows[0]["name"] != "sw1" {
+		t.Errorf("wait expects %v, want the state the transaction left", wait.Rows[0])
+	}
+	if update.Row["name"] != "sw0" {
+		t.Errorf("update restores name %v, want sw0", update.Row["name"])
+	}
+	ports := schema.Table("Logical_Switch").Column("ports")
+	if got, want := valueKey(ports, update.Row["ports"]), valueKey(ports, uuidSet(name)); got != want {
+		t.Errorf("update restores ports %s, want %s", got, want)
+	}
+	if _, ok := update.Row["other_config"]; ok {
+		t.Error("update writes a column the transaction did not change")
+	}
+	if ops[4].Where[0].Value.(ovsdb.UUID).GoUUID != newPortUUID {
+		t.Errorf("delete removes %v, want the inserted port", ops[4].Where)
+	}
+}
+
+func TestTrackChanges(t *testing.T) {
+	schema := testSchema(t)
+	before := ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: switchUUID}, "name": "sw0", "ports": uuidSet(port1UUID)}
+	tx := &appliedTransaction{
+		ops: []ovsdb.Operation{
+			{Op: ovsdb.OperationInsert, Table: "Logical_Switch_Port", UUIDName: "newport", Row: ovsdb.Row{"name": "p2"}},
+			{Op: ovsdb.OperationMutate, Table: "Logical_Switch", Where: uuidWhere(switchUUID), Mutations: []ovsdb.Mutation{
+				{Column: "ports", Mutator: ovsdb.MutateOperationInsert, Value: uuidSet("newport")},
+			}},
+			{Op: ovsdb.OperationUpdate, Table: "Logical_Switch", Where: uuidWhere(switchUUID), Row: ovsdb.Row{"name": "sw1"}},
+		},
+		results: []ovsdb.OperationResult{{UUID: ovsdb.UUID{GoUUID: port2UUID}}, {Count: 1}, {Count: 1}},
+		before: map[int][]ovsdb.Row{
+			1: {before},
+			2: {{"_uuid": ovsdb.UUID{GoUUID: switchUUID}, "name": "sw0", "ports": uuidSet(port1UUID, port2UUID)}},
+		},
+	}
+	changes, order, err := trackChanges(schema, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != port2UUID || order[1] != switchUUID {
+		t.Fatalf("order is %q, want the inserted port then the switch", order)
+	}
+	change := changes[switchUUID]
+	if change.before["name"] != "sw0" || change.after["name"] != "sw1" {
+		t.Errorf("switch went from %v to %v", change.before, change.after)
+	}
+	ports := schema.Table("Logical_Switch").Column("ports")
+	if got, want := valueKey(ports, change.after["ports"]), valueKey(ports, uuidSet(port1UUID, port2UUID)); got != want {
+		t.Errorf("ports after the transaction are %s, want %s with the named uuid resolved", got, want)
+	}
+	if changes[port2UUID].before != nil {
+		t.Error("inserted row has a state before the transaction")
+	}
+}
+
+func TestReleasedColumns(t *testing.T) {
+	schema := testSchema(t)
+	tests := []struct {
+		name string
+		op   ovsdb.Operation
+		want []string
+	}{
+		{"delete a switch", ovsdb.Operation{Op: ovsdb.OperationDelete, Table: "Logical_Switch"}, []string{"ports"}},
+		{"replace the ports", ovsdb.Operation{Op: ovsdb.OperationUpdate, Table: "Logical_Switch", Row: ovsdb.Row{"ports": uuidSet()}}, []string{"ports"}},
+		{"rename a switch", ovsdb.Operation{Op: ovsdb.OperationUpdate, Table: "Logical_Switch", Row: ovsdb.Row{"name": "sw1"}}, nil},
+		{"add a port", ovsdb.Operation{Op: ovsdb.OperationMutate, Table: "Logical_Switch", Mutations: []ovsdb.Mutation{
+			{Column: "ports", Mutator: ovsdb.MutateOperationInsert, Value: uuidSet(port1UUID)},
+		}}, nil},
+		{"remove a port", ovsdb.Operation{Op: ovsdb.OperationMutate, Table: "Logical_Switch", Mutations: []ovsdb.Mutation{
+			{Column: "ports", Mutator: ovsdb.MutateOperationDelete, Value: uuidSet(port1UUID)},
+		}}, []string{"ports"}},
+		{"delete a port group", ovsdb.Operation{Op: ovsdb.OperationDelete, Table: "Port_Group"}, nil},
+	}
+	for _, tt := range tests {
+		got := releasedColumns(schema, schema.Table(tt.op.Table), tt.op)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got columns %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := weakReferrers(schema, "Logical_Switch_Port"); strings.Join(got, ",") != "Port_Group" {
+		t.Errorf("weak referrers of ports are %q", got)
+	}
+}
+
+func TestCommitSelects(t *testing.T) {
+	schema := testSchema(t)
+	// The switch is deleted and its first port may be collected with it
+	ops := commitSelects(schema,
+		[]reference{{table: "Logical_Switch_Port", uuid: port1UUID}},
+		map[string]string{switchUUID: "Logical_Switch", port1UUID: "Logical_Switch_Port"})
+	if len(ops) != 2 {
+		t.Fatalf("got %d selects, want the port and the groups referring to it: %+v", len(ops), ops)
+	}
+	port := ops[0]
+	if port.Table != "Logical_Switch_Port" || len(port.Columns) != 0 || port.Where[0].Value != (ovsdb.UUID{GoUUID: port1UUID}) {
+		t.Errorf("collectable port is captured with %+v", port)
+	}
+	group := ops[1]
+	if group.Table != "Port_Group" || strings.Join(group.Columns, ",") != "_uuid,ports" {
+		t.Fatalf("port groups are captured with %+v", group)
+	}
+	where := group.Where[0]
+	ports := schema.Table("Port_Group").Column("ports")
+	if where.Column != "ports" || where.Function != ovsdb.ConditionIncludes || valueKey(ports, where.Value) != valueKey(ports, uuidSet(port1UUID)) {
+		t.Errorf("port groups are selected with %+v, want the ones including the port", where)
+	}
+}
+
+func TestMergeRows(t *testing.T) {
+	rows := mergeRows(nil, []ovsdb.Row{{"_uuid": ovsdb.UUID{GoUUID: groupUUID}, "name": "pg"}})
+	rows = mergeRows(rows, []ovsdb.Row{
+		{"_uuid": ovsdb.UUID{GoUUID: groupUUID}, "ports": uuidSet(port1UUID)},
+		{"_uuid": ovsdb.UUID{GoUUID: port1UUID}, "name": "p1"},
+	})
+	if len(rows) != 2 || rows[0]["name"] != "pg" || rows[0]["ports"] == nil || rows[1]["name"] != "p1" {
+		t.Errorf("merged rows are %v", rows)
+	}
+}
+
+func TestCommitChanges(t *testing.T) {
+	schema := testSchema(t)
+	port := func(uuid, name string) ovsdb.Row {
+		return ovsdb.Row{"_uuid": ovsdb.UUID{GoUUID: uuid}, "name": name, "tag": ovsdb.OvsSet{GoSet: []interface{}{}}}
+	}
+	// Deleting the switch leaves its ports without a strong reference, and the commit
+	// collects them and drops the port group's weak reference to the first one
+	tx := &appliedTransaction{
+		ops:     []ovsdb.Operation{{Op: ovsdb.OperationDelete, Table: "Logical_Switch", Where: uuidWhere(switchUUID)}},
+		results: []ovsdb.OperationResult{{Count: 1}},
+		before: map[int][]ovsdb.Row{
+			0: {{"_uuid": ovsdb.UUID{GoUUID: switchUUID}, "name": "sw0", "ports": uuidSet(port1UUID, port2UUID)}},
+		},
+		snapshots: map[string][]ovsdb.Row{
+			"Logical_Switch_Port": {port(port1UUID, "p1"), port(port2UUID, "p2")},
+			"Port_Group":          {{"_uuid": ovsdb.UUID{GoUUID: groupUUID}, "name": "pg", "ports": uuidSet(port1UUID)}},
+		},
+	}
+	changes, order, err := trackChanges(schema, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := collectionCandidates(schema, tx, changes, order)
+	if len(candidates) != 2 {
+		t.Fatalf("candidates are %v, want both ports", candidates)
+	}
+	order = commitChanges(schema, tx, changes, order, candidates)
+
+	group := changes[groupUUID]
+	if group == nil {
+		t.Fatal("port group that lost a weak reference is not tracked")
+	}
+	ports := schema.Table("Port_Group").Column("ports")
+	if got, want := valueKey(ports, group.after["ports"]), valueKey(ports, uuidSet()); got != want {
+		t.Errorf("port group after the commit has ports %s, want %s", got, want)
+	}
+
+	ops := inverseOperations(schema, changes, order)
+	var kinds []string
+	for _, op := range ops {
+		kinds = append(kinds, op.Op+" "+op.Table)
+	}
+	want := []string{
+		"insert Logical_Switch",
+		"insert Logical_Switch_Port",
+		"insert Logical_Switch_Port",
+		"wait Port_Group",
+		"update Port_Group",
+	}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("got operations %q, want %q", kinds, want)
+	}
+	name := "row11111111_1111_4111_8111_111111111111"
+	if got, want := valueKey(ports, ops[4].Row["ports"]), valueKey(ports, uuidSet(name)); got != want {
+		t.Errorf("port group is restored with ports %s, want the reinserted port %s", got, want)
+	}
+	if ops[1].UUIDName != name || ops[1].Row["name"] != "p1" {
+		t.Errorf("collected port is reinserted as %+v", ops[1])
+	}
+
+	// A port that is still referenced elsewhere was not collected
+	changes, order, _ = trackChanges(schema, tx)
+	order = commitChanges(schema, tx, changes, order, candidates[1:])
+	if _, ok := changes[groupUUID]; ok {
+		t.Error("port group changed although its port was not collected")
+	}
+}
@@ -0,0 +1,352 @@
+package ovsdb
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// Value kinds
+const (
+	KindAtom     = "atom"
+	KindOptional = "optional" // set of at most one element
+	KindSet      = "set"
+	KindMap      = "map"
+)
+
+// TypeNamedUUID marks a uuid atom that names a row inserted in the same transaction
+const TypeNamedUUID = "named-uuid"
+
+// maxSafeInteger is the largest integer a JavaScript number holds exactly. Integer atoms
+// beyond it are given as their decimal string, which nativeAtom reads back.
+const maxSafeInteger = 1<<53 - 1
+
+// Atom is a single OVSDB value together with its atomic type
+type Atom struct {
+	Type     string      `json:"type"`               // integer, real, boolean, string, uuid or named-uuid
+	Value    interface{} `json:"value"`              // uuids and integers beyond maxSafeInteger as strings
+	RefTable string      `json:"refTable,omitempty"` // table a uuid refers to, if the schema says
+}
+
+// Pair is one key/value pair of a map value
+type Pair struct {
+	Key   Atom `json:"key"`
+	Value Atom `json:"value"`
+}
+
+// Value is a column value that keeps its shape and atomic types, so it converts back to
+// exactly what the server sent. Only the field of its kind is set; an optional without
+// Atom is absent and a set or map without elements is empty.
+type Value struct {
+	Kind string `json:"kind"`
+	Atom *Atom  `json:"atom,omitempty"`
+	Set  []Atom `json:"set,omitempty"`
+	Map  []Pair `json:"map,omitempty"`
+}
+
+// TypedRow is a row whose values keep their OVSDB types, keyed by column
+type TypedRow map[string]Value
+
+// Plain drops the types of a value: an optional becomes its atom or nil, a set a list
+// and a map a mapping
+func (v Value) Plain() interface{} {
+	switch v.Kind {
+	case KindSet:
+		list := make([]interface{}, 0, len(v.Set))
+		for _, atom := range v.Set {
+			list = append(list, atom.Value)
+		}
+		return list
+	case KindMap:
+		mapping := make(map[string]interface{}, len(v.Map))
+		for _, pair := range v.Map {
+			mapping[fmt.Sprint(pair.Key.Value)] = pair.Value.Value
+		}
+		return mapping
+	default:
+		if v.Atom == nil {
+			return nil
+		}
+		return v.Atom.Value
+	}
+}
+
+// typedRow converts a row as received from the server, using the table schema for types
+func typedRow(table *ovsdb.TableSchema, row ovsdb.Row) TypedRow {
+	typed := make(TypedRow, len(row))
+	for column, v := range row {
+		typed[column] = typedValue(columnSchema(table, column), v)
+	}
+	return typed
+}
+
+// typedRows converts rows as received from the server
+func typedRows(table *ovsdb.TableSchema, rows []ovsdb.Row) []TypedRow {
+	typed := make([]TypedRow, 0, len(rows))
+	for _, row := range rows {
+		typed = append(typed, typedRow(table, row))
+	}
+	return typed
+}
+
+// columnSchema returns the schema of a column, including the _uuid and _version columns
+func columnSchema(table *ovsdb.TableSchema, column string) *ovsdb.ColumnSchema {
+	if column == "_version" {
+		return &ovsdb.UUIDColumn
+	}
+	return table.Column(column)
+}
+
+// typedValue converts a column value; without a column schema the type is taken from the value
+func typedValue(column *ovsdb.ColumnSchema, v interface{}) Value {
+	if column == nil {
+		return untypedValue(v)
+	}
+	switch column.Type {
+	case ovsdb.TypeMap:
+		value := Value{Kind: KindMap}
+		if m, ok := v.(ovsdb.OvsMap); ok {
+			for k, val := range m.GoMap {
+				value.Map = append(value.Map, Pair{
+					Key:   typedAtom(column.TypeObj.Key, k),
+					Value: typedAtom(column.TypeObj.Value, val),
+				})
+			}
+		}
+		sort.Slice(value.Map, func(i, j int) bool {
+			return compareValues(value.Map[i].Key.Value, value.Map[j].Key.Value) < 0
+		})
+		return value
+	case ovsdb.TypeSet:
+		elems := setElements(v)
+		if column.TypeObj.Min() == 0 && column.TypeObj.Max() == 1 {
+			value := Value{Kind: KindOptional}
+			if len(elems) > 0 {
+				atom := typedAtom(column.TypeObj.Key, elems[0])
+				value.Atom = &atom
+			}
+			return value
+		}
+		value := Value{Kind: KindSet}
+		for _, elem := range elems {
+			value.Set = append(value.Set, typedAtom(column.TypeObj.Key, elem))
+		}
+		sort.Slice(value.Set, func(i, j int) bool {
+			return compareValues(value.Set[i].Value, value.Set[j].Value) < 0
+		})
+		return value
+	default:
+		atom := typedAtom(baseType(column), v)
+		return Value{Kind: KindAtom, Atom: &atom}
+	}
+}
+
+// typedAtom converts an atomic value. Integers keep every digit, as a string beyond
+// maxSafeInteger; numbers of real columns become float64.
+func typedAtom(base *ovsdb.BaseType, v interface{}) Atom {
+	atom := Atom{Type: base.Type, Value: v}
+	switch a := v.(type) {
+	case ovsdb.UUID:
+		atom.Type = ovsdb.TypeUUID
+		if ovsdb.IsNamedUUID(a.GoUUID) {
+			atom.Type = TypeNamedUUID
+		}
+		atom.Value = a.GoUUID
+	case int:
+		switch {
+		case base.Type == ovsdb.TypeReal:
+			atom.Value = float64(a)
+		case a > maxSafeInteger || a < -maxSafeInteger:
+			atom.Value = strconv.Itoa(a)
+		}
+	case float64:
+		if base.Type == ovsdb.TypeInteger && a == math.Trunc(a) && math.Abs(a) <= maxSafeInteger {
+			atom.Value = int(a)
+		}
+	}
+	if refTable, err := base.RefTable(); err == nil {
+		atom.RefTable = refTable
+	}
+	return atom
+}
+
+// untypedValue converts a value of a column missing from the schema
+func untypedValue(v interface{}) Value {
+	switch val := v.(type) {
+	case ovsdb.OvsSet:
+		value := Value{Kind: KindSet}
+		for _, elem := range val.GoSet {
+			value.Set = append(value.Set, untypedAtom(elem))
+		}
+		return value
+	case ovsdb.OvsMap:
+		value := Value{Kind: KindMap}
+		for k, v := range val.GoMap {
+			value.Map = append(value.Map, Pair{Key: untypedAtom(k), Value: untypedAtom(v)})
+		}
+		return value
+	default:
+		atom := untypedAtom(v)
+		return Value{Kind: KindAtom, Atom: &atom}
+	}
+}
+
+func untypedAtom(v interface{}) Atom {
+	switch a := v.(type) {
+	case bool:
+		return Atom{Type: ovsdb.TypeBoolean, Value: a}
+	case float64:
+		return Atom{Type: ovsdb.TypeReal, Value: a}
+	case int:
+		return Atom{Type: ovsdb.TypeInteger, Value: a}
+	default:
+		return typedAtom(&ovsdb.BaseType{Type: ovsdb.TypeString}, v)
+	}
+}
+
+// nativeValue converts a typed value back into the form libovsdb sends to the server,
+// checking it against the column type
+func nativeValue(column *ovsdb.ColumnSchema, v Value) (interface{}, error) {
+	switch column.Type {
+	case ovsdb.TypeMap:
+		goMap, err := nativeMap(column.TypeObj, v)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSize(column.TypeObj, len(goMap.GoMap)); err != nil {
+			return nil, err
+		}
+		return goMap, nil
+	case ovsdb.TypeSet:
+		set, err := nativeSet(column.TypeObj.Key, v)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSize(column.TypeObj, len(set.GoSet)); err != nil {
+			return nil, err
+		}
+		return set, nil
+	default:
+		if v.Kind != KindAtom || v.Atom == nil {
+			return nil, fmt.Errorf("expected a single value, got %s", v.Kind)
+		}
+		return nativeAtom(baseType(column), *v.Atom)
+	}
+}
+
+// nativeMap converts a typed map, whatever its size
+func nativeMap(columnType *ovsdb.ColumnType, v Value) (ovsdb.OvsMap, error) {
+	if v.Kind != KindMap {
+		return ovsdb.OvsMap{}, fmt.Errorf("expected a map, got %s", v.Kind)
+	}
+	goMap := make(map[interface{}]interface{}, len(v.Map))
+	for _, pair := range v.Map {
+		key, err := nativeAtom(columnType.Key, pair.Key)
+		if err != nil {
+			return ovsdb.OvsMap{}, fmt.Errorf("key %v: %w", pair.Key.Value, err)
+		}
+		value, err := nativeAtom(columnType.Value, pair.Value)
+		if err != nil {
+			return ovsdb.OvsMap{}, fmt.Errorf("value of %v: %w", pair.Key.Value, err)
+		}
+		goMap[key] = value
+	}
+	return ovsdb.OvsMap{GoMap: goMap}, nil
+}
+
+// nativeSet converts a typed set, whatever its size; an optional or single value counts as
+// a set of at most one element
+func nativeSet(base *ovsdb.BaseType, v Value) (ovsdb.OvsSet, error) {
+	atoms := v.Set
+	switch v.Kind {
+	case KindSet:
+	case KindOptional, KindAtom:
+		atoms = nil
+		if v.Atom != nil {
+			atoms = []Atom{*v.Atom}
+		}
+	default:
+		return ovsdb.OvsSet{}, fmt.Errorf("expected a set, got %s", v.Kind)
+	}
+	set := make([]interface{}, 0, len(atoms))
+	for _, atom := range atoms {
+		elem, err := nativeAtom(base, atom)
+		if err != nil {
+			return ovsdb.OvsSet{}, err
+		}
+		set = append(set, elem)
+	}
+	return ovsdb.OvsSet{GoSet: set}, nil
+}
+
+// checkSize checks the number of elements of a set or map against the column's min and max
+func checkSize(columnType *ovsdb.ColumnType, n int) error {
+	if min := columnType.Min(); n < min {
+		return fmt.Errorf("%d elements are below the minimum of %d", n, min)
+	}
+	if max := columnType.Max(); max != ovsdb.Unlimited && n > max {
+		return fmt.Errorf("%d elements exceed the maximum of %d", n, max)
+	}
+	return nil
+}
+
+// nativeAtom converts a typed atom, which must be of the column's atomic type; a named
+// uuid stands for a uuid
+func nativeAtom(base *ovsdb.BaseType, atom Atom) (interface{}, error) {
+	if atom.Type == TypeNamedUUID && base.Type == ovsdb.TypeUUID {
+		name, ok := atom.Value.(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid named uuid %v", atom.Value)
+		}
+		return ovsdb.UUID{GoUUID: name}, nil
+	}
+	if atom.Type != base.Type {
+		return nil, fmt.Errorf("expected %s, got %s", base.Type, atom.Type)
+	}
+	v, err := encodeAtom(base, atom.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRange(base, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// checkRange checks an atom against the limits of its type: the range of an integer or a
+// real, and the length of a string in characters. Limits a schema leaves out read as the
+// defaults libovsdb fills in, except the smallest positive real it gives as the minimum.
+func checkRange(base *ovsdb.BaseType, v interface{}) error {
+	switch a := v.(type) {
+	case int:
+		if min, _ := base.MinInteger(); a < min {
+			return fmt.Errorf("%d is below the minimum of %d", a, min)
+		}
+		if max, _ := base.MaxInteger(); a > max {
+			return fmt.Errorf("%d is above the maximum of %d", a, max)
+		}
+	case float64:
+		if min, _ := base.MinReal(); min != math.SmallestNonzeroFloat64 && a < min {
+			return fmt.Errorf("%v is below the minimum of %v", a, min)
+		}
+		if max, _ := base.MaxReal(); a > max {
+			return fmt.Errorf("%v is above the maximum of %v", a, max)
+		}
+	case string:
+		if base.Type != ovsdb.TypeString {
+			return nil
+		}
+		n := utf8.RuneCountInString(a)
+		if min, _ := base.MinLength(); n < min {
+			return fmt.Errorf("%q is shorter than %d characters", a, min)
+		}
+		if max, _ := base.MaxLength(); n > max {
+			return fmt.Errorf("%q is longer than %d characters", a, max)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package ovsdb
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// valuesTable is a table schema with a column of each kind of value
+func valuesTable(t *testing.T) *ovsdb.TableSchema {
+	t.Helper()
+	var table ovsdb.TableSchema
+	err := json.Unmarshal([]byte(`{"columns": {
+		"name": {"type": "string"},
+		"tag": {"type": "integer"},
+		"weight": {"type": "real"},
+		"up": {"type": "boolean"},
+		"peer": {"type": {"key": "string", "min": 0, "max": 1}},
+		"ports": {"type": {"key": {"type": "uuid", "refTable": "Port"}, "min": 1, "max": "unlimited"}},
+		"trunks": {"type": {"key": "integer", "min": 0, "max": 4}},
+		"stats": {"type": {"key": "string", "value": "integer", "min": 0, "max": "unlimited"}}
+	}}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &table
+}
+
+func TestTypedRow(t *testing.T) {
+	table := valuesTable(t)
+	const port1, port2 = "6a1c9f0e-3b2d-4c5e-8f7a-9b0c1d2e3f40", "1f2e3d4c-5b6a-4978-8a9b-0c1d2e3f4a5b"
+	row := typedRow(table, ovsdb.Row{
+		"_uuid":    ovsdb.UUID{GoUUID: port1},
+		"_version": ovsdb.UUID{GoUUID: port2},
+		"name":     "br0",
+		"tag":      1 << 60,
+		"weight":   2,
+		"up":       true,
+		"peer":     ovsdb.OvsSet{GoSet: []interface{}{}},
+		"ports":    ovsdb.OvsSet{GoSet: []interface{}{ovsdb.UUID{GoUUID: port1}, ovsdb.UUID{GoUUID: port2}}},
+		"trunks":   float64(10),
+		"stats":    ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"tx": 5, "rx": float64(maxSafeInteger) * 2}},
+		"other":    ovsdb.OvsSet{GoSet: []interface{}{"x", 1.5}},
+	})
+
+	want := TypedRow{
+		"_uuid":    {Kind: KindAtom, Atom: &Atom{Type: "uuid", Value: port1}},
+		"_version": {Kind: KindAtom, Atom: &Atom{Type: "uuid", Value: port2}},
+		"name":     {Kind: KindAtom, Atom: &Atom{Type: "string", Value: "br0"}},
+		"tag":      {Kind: KindAtom, Atom: &Atom{Type: "integer", Value: "1152921504606846976"}},
+		"weight":   {Kind: KindAtom, Atom: &Atom{Type: "real", Value: 2.0}},
+		"up":       {Kind: KindAtom, Atom: &Atom{Type: "boolean", Value: true}},
+		"peer":     {Kind: KindOptional},
+		"ports": {Kind: KindSet, Set: []Atom{
+			{Type: "uuid", Value: port2, RefTable: "Port"},
+			{Type: "uuid", Value: port1, RefTable: "Port"},
+		}},
+		"trunks": {Kind: KindSet, Set: []Atom{{Type: "integer", Value: 10}}},
+		"stats": {Kind: KindMap, Map: []Pair{
+			{Key: Atom{Type: "string", Value: "rx"}, Value: Atom{Type: "integer", Value: float64(maxSafeInteger) * 2}},
+			{Key: Atom{Type: "string", Value: "tx"}, Value: Atom{Type: "integer", Value: 5}},
+		}},
+		"other": {Kind: KindSet, Set: []Atom{{Type: "string", Value: "x"}, {Type: "real", Value: 1.5}}},
+	}
+	for column, value := range want {
+		if !reflect.DeepEqual(row[column], value) {
+			t.Errorf("%s = %s, want %s", column, dump(row[column]), dump(value))
+		}
+	}
+}
+
+func dump(v Value) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestNativeValue(t *testing.T) {
+	table := valuesTable(t)
+	const port = "6a1c9f0e-3b2d-4c5e-8f7a-9b0c1d2e3f40"
+	rows := []ovsdb.Row{{
+		"name":   "br0",
+		"tag":    1<<60 + 1,
+		"weight": 0.5,
+		"up":     false,
+		"peer":   ovsdb.OvsSet{GoSet: []interface{}{"patch-br1"}},
+		"ports":  ovsdb.OvsSet{GoSet: []interface{}{ovsdb.UUID{GoUUID: port}}},
+		"trunks": ovsdb.OvsSet{GoSet: []interface{}{1, 2, 3}},
+		"stats":  ovsdb.OvsMap{GoMap: map[interface{}]interface{}{"tx": 5}},
+	}, {
+		"tag":  -maxSafeInteger,
+		"peer": ovsdb.OvsSet{GoSet: []interface{}{}},
+	}}
+
+	// Values sent to the frontend and back, as JSON, convert to what the server sent
+	for _, row := range rows {
+		data, err := json.Marshal(typedRow(table, row))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var typed TypedRow
+		if err := json.Unmarshal(data, &typed); err != nil {
+			t.Fatal(err)
+		}
+		for column, want := range row {
+			got, err := nativeValue(table.Column(column), typed[column])
+			if err != nil {
+				t.Errorf("%s: %v", column, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s = %#v, want %#v", column, got, want)
+			}
+		}
+	}
+
+	named := Atom{Type: TypeNamedUUID, Value: "new_port"}
+	if got, err := nativeValue(table.Column("ports"), Value{Kind: KindSet, Set: []Atom{named}}); err != nil ||
+		!reflect.DeepEqual(got, ovsdb.OvsSet{GoSet: []interface{}{ovsdb.UUID{GoUUID: "new_port"}}}) {
+		t.Errorf("named uuid = %#v, %v", got, err)
+	}
+
+	integer := func(n interface{}) Atom { return Atom{Type: "integer", Value: n} }
+	tests := []struct {
+		column string
+		value  Value
+		err    string
+	}{
+		{"name", Value{Kind: KindSet}, "expected a single value, got set"},
+		{"name", Value{Kind: KindAtom, Atom: &Atom{Type: "integer", Value: 1}}, "expected string, got integer"},
+		{"tag", Value{Kind: KindAtom, Atom: &Atom{Type: "integer", Value: "many"}}, `"many" is not an integer`},
+		{"ports", Value{Kind: KindSet}, "0 elements are below the minimum of 1"},
+		{"ports", Value{Kind: KindSet, Set: []Atom{{Type: "uuid", Value: "port1"}}}, `"port1" is not a uuid`},
+		{"trunks", Value{Kind: KindSet, Set: []Atom{integer(1), integer(2), integer(3), integer(4), integer(5)}}, "5 elements exceed the maximum of 4"},
+		{"stats", Value{Kind: KindSet}, "expected a map, got set"},
+		{"stats", Value{Kind: KindMap, Map: []Pair{{Key: Atom{Type: "string", Value: "tx"}, Value: Atom{Type: "string", Value: "5"}}}}, "value of tx: expected integer, got string"},
+	}
+	for _, tt := range tests {
+		_, err := nativeValue(table.Column(tt.column), tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %s: error %v, want %q", tt.column, dump(tt.value), err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,646 @@
+package ovsdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// Edit is one change to the rows of a table. Values use the typed format of TypedRow, and
+// rows inserted by the same transaction are referred to with named-uuid atoms.
+type Edit struct {
+	Op        string           `json:"op"` // insert, update, mutate or delete
+	Table     string           `json:"table"`
+	UUID      string           `json:"uuid,omitempty"`     // row to change
+	Where     []Condition      `json:"where,omitempty"`    // rows to change, with or instead of UUID
+	UUIDName  string           `json:"uuidName,omitempty"` // name of an inserted row
+	Row       map[string]Value `json:"row,omitempty"`      // columns to insert or update
+	Mutations []Mutation       `json:"mutations,omitempty"`
+	// Expect holds column values the row identified by UUID must still have; the edit is
+	// guarded by a wait operation and reported as a Conflict when the row has changed
+	Expect map[string]Value `json:"expect,omitempty"`
+}
+
+// Mutation is one change made by a mutate edit
+type Mutation struct {
+	Column  string `json:"column"`
+	Mutator string `json:"mutator"` // +=, -=, *=, /=, %=, insert or delete
+	Value   Value  `json:"value"`
+}
+
+// EditResult reports the outcome of one operation of a transaction
+type EditResult struct {
+	Op      string `json:"op"`
+	Table   string `json:"table"`
+	UUID    string `json:"uuid,omitempty"` // uuid of an inserted row
+	Count   int    `json:"count"`          // rows updated, mutated or deleted
+	Error   string `json:"error,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// TransactionResult reports the outcome of a transaction, which changes nothing unless it
+// is committed. Results has one entry per operation, including the waits guarding edits.
+type TransactionResult struct {
+	Committed bool         `json:"committed"`
+	Results   []EditResult `json:"results"`
+	// Error is a failure of the transaction as a whole, such as a constraint violated at commit
+	Error   string `json:"error,omitempty"`
+	Details string `json:"details,omitempty"`
+	// Conflict is set when a guarded edit failed because its row has changed
+	Conflict *Conflict `json:"conflict,omitempty"`
+	// UndoID identifies the entry of the undo history that reverts a committed transaction;
+	// 0 when there is nothing to undo or the inverse could not be worked out, see UndoError
+	UndoID    int    `json:"undoId,omitempty"`
+	UndoError string `json:"undoError,omitempty"`
+}
+
+// Conflict describes a row that changed since it was read, for a three-way comparison of
+// the values the edit was based on, the values on the server and the values of the edit
+type Conflict struct {
+	Table    string   `json:"table"`
+	UUID     string   `json:"uuid"`
+	Expected TypedRow `json:"expected"`
+	Current  TypedRow `json:"current,omitempty"` // nil when the row was deleted
+	Proposed TypedRow `json:"proposed,omitempty"`
+	Deleted  bool     `json:"deleted"`
+}
+
+// txStep tells what an operation of a built transaction is for
+type txStep struct {
+	edit     int  // index of the edit behind the operation
+	capture  bool // a select of the rows the next operation changes, kept for undo
+	snapshot bool // a select of rows the commit may change on its own, kept for undo
+}
+
+// appliedTransaction is a transaction that was run, with the capture selects taken out
+type appliedTransaction struct {
+	ops     []ovsdb.Operation
+	origins []int // index of the edit behind each operation
+	results []ovsdb.OperationResult
+	before  map[int][]ovsdb.Row // rows each operation changed, as they were just before it
+	// snapshots holds, by table, the rows the commit may garbage collect or drop weak
+	// references from, as they were before the transaction; a row read by several selects
+	// holds the columns of all of them
+	snapshots map[string][]ovsdb.Row
+}
+
+// reference is a uuid written to a column, and the table it must be a row of
+type reference struct {
+	table string // empty when the column does not name a table
+	uuid  string
+}
+
+// BuildTransaction turns edits into operations, checking them against the schema and every
+// referenced row against the referenced table. These are the operations of the edits and the
+// waits guarding them; the selects ApplyTransaction adds to capture rows for undo are left out.
+func (c *OVSDBClient) BuildTransaction(ctx context.Context, dbName string, edits []Edit) ([]ovsdb.Operation, error) {
+	ops, steps, err := c.buildTransaction(ctx, dbName, edits)
+	if err != nil {
+		return nil, err
+	}
+	return splitCaptures(ops, steps, nil).ops, nil
+}
+
+// buildTransaction is BuildTransaction, also telling what each operation is for. Every
+// update, mutate and delete is preceded by a select of the rows it changes, from which the
+// undo of the transaction is worked out.
+func (c *OVSDBClient) buildTransaction(ctx context.Context, dbName string, edits []Edit) ([]ovsdb.Operation, []txStep, error) {
+	if len(edits) == 0 {
+		return nil, nil, fmt.Errorf("no edits given")
+	}
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ops := make([]ovsdb.Operation, 0, len(edits))
+	steps := make([]txStep, 0, len(edits))
+	names := make(map[string]bool)
+	var refs []reference
+	for i, edit := range edits {
+		table := schema.Table(edit.Table)
+		if table == nil {
+			return nil, nil, fmt.Errorf("edit %d: table %s not found in %s", i+1, edit.Table, schema.Name)
+		}
+		op, err := buildOperation(table, edit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("edit %d (%s on %s): %w", i+1, edit.Op, edit.Table, err)
+		}
+		if len(edit.Expect) > 0 {
+			wait, err := guardOperation(table, edit)
+			if err != nil {
+				return nil, nil, fmt.Errorf("edit %d (%s on %s): %w", i+1, edit.Op, edit.Table, err)
+			}
+			ops = append(ops, wait)
+			steps = append(steps, txStep{edit: i})
+		}
+		if op.UUIDName != "" {
+			if names[op.UUIDName] {
+				return nil, nil, fmt.Errorf("edit %d: uuid name %s is used twice", i+1, op.UUIDName)
+			}
+			names[op.UUIDName] = true
+		}
+		for column, value := range op.Row {
+			refs = append(refs, references(table.Columns[column], value)...)
+		}
+		for _, mutation := range op.Mutations {
+			if mutation.Mutator == ovsdb.MutateOperationInsert {
+				refs = append(refs, references(table.Columns[mutation.Column], mutation.Value)...)
+			}
+		}
+		if op.Op != ovsdb.OperationInsert {
+			ops = append(ops, ovsdb.Operation{Op: ovsdb.OperationSelect, Table: op.Table, Where: op.Where})
+			steps = append(steps, txStep{edit: i, capture: true})
+		}
+		ops = append(ops, op)
+		steps = append(steps, txStep{edit: i})
+	}
+
+	if err := c.checkReferences(ctx, dbName, refs, names); err != nil {
+		return nil, nil, err
+	}
+	return ops, steps, nil
+}
+
+// PreviewTransaction returns the JSON-RPC request of the edits. ApplyTransaction sends it with
+// the selects capturing rows for the undo added, which only read.
+func (c *OVSDBClient) PreviewTransaction(ctx context.Context, dbName string, edits []Edit) (string, error) {
+	ops, err := c.BuildTransaction(ctx, dbName, edits)
+	if err != nil {
+		return "", err
+	}
+	if c.isPrimary(dbName) {
+		dbName = c.dbName
+	}
+	request := struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+		ID     int           `json:"id"`
+	}{Method: "transact", Params: ovsdb.NewTransactArgs(dbName, ops...)}
+	data, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transaction: %w", err)
+	}
+	return string(data), nil
+}
+
+// ApplyTransaction runs the edits as one atomic transaction and reports the outcome of each.
+// A committed transaction is added to the undo history; the transaction opens with selects
+// of the rows its commit may collect or drop weak references from, which the undo restores.
+func (c *OVSDBClient) ApplyTransaction(ctx context.Context, dbName string, edits []Edit) (*TransactionResult, error) {
+	ops, steps, err := c.buildTransaction(ctx, dbName, edits)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := c.schema(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := c.commitCaptures(ctx, dbName, schema, ops)
+	if err != nil {
+		return nil, err
+	}
+	snapshotSteps := make([]txStep, len(snapshots))
+	for i := range snapshotSteps {
+		snapshotSteps[i] = txStep{edit: -1, capture: true, snapshot: true}
+	}
+	ops, steps = append(snapshots, ops...), append(snapshotSteps, steps...)
+	results, err := c.transactSteps(ctx, dbName, ops, steps)
+	if err != nil {
+		return nil, fmt.Errorf("transaction failed: %w", err)
+	}
+	tx := splitCaptures(ops, steps, results)
+	outcome := transactionResult(tx.ops, tx.results)
+	for i, op := range tx.ops {
+		// A wait with no timeout fails with "timed out" as soon as the row differs
+		if op.Op == ovsdb.OperationWait && i < len(tx.results) && tx.results[i].Error == "timed out" {
+			if outcome.Conflict, err = c.conflict(ctx, dbName, edits[tx.origins[i]]); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if outcome.Committed {
+		if outcome.UndoID, err = c.recordUndo(ctx, dbName, edits, tx); err != nil {
+			outcome.UndoError = err.Error()
+		}
+	}
+	return outcome, nil
+}
+
+// splitCaptures takes the capture selects out of a transaction that was run, keeping the
+// rows they returned. Results past the operations, such as a failed commit, are kept.
+func splitCaptures(ops []ovsdb.Operation, steps []txStep, results []ovsdb.OperationResult) *appliedTransaction {
+	tx := &appliedTransaction{before: make(map[int][]ovsdb.Row), snapshots: make(map[string][]ovsdb.Row)}
+	var captured []ovsdb.Row
+	for i, op := range ops {
+		if steps[i].snapshot {
+			if i < len(results) {
+				tx.snapshots[op.Table] = mergeRows(tx.snapshots[op.Table], results[i].Rows)
+			}
+			continue
+		}
+		if steps[i].capture {
+			if i < len(results) {
+				captured = results[i].Rows
+			}
+			continue
+		}
+		if captured != nil {
+			tx.before[len(tx.ops)] = captured
+			captured = nil
+		}
+		tx.ops = append(tx.ops, op)
+		tx.origins = append(tx.origins, steps[i].edit)
+		if i < len(results) {
+			tx.results = append(tx.results, results[i])
+		}
+	}
+	if len(results) > len(ops) {
+		tx.results = append(tx.results, results[len(ops):]...)
+	}
+	return tx
+}
+
+// mergeRows adds rows to those read before, merging the columns of rows with the same uuid
+func mergeRows(rows []ovsdb.Row, more []ovsdb.Row) []ovsdb.Row {
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if uuid, ok := row["_uuid"].(ovsdb.UUID); ok {
+			index[uuid.GoUUID] = i
+		}
+	}
+	for _, row := range more {
+		uuid, ok := row["_uuid"].(ovsdb.UUID)
+		i, seen := index[uuid.GoUUID]
+		if !ok || !seen {
+			index[uuid.GoUUID] = len(rows)
+			rows = append(rows, row)
+			continue
+		}
+		merged := make(ovsdb.Row, len(rows[i])+len(row))
+		for _, r := range []ovsdb.Row{rows[i], row} {
+			for column, value := range r {
+				merged[column] = value
+			}
+		}
+		rows[i] = merged
+	}
+	return rows
+}
+
+// GuardedUpdate updates columns of a row only if the row still has the original values it
+// was shown with; otherwise nothing is written and the result holds the Conflict
+func (c *OVSDBClient) GuardedUpdate(ctx context.Context, dbName string, table string, uuid string, original map[string]Value, changes map[string]Value) (*TransactionResult, error) {
+	if len(original) == 0 {
+		return nil, fmt.Errorf("no original values to guard the update with")
+	}
+	return c.ApplyTransaction(ctx, dbName, []Edit{{
+		Op:     ovsdb.OperationUpdate,
+		Table:  table,
+		UUID:   uuid,
+		Row:    changes,
+		Expect: original,
+	}})
+}
+
+// conflict reads the current state of the row behind a failed guard
+func (c *OVSDBClient) conflict(ctx context.Context, dbName string, edit Edit) (*Conflict, error) {
+	table, err := c.tableSchema(ctx, dbName, edit.Table)
+	if err != nil {
+		return nil, err
+	}
+	conflict := &Conflict{
+		Table:    edit.Table,
+		UUID:     edit.UUID,
+		Expected: TypedRow(edit.Expect),
+		Proposed: TypedRow(edit.Row),
+	}
+	rows, err := c.selectRaw(ctx, dbName, ovsdb.Operation{
+		Op:    ovsdb.OperationSelect,
+		Table: edit.Table,
+		Where: []ovsdb.Condition{ovsdb.NewCondition("_uuid", ovsdb.ConditionEqual, ovsdb.UUID{GoUUID: edit.UUID})},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("row %s has changed, and reading it again failed: %w", edit.UUID, err)
+	}
+	if len(rows) == 0 {
+		conflict.Deleted = true
+		return conflict, nil
+	}
+	conflict.Current = typedRow(table, rows[0])
+	return conflict, nil
+}
+
+// transactionResult pairs the operations with their results. The server answers an aborted
+// transaction with the error of the failing operation, and a failed commit with one result
+// more than there were operations.
+func transactionResult(ops []ovsdb.Operation, results []ovsdb.OperationResult) *TransactionResult {
+	outcome := &TransactionResult{Committed: true, Results: make([]EditResult, 0, len(ops))}
+	for i, op := range ops {
+		result := EditResult{Op: op.Op, Table: op.Table}
+		if i < len(results) {
+			result.Count = results[i].Count
+			result.UUID = results[i].UUID.GoUUID
+			result.Error = results[i].Error
+			result.Details = results[i].Details
+		}
+		if result.Error != "" {
+			outcome.Committed = false
+		}
+		outcome.Results = append(outcome.Results, result)
+	}
+	if len(results) > len(ops) && results[len(ops)].Error != "" {
+		outcome.Committed = false
+		outcome.Error = results[len(ops)].Error
+		outcome.Details = results[len(ops)].Details
+	}
+	if len(results) < len(ops) {
+		outcome.Committed = false
+		outcome.Error = fmt.Sprintf("expected %d results, got %d", len(ops), len(results))
+	}
+	if !outcome.Committed {
+		// Nothing was written, so no insert has a uuid
+		for i := range outcome.Results {
+			outcome.Results[i].UUID = ""
+		}
+	}
+	return outcome
+}
+
+// buildOperation converts one edit, checking its values against the table schema
+func buildOperation(table *ovsdb.TableSchema, edit Edit) (ovsdb.Operation, error) {
+	op := ovsdb.Operation{Op: edit.Op, Table: edit.Table}
+	if edit.UUIDName != "" && edit.Op != ovsdb.OperationInsert {
+		return op, fmt.Errorf("only an insert can name its row")
+	}
+	if len(edit.Row) > 0 && edit.Op != ovsdb.OperationInsert && edit.Op != ovsdb.OperationUpdate {
+		return op, fmt.Errorf("%s takes no row", edit.Op)
+	}
+	if len(edit.Mutations) > 0 && edit.Op != ovsdb.OperationMutate {
+		return op, fmt.Errorf("%s takes no mutations", edit.Op)
+	}
+
+	var err error
+	switch edit.Op {
+	case ovsdb.OperationInsert:
+		if edit.UUID != "" || len(edit.Where) > 0 {
+			return op, fmt.Errorf("insert takes no uuid or where clause")
+		}
+		if edit.UUIDName != "" && !validUUIDName(edit.UUIDName) {
+			return op, fmt.Errorf("invalid uuid name %q", edit.UUIDName)
+		}
+		op.UUIDName = edit.UUIDName
+		op.Row, err = nativeRow(table, edit.Row, false)
+	case ovsdb.OperationUpdate:
+		if len(edit.Row) == 0 {
+			return op, fmt.Errorf("no columns to update")
+		}
+		if op.Where, err = editWhere(table, edit); err != nil {
+			return op, err
+		}
+		op.Row, err = nativeRow(table, edit.Row, true)
+	case ovsdb.OperationMutate:
+		if len(edit.Mutations) == 0 {
+			return op, fmt.Errorf("no mutations")
+		}
+		if op.Where, err = editWhere(table, edit); err != nil {
+			return op, err
+		}
+		for _, m := range edit.Mutations {
+			mutation, err := nativeMutation(table, m)
+			if err != nil {
+				return op, err
+			}
+			op.Mutations = append(op.Mutations, mutation)
+		}
+	case ovsdb.OperationDelete:
+		op.Where, err = editWhere(table, edit)
+	default:
+		return op, fmt.Errorf("unsupported operation %q", edit.Op)
+	}
+	return op, err
+}
+
+// guardOperation builds the wait that makes an edit fail unless its row still holds the
+// expected values. The timeout of zero makes the server fail it at once instead of waiting.
+func guardOperation(table *ovsdb.TableSchema, edit Edit) (ovsdb.Operation, error) {
+	if edit.Op == ovsdb.OperationInsert {
+		return ovsdb.Operation{}, fmt.Errorf("an insert has no values to expect")
+	}
+	if edit.UUID == "" {
+		return ovsdb.Operation{}, fmt.Errorf("expected values need the uuid of the row")
+	}
+	expected := make(ovsdb.Row, len(edit.Expect))
+	columns := make([]string, 0, len(edit.Expect))
+	for name, value := range edit.Expect {
+		column, ok := table.Columns[name]
+		if !ok {
+			return ovsdb.Operation{}, fmt.Errorf("expected column %s not found", name)
+		}
+		v, err := nativeValue(column, value)
+		if err != nil {
+			return ovsdb.Operation{}, fmt.Errorf("expected column %s: %w", name, err)
+		}
+		expected[name] = v
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	where, err := whereClause(table, []Condition{{Column: "_uuid", Function: string(ovsdb.ConditionEqual), Value: edit.UUID}})
+	if err != nil {
+		return ovsdb.Operation{}, err
+	}
+	timeout := 0
+	return ovsdb.Operation{
+		Op:      ovsdb.OperationWait,
+		Table:   edit.Table,
+		Timeout: &timeout,
+		Where:   where,
+		Columns: columns,
+		Until:   string(ovsdb.WaitConditionEqual),
+		Rows:    []ovsdb.Row{expected},
+	}, nil
+}
+
+// editWhere returns the conditions selecting the rows an edit changes. Every edit has to
+// name its rows, so that a forgotten condition does not change the whole table.
+func editWhere(table *ovsdb.TableSchema, edit Edit) ([]ovsdb.Condition, error) {
+	if edit.UUID == "" && len(edit.Where) == 0 {
+		return nil, fmt.Errorf("a uuid or a where clause is required")
+	}
+	conditions := edit.Where
+	if edit.UUID != "" {
+		conditions = append([]Condition{{Column: "_uuid", Function: string(ovsdb.ConditionEqual), Value: edit.UUID}}, conditions...)
+	}
+	return whereClause(table, conditions)
+}
+
+// nativeRow converts the columns of an insert or update
+func nativeRow(table *ovsdb.TableSchema, row map[string]Value, update bool) (ovsdb.Row, error) {
+	native := make(ovsdb.Row, len(row))
+	for name, value := range row {
+		column, err := writableColumn(table, name, update)
+		if err != nil {
+			return nil, err
+		}
+		v, err := nativeValue(column, value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		native[name] = v
+	}
+	return native, nil
+}
+
+// writableColumn returns the schema of a column an edit may write
+func writableColumn(table *ovsdb.TableSchema, name string, update bool) (*ovsdb.ColumnSchema, error) {
+	if strings.HasPrefix(name, "_") {
+		return nil, fmt.Errorf("column %s cannot be written", name)
+	}
+	column, ok := table.Columns[name]
+	if !ok {
+		return nil, fmt.Errorf("column %s not found", name)
+	}
+	if update && !column.Mutable() {
+		return nil, fmt.Errorf("column %s is immutable", name)
+	}
+	return column, nil
+}
+
+// nativeMutation converts one mutation. Arithmetic applies to integer and real columns,
+// including sets of them; insert and delete to sets and maps, and a map's keys can be
+// deleted with a set.
+func nativeMutation(table *ovsdb.TableSchema, m Mutation) (ovsdb.Mutation, error) {
+	column, err := writableColumn(table, m.Column, true)
+	if err != nil {
+		return ovsdb.Mutation{}, err
+	}
+	mutator := ovsdb.Mutator(m.Mutator)
+	invalid := fmt.Errorf("mutator %s is not valid for column %s of type %s", m.Mutator, m.Column, typeName(column))
+	base := baseType(column)
+
+	var value interface{}
+	switch mutator {
+	case ovsdb.MutateOperationAdd, ovsdb.MutateOperationSubtract, ovsdb.MutateOperationMultiply,
+		ovsdb.MutateOperationDivide, ovsdb.MutateOperationModulo:
+		if column.Type == ovsdb.TypeMap || (base.Type != ovsdb.TypeInteger && base.Type != ovsdb.TypeReal) ||
+			(mutator == ovsdb.MutateOperationModulo && base.Type != ovsdb.TypeInteger) {
+			return ovsdb.Mutation{}, invalid
+		}
+		if m.Value.Atom == nil || (m.Value.Kind != KindAtom && m.Value.Kind != KindOptional) {
+			return ovsdb.Mutation{}, fmt.Errorf("column %s: expected a single value, got %s", m.Column, m.Value.Kind)
+		}
+		// The operand is a plain number, whatever the limits on the column
+		value, err = nativeAtom(&ovsdb.BaseType{Type: base.Type}, *m.Value.Atom)
+	case ovsdb.MutateOperationInsert, ovsdb.MutateOperationDelete:
+		switch {
+		case column.Type == ovsdb.TypeSet:
+			value, err = nativeSet(column.TypeObj.Key, m.Value)
+		case column.Type == ovsdb.TypeMap && (m.Value.Kind == KindMap || mutator == ovsdb.MutateOperationInsert):
+			value, err = nativeMap(column.TypeObj, m.Value)
+		case column.Type == ovsdb.TypeMap:
+			value, err = nativeSet(column.TypeObj.Key, m.Value)
+		default:
+			return ovsdb.Mutation{}, invalid
+		}
+	default:
+		return ovsdb.Mutation{}, fmt.Errorf("unknown mutator %q for column %s", m.Mutator, m.Column)
+	}
+	if err != nil {
+		return ovsdb.Mutation{}, fmt.Errorf("column %s: %w", m.Column, err)
+	}
+	return *ovsdb.NewMutation(m.Column, mutator, value), nil
+}
+
+// references lists the uuids in a value written to a column
+func references(column *ovsdb.ColumnSchema, v interface{}) []reference {
+	if column == nil || column.TypeObj == nil {
+		return nil
+	}
+	keyTable, _ := column.TypeObj.Key.RefTable()
+	var valueTable string
+	if column.TypeObj.Value != nil {
+		valueTable, _ = column.TypeObj.Value.RefTable()
+	}
+	var refs []reference
+	add := func(table string, elem interface{}) {
+		if uuid, ok := elem.(ovsdb.UUID); ok {
+			refs = append(refs, reference{table: table, uuid: uuid.GoUUID})
+		}
+	}
+	switch val := v.(type) {
+	case ovsdb.OvsSet:
+		for _, elem := range val.GoSet {
+			add(keyTable, elem)
+		}
+	case ovsdb.OvsMap:
+		for k, elem := range val.GoMap {
+			add(keyTable, k)
+			add(valueTable, elem)
+		}
+	default:
+		add(keyTable, val)
+	}
+	return refs
+}
+
+// checkReferences makes sure every referenced row exists, and every named uuid names a row
+// inserted by the transaction. The rows are looked up in a single transaction.
+func (c *OVSDBClient) checkReferences(ctx context.Context, dbName string, refs []reference, names map[string]bool) error {
+	var ops []ovsdb.Operation
+	var checked []reference
+	seen := make(map[reference]bool)
+	for _, ref := range refs {
+		if ovsdb.IsNamedUUID(ref.uuid) {
+			if !names[ref.uuid] {
+				return fmt.Errorf("named uuid %s is not defined by an insert", ref.uuid)
+			}
+			continue
+		}
+		if ref.table == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		checked = append(checked, ref)
+		ops = append(ops, ovsdb.Operation{
+			Op:      ovsdb.OperationSelect,
+			Table:   ref.table,
+			Where:   []ovsdb.Condition{ovsdb.NewCondition("_uuid", ovsdb.ConditionEqual, ovsdb.UUID{GoUUID: ref.uuid})},
+			Columns: []string{"_uuid"},
+		})
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	results, err := c.Transact(ctx, dbName, ops...)
+	if err != nil {
+		return fmt.Errorf("failed to check references: %w", err)
+	}
+	for i, ref := range checked {
+		if i >= len(results) || results[i].Error != "" {
+			return fmt.Errorf("failed to check reference to %s in %s", ref.uuid, ref.table)
+		}
+		if len(results[i].Rows) == 0 {
+			return fmt.Errorf("row %s does not exist in %s", ref.uuid, ref.table)
+		}
+	}
+	return nil
+}
+
+// validUUIDName reports whether a name is an <id> as RFC 7047 requires of uuid-name
+func validUUIDName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,223 @@
+package ovsdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// atomValue is a single typed value, as the frontend sends it
+func atomValue(typ string, v interface{}) Value {
+	return Value{Kind: KindAtom, Atom: &Atom{Type: typ, Value: v}}
+}
+
+func TestBuildOperation(t *testing.T) {
+	schema := testSchema(t)
+	str := func(s string) Value { return atomValue("string", s) }
+	tests := []struct {
+		name string
+		edit Edit
+		err  string
+	}{
+		{"insert with a uuid", Edit{Op: "insert", Table: "Logical_Switch", UUID: switchUUID}, "insert takes no uuid or where clause"},
+		{"invalid uuid name", Edit{Op: "insert", Table: "Logical_Switch", UUIDName: "1st"}, `invalid uuid name "1st"`},
+		{"named update", Edit{Op: "update", Table: "Logical_Switch", UUID: switchUUID, UUIDName: "ls", Row: map[string]Value{"name": str("sw0")}}, "only an insert can name its row"},
+		{"update without columns", Edit{Op: "update", Table: "Logical_Switch", UUID: switchUUID}, "no columns to update"},
+		{"update without rows", Edit{Op: "update", Table: "Logical_Switch", Row: map[string]Value{"name": str("sw0")}}, "a uuid or a where clause is required"},
+		{"delete without rows", Edit{Op: "delete", Table: "Logical_Switch"}, "a uuid or a where clause is required"},
+		{"delete with a row", Edit{Op: "delete", Table: "Logical_Switch", UUID: switchUUID, Row: map[string]Value{"name": str("sw0")}}, "delete takes no row"},
+		{"write of _uuid", Edit{Op: "insert", Table: "Logical_Switch", Row: map[string]Value{"_uuid": atomValue("uuid", switchUUID)}}, "column _uuid cannot be written"},
+		{"unknown column", Edit{Op: "insert", Table: "Logical_Switch", Row: map[string]Value{"other": str("x")}}, "column other not found"},
+		{"wrong type", Edit{Op: "insert", Table: "Logical_Switch", Row: map[string]Value{"priority": str("high")}}, "column priority: expected integer, got string"},
+		{"out of range", Edit{Op: "insert", Table: "Logical_Switch_Port", Row: map[string]Value{"tag": {Kind: KindOptional, Atom: &Atom{Type: "integer", Value: 4096}}}}, "column tag: 4096 is above the maximum of 4095"},
+		{"mutate without mutations", Edit{Op: "mutate", Table: "Logical_Switch", UUID: switchUUID}, "no mutations"},
+		{"mutations on an update", Edit{Op: "update", Table: "Logical_Switch", UUID: switchUUID, Row: map[string]Value{"name": str("sw0")}, Mutations: []Mutation{{Column: "priority", Mutator: "+=", Value: atomValue("integer", 1)}}}, "update takes no mutations"},
+		{"arithmetic on a string", Edit{Op: "mutate", Table: "Logical_Switch", UUID: switchUUID, Mutations: []Mutation{{Column: "name", Mutator: "+=", Value: str("x")}}}, "mutator += is not valid for column name of type string"},
+		{"modulo of a map", Edit{Op: "mutate", Table: "Logical_Switch", UUID: switchUUID, Mutations: []Mutation{{Column: "other_config", Mutator: "%=", Value: atomValue("integer", 2)}}}, "mutator %= is not valid"},
+		{"unknown mutator", Edit{Op: "mutate", Table: "Logical_Switch", UUID: switchUUID, Mutations: []Mutation{{Column: "priority", Mutator: "^=", Value: atomValue("integer", 2)}}}, `unknown mutator "^="`},
+		{"select", Edit{Op: "select", Table: "Logical_Switch"}, `unsupported operation "select"`},
+	}
+	for _, tt := range tests {
+		_, err := buildOperation(schema.Table(tt.edit.Table), tt.edit)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// Map keys are deleted with a set, and arithmetic applies to each element of a set
+	op, err := buildOperation(schema.Table("Logical_Switch"), Edit{Op: "mutate", Table: "Logical_Switch", Where: []Condition{{Column: "name", Function: "==", Value: "sw0"}}, Mutations: []Mutation{
+		{Column: "other_config", Mutator: "delete", Value: Value{Kind: KindSet, Set: []Atom{{Type: "string", Value: "mtu"}}}},
+		{Column: "vlans", Mutator: "+=", Value: atomValue("integer", 1)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(op.Mutations) != 2 || op.Mutations[0].Value.(ovsdb.OvsSet).GoSet[0] != "mtu" || op.Mutations[1].Value != 1 {
+		t.Errorf("mutations = %+v", op.Mutations)
+	}
+}
+
+func TestApplyTransaction(t *testing.T) {
+	sock := startServer(t)
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	str := func(s string) Value { return atomValue("string", s) }
+	apply := func(edits ...Edit) *TransactionResult {
+		t.Helper()
+		result, err := c.ApplyTransaction(ctx, "", edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := apply(Edit{Op: "insert", Table: "Port", Row: map[string]Value{"name": str("p1")}})
+	if !result.Committed || len(result.Results) != 1 || result.Results[0].UUID == "" {
+		t.Fatalf("insert of a port = %+v", result)
+	}
+	port := result.Results[0].UUID
+
+	// Rows are inserted together, referring to each other by name
+	result = apply(
+		Edit{Op: "insert", Table: "Mirror", UUIDName: "m", Row: map[string]Value{
+			"name":            str("m0"),
+			"select_src_port": {Kind: KindSet, Set: []Atom{{Type: "uuid", Value: port}}},
+		}},
+		Edit{Op: "insert", Table: "Bridge", Row: map[string]Value{
+			"name":    str("br0"),
+			"mirrors": {Kind: KindSet, Set: []Atom{{Type: TypeNamedUUID, Value: "m"}}},
+		}},
+	)
+	if !result.Committed || len(result.Results) != 2 || result.Results[1].UUID == "" {
+		t.Fatalf("insert of a bridge and its mirror = %+v", result)
+	}
+
+	// Each operation reports how many rows it changed; the captures for the undo are left out
+	byName := []Condition{{Column: "name", Function: "==", Value: "br0"}}
+	result = apply(
+		Edit{Op: "update", Table: "Bridge", Where: byName, Row: map[string]Value{"datapath_type": str("netdev")}},
+		Edit{Op: "mutate", Table: "Bridge", Where: byName, Mutations: []Mutation{{Column: "external_ids", Mutator: "insert", Value: Value{Kind: KindMap, Map: []Pair{{Key: Atom{Type: "string", Value: "owner"}, Value: Atom{Type: "string", Value: "test"}}}}}}},
+		Edit{Op: "delete", Table: "Bridge", Where: []Condition{{Column: "name", Function: "==", Value: "br9"}}},
+	)
+	if !result.Committed || len(result.Results) != 3 {
+		t.Fatalf("changes = %+v", result)
+	}
+	for i, count := range []int{1, 1, 0} {
+		if r := result.Results[i]; r.Count != count || r.Error != "" {
+			t.Errorf("result %d = %+v, want a count of %d", i, r, count)
+		}
+	}
+	rows, err := c.GetTableData(ctx, "", "Bridge", byName[0])
+	if err != nil || len(rows) != 1 || rows[0]["datapath_type"].Atom.Value != "netdev" || rows[0]["external_ids"].Plain().(map[string]interface{})["owner"] != "test" {
+		t.Errorf("bridge after the changes = %+v, %v", rows, err)
+	}
+
+	// A failed commit writes nothing and reports no uuids
+	result = apply(Edit{Op: "insert", Table: "Port", Row: map[string]Value{"name": str("p2")}}, Edit{Op: "insert", Table: "Port", Row: map[string]Value{"name": str("p1")}})
+	if result.Committed || result.Error != "constraint violation" || result.Results[0].UUID != "" {
+		t.Errorf("insert of a duplicate port = %+v", result)
+	}
+	if rows, _ := c.GetTableData(ctx, "", "Port"); len(rows) != 1 {
+		t.Errorf("failed transaction left %d ports", len(rows))
+	}
+
+	for _, tt := range []struct {
+		name  string
+		edits []Edit
+		err   string
+	}{
+		{"no edits", nil, "no edits given"},
+		{"unknown table", []Edit{{Op: "insert", Table: "Other"}}, "edit 1: table Other not found in Open_vSwitch"},
+		{"immutable column", []Edit{{Op: "update", Table: "Bridge", Where: byName, Row: map[string]Value{"name": str("br1")}}}, "edit 1 (update on Bridge): column name is immutable"},
+		{"missing reference", []Edit{{Op: "insert", Table: "Mirror", Row: map[string]Value{"select_src_port": {Kind: KindSet, Set: []Atom{{Type: "uuid", Value: switchUUID}}}}}}, "row " + switchUUID + " does not exist in Port"},
+		{"undefined name", []Edit{{Op: "insert", Table: "Bridge", Row: map[string]Value{"mirrors": {Kind: KindSet, Set: []Atom{{Type: TypeNamedUUID, Value: "m"}}}}}}, "named uuid m is not defined by an insert"},
+		{"name used twice", []Edit{{Op: "insert", Table: "Port", UUIDName: "p"}, {Op: "insert", Table: "Port", UUIDName: "p"}}, "edit 2: uuid name p is used twice"},
+		{"integer out of range", []Edit{{Op: "insert", Table: "Flow_Sample_Collector_Set", Row: map[string]Value{"id": atomValue("integer", 1<<33)}}}, "8589934592 is above the maximum of 4294967295"},
+	} {
+		if _, err := c.BuildTransaction(ctx, "", tt.edits); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	preview, err := c.PreviewTransaction(ctx, "", []Edit{{Op: "delete", Table: "Bridge", Where: byName}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"method": "transact"`, `"Open_vSwitch"`, `"op": "delete"`, `"br0"`} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("preview does not contain %s:\n%s", want, preview)
+		}
+	}
+	if strings.Contains(preview, `"select"`) {
+		t.Errorf("preview holds the capture for the undo:\n%s", preview)
+	}
+}
+
+func TestGuardedUpdate(t *testing.T) {
+	sock := startServer(t)
+	c := &OVSDBClient{}
+	connectServer(t, c, sock)
+	ctx := context.Background()
+	str := func(s string) Value { return atomValue("string", s) }
+	result, err := c.ApplyTransaction(ctx, "", []Edit{{Op: "insert", Table: "Bridge", Row: map[string]Value{"name": str("br0"), "datapath_type": str("system")}}})
+	if err != nil || !result.Committed {
+		t.Fatalf("insert = %+v, %v", result, err)
+	}
+	bridge := result.Results[0].UUID
+
+	// The update goes through while the row holds the values it was shown with
+	result, err = c.GuardedUpdate(ctx, "", "Bridge", bridge, map[string]Value{"datapath_type": str("system")}, map[string]Value{"datapath_type": str("netdev")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Committed || result.Conflict != nil || len(result.Results) != 2 || result.Results[0].Op != "wait" || result.Results[1].Count != 1 {
+		t.Fatalf("guarded update = %+v", result)
+	}
+
+	// Once another change came in between, nothing is written and the three versions are
+	// reported
+	result, err = c.GuardedUpdate(ctx, "", "Bridge", bridge, map[string]Value{"datapath_type": str("system")}, map[string]Value{"datapath_type": str("dummy")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict := result.Conflict
+	if result.Committed || conflict == nil || conflict.Deleted || conflict.Table != "Bridge" || conflict.UUID != bridge {
+		t.Fatalf("conflicting update = %+v", result)
+	}
+	if conflict.Expected["datapath_type"].Atom.Value != "system" || conflict.Current["datapath_type"].Atom.Value != "netdev" || conflict.Proposed["datapath_type"].Atom.Value != "dummy" {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	rows, err := c.GetTableData(ctx, "", "Bridge")
+	if err != nil || rows[0]["datapath_type"].Atom.Value != "netdev" {
+		t.Errorf("bridge after the conflict = %+v, %v", rows, err)
+	}
+
+	if _, err := c.ApplyTransaction(ctx, "", []Edit{{Op: "delete", Table: "Bridge", UUID: bridge}}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = c.GuardedUpdate(ctx, "", "Bridge", bridge, map[string]Value{"datapath_type": str("netdev")}, map[string]Value{"datapath_type": str("system")})
+	if err != nil || result.Committed || result.Conflict == nil || !result.Conflict.Deleted || result.Conflict.Current != nil {
+		t.Errorf("update of a deleted row = %+v, %v", result, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		edit Edit
+		err  string
+	}{
+		{"insert", Edit{Op: "insert", Table: "Bridge", Expect: map[string]Value{"name": str("br0")}}, "an insert has no values to expect"},
+		{"rows by condition", Edit{Op: "delete", Table: "Bridge", Where: []Condition{{Column: "name", Function: "==", Value: "br0"}}, Expect: map[string]Value{"name": str("br0")}}, "expected values need the uuid of the row"},
+		{"unknown column", Edit{Op: "delete", Table: "Bridge", UUID: bridge, Expect: map[string]Value{"other": str("x")}}, "expected column other not found"},
+		{"wrong type", Edit{Op: "delete", Table: "Bridge", UUID: bridge, Expect: map[string]Value{"name": atomValue("integer", 1)}}, "expected column name: expected string, got integer"},
+	} {
+		if _, err := c.BuildTransaction(ctx, "", []Edit{tt.edit}); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+	if _, err := c.GuardedUpdate(ctx, "", "Bridge", bridge, nil, map[string]Value{"datapath_type": str("system")}); err == nil {
+		t.Error("update without original values was not refused")
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const profilesVersion = 1
+
+// Profile is a named connection the user keeps, unlike the recent connections of the
+// history, which come and go on their own
+type Profile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Tags name the environment, such as prod, staging or lab
+	Tags []string `json:"tags,omitempty"`
+	// Folder groups profiles, with / between nested folders such as "ovn/east"
+	Folder    string           `json:"folder,omitempty"`
+	Endpoints []EndpointConfig `json:"endpoints"`
+	Follower  string           `json:"follower,omitempty"`
+	// Database is opened on connecting; Open_vSwitch when empty
+	Database string `json:"database,omitempty"`
+	// ReadOnly, when set, overrides the read-only default for the profile's connections
+	ReadOnly *bool `json:"readOnly,omitempty"`
+	Favorite bool  `json:"favorite,omitempty"`
+	Created  int64 `json:"created"`
+	Updated  int64 `json:"updated"`
+	LastUsed int64 `json:"lastUsed,omitempty"`
+	// Shared is the file of a shared profile directory the profile was read from; shared
+	// profiles cannot be changed, only imported or overridden by saving one with their id
+	Shared string `json:"shared,omitempty"`
+	// UnconfirmedCommands are the relay commands of the profile, as command lines, that
+	// were not confirmed to run on this machine; ConnectProfile refuses the profile until
+	// TrustProfile confirms them. They are worked out when profiles are listed.
+	UnconfirmedCommands []string `json:"unconfirmedCommands,omitempty"`
+}
+
+// profileFile is the layout of profiles.json
+type profileFile struct {
+	Version  int       `json:"version"`
+	Profiles []Profile `json:"profiles"`
+}
+
+// GetProfiles returns the saved profiles, the user's own and the shared ones they do not
+// override, ordered by folder and name
+func (a *App) GetProfiles() []Profile {
+	trusted := a.trustedCommands()
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	profiles := append([]Profile{}, a.profiles...)
+	for _, p := range a.sharedProfiles {
+		if a.ownProfile(p.ID) < 0 {
+			profiles = append(profiles, p)
+		}
+	}
+	for i := range profiles {
+		profiles[i].UnconfirmedCommands = unconfirmedCommands(profiles[i], trusted)
+	}
+	sortProfiles(profiles)
+	return profiles
+}
+
+// SaveProfile creates a profile when its id is empty, or replaces the profile with its id,
+// and returns it as stored. Names are unique within a folder. The relay commands of a
+// profile the user saves are trusted.
+func (a *App) SaveProfile(profile Profile) (*Profile, error) {
+	profile, err := normalizeProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	index := -1
+	for i, p := range a.profiles {
+		if p.ID == profile.ID && profile.ID != "" {
+			index = i
+		} else if p.Folder == profile.Folder && strings.EqualFold(p.Name, profile.Name) {
+			return nil, fmt.Errorf("a profile named %q already exists in %s", profile.Name, folderName(profile.Folder))
+		}
+	}
+
+	now := time.Now().Unix()
+	profile.Updated = now
+	profile.Shared = ""
+	switch {
+	case index >= 0:
+		profile.Created = a.profiles[index].Created
+		profile.LastUsed = a.profiles[index].LastUsed
+		a.profiles[index] = profile
+	case profile.ID != "":
+		// Saving a shared profile keeps a copy of the user's own in its place
+		if a.sharedProfile(profile.ID) == nil {
+			return nil, fmt.Errorf("no profile with id %s", profile.ID)
+		}
+		profile.Created = now
+		a.profiles = append(a.profiles, profile)
+	default:
+		profile.ID = randomToken()
+		profile.Created = now
+		a.profiles = append(a.profiles, profile)
+	}
+	if err := a.writeProfiles(); err != nil {
+		return nil, err
+	}
+	if err := a.trustCommands(profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// DeleteProfile removes a profile by id
+func (a *App) DeleteProfile(id string) error {
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	i := a.ownProfile(id)
+	if i < 0 {
+		return a.missingProfile(id)
+	}
+	a.profiles = append(a.profiles[:i:i], a.profiles[i+1:]...)
+	return a.writeProfiles()
+}
+
+// SetProfileFavorite marks a profile as a favourite or not
+func (a *App) SetProfileFavorite(id string, favorite bool) error {
+	return a.updateProfile(id, func(p *Profile) { p.Favorite = favorite })
+}
+
+// ConnectProfile connects a session, as ConnectDynamic does, with a profile's endpoints and
+// read-only mode, and returns the session's id. Web sessions may use the profile's command
+// endpoints and files, which ConnectDynamic refuses them, once the commands are confirmed.
+// An empty dbName opens the profile's default database.
+func (a *App) ConnectProfile(sessionID string, id string, dbName string) (string, error) {
+	profile, err := a.profile(id)
+	if err != nil {
+		return "", err
+	}
+	if dbName == "" {
+		dbName = profile.Database
+	}
+	if dbName == "" {
+		dbName = "Open_vSwitch"
+	}
+	if commands := unconfirmedCommands(*profile, a.trustedCommands()); len(commands) > 0 {
+		return "", fmt.Errorf("profile %q runs %s on this machine and must be confirmed first", profile.Name, strings.Join(commands, "; "))
+	}
+	// Profiles are kept on the server, so they may name its commands and files
+	sessionID, err = a.connect(sessionID, ConnectRequest{
+		Endpoints: cloneEndpoints(profile.Endpoints),
+		Follower:  profile.Follower,
+		ReadOnly:  profile.ReadOnly,
+	}, dbName, false)
+	if err != nil {
+		return "", err
+	}
+	_ = a.updateProfile(id, func(p *Profile) { p.LastUsed = time.Now().Unix() })
+	return sessionID, nil
+}
+
+// profile returns a copy of the profile with the given id
+func (a *App) profile(id string) (*Profile, error) {
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	if i := a.ownProfile(id); i >= 0 {
+		p := a.profiles[i]
+		return &p, nil
+	}
+	if p := a.sharedProfile(id); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("no profile with id %s", id)
+}
+
+// updateProfile changes one of the user's own profiles and saves them
+func (a *App) updateProfile(id string, update func(*Profile)) error {
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	i := a.ownProfile(id)
+	if i < 0 {
+		return a.missingProfile(id)
+	}
+	update(&a.profiles[i])
+	return a.writeProfiles()
+}
+
+// ownProfile returns the index of the user's own profile with the given id, or -1;
+// profilesMu must be held
+func (a *App) ownProfile(id string) int {
+	for i, p := range a.profiles {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// sharedProfile returns a copy of the shared profile with the given id, or nil;
+// profilesMu must be held
+func (a *App) sharedProfile(id string) *Profile {
+	for _, p := range a.sharedProfiles {
+		if p.ID == id {
+			return &p
+		}
+	}
+	return nil
+}
+
+// missingProfile explains why a profile that is not the user's own cannot be changed
+func (a *App) missingProfile(id string) error {
+	if p := a.sharedProfile(id); p != nil {
+		return fmt.Errorf("profile %q is shared from %s and cannot be changed", p.Name, p.Shared)
+	}
+	return fmt.Errorf("no profile with id %s", id)
+}
+
+// SaveProfiles saves the profiles to a file
+func (a *App) SaveProfiles() error {
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	return a.writeProfiles()
+}
+
+// writeProfiles saves the profiles; profilesMu must be held. The file is replaced in one
+// step, so it is never left half written.
+func (a *App) writeProfiles() error {
+	if a.profilesErr != nil {
+		return fmt.Errorf("profiles are not saved while profiles.json cannot be read (%w); fix or remove it and reload", a.profilesErr)
+	}
+	data, err := json.MarshalIndent(profileFile{Version: profilesVersion, Profiles: a.profiles}, "", "  ")
+	if err != nil {
+		return err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(homeDir, ".ovsdb-viewer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "profiles.json"), data, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadProfiles loads the profiles from a file, and the shared ones from the shared profile
+// directories. The first time, when there is no file yet, every entry of the connection
+// history becomes a profile named after its endpoints.
+func (a *App) LoadProfiles() error {
+	shared, sharedErr := loadSharedProfiles(a.sharedProfileDirs())
+	a.profilesMu.Lock()
+	defer a.profilesMu.Unlock()
+	a.sharedProfiles = shared
+	if err := a.loadOwnProfiles(); err != nil {
+		return err
+	}
+	return sharedErr
+}
+
+// loadOwnProfiles loads the user's own profiles; profilesMu must be held. A file that
+// cannot be read is kept from being written over until it loads.
+func (a *App) loadOwnProfiles() error {
+	a.profiles = []Profile{}
+	a.profilesErr = nil
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, ".ovsdb-viewer", "profiles.json"))
+	if os.IsNotExist(err) {
+		a.profiles = profilesFromHistory(a.GetHistory())
+		if len(a.profiles) == 0 {
+			return nil
+		}
+		return a.writeProfiles()
+	}
+	if err != nil {
+		a.profilesErr = err
+		return err
+	}
+	var file profileFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		a.profilesErr = fmt.Errorf("failed to read profiles: %w", err)
+		return a.profilesErr
+	}
+	if file.Version > profilesVersion {
+		a.profilesErr = fmt.Errorf("profiles.json is version %d; this version reads up to %d", file.Version, profilesVersion)
+		return a.profilesErr
+	}
+	a.profiles = file.Profiles
+	return nil
+}
+
+// profilesFromHistory turns recent connections into profiles, oldest first
+func profilesFromHistory(history []ConnectionHistory) []Profile {
+	profiles := []Profile{}
+	for i := len(history) - 1; i >= 0; i-- {
+		h := history[i]
+		profile, err := normalizeProfile(Profile{
+			Name:      historyProfileName(h.Endpoints),
+			Endpoints: cloneEndpoints(h.Endpoints),
+			ReadOnly:  h.ReadOnly,
+		})
+		if err != nil {
+			continue
+		}
+		base := profile.Name
+		for n := 2; profileNameTaken(profiles, profile.Folder, profile.Name); n++ {
+			profile.Name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		profile.ID = randomToken()
+		profile.Created = h.Timestamp
+		profile.Updated = h.Timestamp
+		profile.LastUsed = h.Timestamp
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// historyProfileName names a profile after the first of its endpoints
+func historyProfileName(endpoints []EndpointConfig) string {
+	if len(endpoints) == 0 {
+		return ""
+	}
+	name := endpoints[0].Endpoint
+	if t := endpoints[0].Tunnel; t != nil && t.Host != "" {
+		name += " via " + t.Host
+	}
+	if len(endpoints) > 1 {
+		name += fmt.Sprintf(" +%d", len(endpoints)-1)
+	}
+	return name
+}
+
+func profileNameTaken(profiles []Profile, folder string, name string) bool {
+	for _, p := range profiles {
+		if p.Folder == folder && strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeProfile trims a profile and checks it can be connected with
+func normalizeProfile(p Profile) (Profile, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return p, fmt.Errorf("a profile needs a name")
+	}
+	p.Description = strings.TrimSpace(p.Description)
+	p.Follower = strings.TrimSpace(p.Follower)
+	p.Database = strings.TrimSpace(p.Database)
+
+	var folders []string
+	for _, f := range strings.Split(p.Folder, "/") {
+		if f = strings.TrimSpace(f); f != "" {
+			folders = append(folders, f)
+		}
+	}
+	p.Folder = strings.Join(folders, "/")
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, tag := range p.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	p.Tags = tags
+
+	p.UnconfirmedCommands = nil
+	p.Endpoints = normalizeEndpoints(p.Endpoints)
+	if len(p.Endpoints) == 0 {
+		return p, fmt.Errorf("profile %q has no endpoints", p.Name)
+	}
+	return p, nil
+}
+
+func sortProfiles(profiles []Profile) {
+	sort.SliceStable(profiles, func(i, j int) bool {
+		if profiles[i].Folder != profiles[j].Folder {
+			return profiles[i].Folder < profiles[j].Folder
+		}
+		return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+	})
+}
+
+func folderName(folder string) string {
+	if folder == "" {
+		return "the top folder"
+	}
+	return "folder " + folder
+}
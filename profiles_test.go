@@ -0,0 +1,256 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCorruptProfilesAreNotOverwritten(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".ovsdb-viewer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "profiles.json")
+	corrupt := []byte(`{"version": 1, "profiles": [{"id": "a", "name": "lab"`)
+	if err := os.WriteFile(file, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewApp()
+	if err := a.LoadProfiles(); err == nil {
+		t.Fatal("corrupt profiles.json loaded without an error")
+	}
+	profile := Profile{Name: "east", Endpoints: []EndpointConfig{{Endpoint: "tcp:10.0.0.1:6641"}}}
+	if _, err := a.SaveProfile(profile); err == nil {
+		t.Fatal("profile saved over a profiles.json that could not be read")
+	}
+	if data, _ := os.ReadFile(file); string(data) != string(corrupt) {
+		t.Fatalf("profiles.json was changed to %s", data)
+	}
+
+	// Once the file is repaired and reloaded, saving works again
+	if err := os.WriteFile(file, []byte(`{"version": 1, "profiles": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SaveProfile(profile); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "profiles.json" {
+			t.Errorf("saving left %s behind", entry.Name())
+		}
+	}
+	reloaded := NewApp()
+	if err := reloaded.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if profiles := reloaded.GetProfiles(); len(profiles) != 1 || profiles[0].Name != "east" {
+		t.Fatalf("reloaded profiles are %+v", profiles)
+	}
+}
+
+func TestImportedCommandsNeedConfirmation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	shared := t.TempDir()
+	t.Setenv("OVSDB_VIEWER_PROFILE_DIRS", shared)
+	bundle := []byte(`{"version": 1, "profiles": [
+		{"id": "relay", "name": "relay", "endpoints": [{"endpoint": "unix:/run/ovn/ovnnb_db.sock", "command": {"command": "kubectl", "args": ["exec", "-i", "ovnkube-db", "--", "socat", "-", "UNIX-CONNECT:{address}"]}}]},
+		{"id": "direct", "name": "direct", "endpoints": [{"endpoint": "tcp:10.0.0.1:6641"}]}
+	]}`)
+	if err := os.WriteFile(filepath.Join(shared, "team.json"), bundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewApp()
+	if err := a.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	unconfirmed := func(a *App) map[string]bool {
+		names := make(map[string]bool)
+		for _, p := range a.GetProfiles() {
+			if len(p.UnconfirmedCommands) > 0 {
+				names[p.ID] = p.Shared != ""
+			}
+		}
+		return names
+	}
+	if got := unconfirmed(a); len(got) != 1 || !got["relay"] {
+		t.Fatalf("unconfirmed profiles after loading the shared directory are %v", got)
+	}
+
+	result, err := a.importProfileBundle(bundle, ImportRename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Unconfirmed) != 1 || result.Unconfirmed[0] != "relay" {
+		t.Fatalf("import reported %q as unconfirmed", result.Unconfirmed)
+	}
+	if got := unconfirmed(a); len(got) != 1 || got["relay"] {
+		t.Fatalf("unconfirmed profiles after the import are %v", got)
+	}
+	if _, err := a.ConnectProfile("", "relay", ""); err == nil || !strings.Contains(err.Error(), "must be confirmed") {
+		t.Fatalf("connecting an unconfirmed profile returned %v", err)
+	}
+
+	// Confirming the imported profile also confirms the same command in the shared one
+	if err := a.TrustProfile("relay"); err != nil {
+		t.Fatal(err)
+	}
+	if got := unconfirmed(a); len(got) != 0 {
+		t.Fatalf("unconfirmed profiles after confirming are %v", got)
+	}
+	reloaded := NewApp()
+	if err := reloaded.LoadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if got := unconfirmed(reloaded); len(got) != 0 {
+		t.Fatalf("unconfirmed profiles after reloading are %v", got)
+	}
+
+	// A changed command needs confirming again
+	changed := strings.NewReplacer(`"id": "relay"`, `"id": "relay-1"`, "ovnkube-db", "ovnkube-db-1").Replace(string(bundle))
+	if err := os.WriteFile(filepath.Join(shared, "team.json"), []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if got := unconfirmed(reloaded); len(got) != 1 || !got["relay-1"] {
+		t.Fatalf("unconfirmed profiles after the command changed are %v", got)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	a := testApp(t)
+	endpoint := startServer(t)
+	readOnly := false
+	saved, err := a.SaveProfile(Profile{
+		Name:      " east ",
+		Folder:    "ovn/ / prod/",
+		Tags:      []string{"Prod", "lab", " prod"},
+		Endpoints: []EndpointConfig{{Endpoint: endpoint}},
+		ReadOnly:  &readOnly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID == "" || saved.Name != "east" || saved.Folder != "ovn/prod" || !reflect.DeepEqual(saved.Tags, []string{"lab", "prod"}) || saved.Created == 0 {
+		t.Errorf("saved profile = %+v", saved)
+	}
+	for _, p := range []Profile{
+		{Name: "EAST", Folder: "ovn/prod", Endpoints: []EndpointConfig{{Endpoint: endpoint}}},
+		{Name: " ", Endpoints: []EndpointConfig{{Endpoint: endpoint}}},
+		{Name: "west"},
+		{ID: "unknown", Name: "west", Endpoints: []EndpointConfig{{Endpoint: endpoint}}},
+	} {
+		if _, err := a.SaveProfile(p); err == nil {
+			t.Errorf("profile %+v was saved", p)
+		}
+	}
+	// The same name is free in another folder
+	west, err := a.SaveProfile(Profile{Name: "east", Database: "_Server", Endpoints: []EndpointConfig{{Endpoint: endpoint}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := *saved
+	updated.Description = "East region"
+	if _, err := a.SaveProfile(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetProfileFavorite(saved.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	profiles := a.GetProfiles()
+	if len(profiles) != 2 || profiles[0].ID != west.ID || profiles[1].Description != "East region" || !profiles[1].Favorite || profiles[1].Created != saved.Created {
+		t.Errorf("profiles = %+v", profiles)
+	}
+
+	// Connecting opens the profile's database with its read-only mode
+	id, err := a.ConnectProfile("", saved.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := a.GetReadOnlyStatus(id); status.ReadOnly || status.Source != ReadOnlySourceConnection {
+		t.Errorf("read-only status of the profile's session = %+v", status)
+	}
+	if sessions := a.ListSessions(); len(sessions) != 1 || sessions[0].Database != "Open_vSwitch" {
+		t.Errorf("sessions = %+v", sessions)
+	}
+	if p, _ := a.profile(saved.ID); p.LastUsed == 0 {
+		t.Error("connecting did not record when the profile was used")
+	}
+
+	if err := a.DeleteProfile(west.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteProfile(west.ID); err == nil {
+		t.Error("deleted a profile twice")
+	}
+	reloaded := NewApp()
+	if err := reloaded.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if profiles := reloaded.GetProfiles(); len(profiles) != 1 || profiles[0].ID != saved.ID || !profiles[0].Favorite || profiles[0].ReadOnly == nil {
+		t.Errorf("reloaded profiles = %+v", profiles)
+	}
+}
+
+func TestProfilesFromHistory(t *testing.T) {
+	a := testApp(t)
+	readOnly := true
+	for i, ep := range [][]EndpointConfig{
+		{{Endpoint: "tcp:10.0.0.1:6641"}},
+		{{Endpoint: "tcp:10.0.0.1:6641", Tunnel: &TunnelConfig{Host: "bastion"}}},
+		{{Endpoint: "tcp:10.0.0.1:6641", Tunnel: &TunnelConfig{Host: "bastion", User: "admin"}}},
+		{{Endpoint: "tcp:10.0.0.1:6641"}, {Endpoint: "tcp:10.0.0.2:6641"}},
+	} {
+		a.AddToHistory(ConnectionHistory{Endpoints: ep, ReadOnly: &readOnly, Timestamp: int64(1700000000 + i)})
+	}
+	if err := a.SaveHistory(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first start without profiles turns the recent connections into profiles, once
+	b := NewApp()
+	if err := b.LoadHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LoadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range b.GetProfiles() {
+		names = append(names, p.Name)
+		if p.ReadOnly == nil || !*p.ReadOnly || p.LastUsed < 1700000000 {
+			t.Errorf("profile from history = %+v", p)
+		}
+	}
+	want := []string{"tcp:10.0.0.1:6641", "tcp:10.0.0.1:6641 +1", "tcp:10.0.0.1:6641 via bastion", "tcp:10.0.0.1:6641 via bastion (2)"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("profiles from history are %q, want %q", names, want)
+	}
+	if err := b.DeleteProfile(b.GetProfiles()[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	c := NewApp()
+	c.LoadHistory()
+	if err := c.LoadProfiles(); err != nil || len(c.GetProfiles()) != 3 {
+		t.Errorf("profiles after a restart = %+v, %v", c.GetProfiles(), err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"ovsdb-viewer/internal/ovsdb"
+)
+
+// promptTimeout bounds how long a connection attempt waits for the user to answer a prompt
+const promptTimeout = 2 * time.Minute
+
+// HostKeyPrompt is emitted to the frontend as the "ssh:host-key" event when a host key is not in known_hosts
+type HostKeyPrompt struct {
+	ID          string `json:"id"`
+	Host        string `json:"host"`
+	Address     string `json:"address"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// AuthPrompt is emitted to the frontend as the "ssh:auth-prompt" event when a passphrase,
+// password or keyboard-interactive answer is needed
+type AuthPrompt struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Host    string `json:"host"`
+	User    string `json:"user"`
+	Message string `json:"message"`
+	Echo    bool   `json:"echo"`
+}
+
+// promptAnswer is the user's reply to a pending prompt
+type promptAnswer struct {
+	ok    bool
+	value string
+}
+
+// ConfirmHostKey answers a pending "ssh:host-key" prompt
+func (a *App) ConfirmHostKey(id string, accept bool) error {
+	return a.answerPrompt(id, promptAnswer{ok: accept})
+}
+
+// AnswerAuthPrompt answers a pending "ssh:auth-prompt" prompt; ok=false cancels authentication
+func (a *App) AnswerAuthPrompt(id string, value string, ok bool) error {
+	return a.answerPrompt(id, promptAnswer{ok: ok, value: value})
+}
+
+// promptHostKey asks the frontend to confirm an unknown host key and blocks until it answers
+func (a *App) promptHostKey(p ovsdb.HostKeyPrompt) bool {
+	id, ch := a.newPrompt()
+	a.emitEvent("ssh:host-key", HostKeyPrompt{
+		ID:          id,
+		Host:        p.Host,
+		Address:     p.Address,
+		KeyType:     p.KeyType,
+		Fingerprint: p.Fingerprint,
+	})
+	answer, err := a.waitPrompt(id, ch)
+	return err == nil && answer.ok
+}
+
+// promptAuth asks the frontend for an authentication secret and blocks until it answers
+func (a *App) promptAuth(p ovsdb.AuthPrompt) (string, error) {
+	id, ch := a.newPrompt()
+	a.emitEvent("ssh:auth-prompt", AuthPrompt{
+		ID:      id,
+		Kind:    p.Kind,
+		Host:    p.Host,
+		User:    p.User,
+		Message: p.Message,
+		Echo:    p.Echo,
+	})
+	answer, err := a.waitPrompt(id, ch)
+	if err != nil {
+		return "", err
+	}
+	if !answer.ok {
+		return "", fmt.Errorf("%s for %s@%s was cancelled", p.Kind, p.User, p.Host)
+	}
+	return answer.value, nil
+}
+
+// newPrompt registers a pending prompt and returns its id and answer channel
+func (a *App) newPrompt() (string, chan promptAnswer) {
+	a.promptMu.Lock()
+	defer a.promptMu.Unlock()
+	if a.prompts == nil {
+		a.prompts = make(map[string]chan promptAnswer)
+	}
+	a.promptSeq++
+	id := strconv.FormatUint(a.promptSeq, 10)
+	// Buffered so a late answer never blocks the bound method
+	ch := make(chan promptAnswer, 1)
+	a.prompts[id] = ch
+	return id, ch
+}
+
+func (a *App) waitPrompt(id string, ch chan promptAnswer) (promptAnswer, error) {
+	select {
+	case answer := <-ch:
+		return answer, nil
+	case <-time.After(promptTimeout):
+		a.promptMu.Lock()
+		delete(a.prompts, id)
+		a.promptMu.Unlock()
+		return promptAnswer{}, fmt.Errorf("prompt timed out")
+	}
+}
+
+func (a *App) answerPrompt(id string, answer promptAnswer) error {
+	a.promptMu.Lock()
+	ch, ok := a.prompts[id]
+	delete(a.prompts, id)
+	a.promptMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no pending prompt with id %s", id)
+	}
+	ch <- answer
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"ovsdb-viewer/internal/ovsdb"
+)
+
+func TestPrompts(t *testing.T) {
+	a := NewApp()
+	events := make(chan interface{}, 1)
+	a.emit = func(name string, data interface{}) { events <- data }
+
+	// The frontend answers each prompt from its dialog through the bound methods
+	go func() {
+		p := (<-events).(HostKeyPrompt)
+		if p.Fingerprint != "SHA256:abc" {
+			t.Errorf("host key prompt = %+v", p)
+		}
+		a.ConfirmHostKey(p.ID, true)
+		a.ConfirmHostKey((<-events).(HostKeyPrompt).ID, false)
+		a.AnswerAuthPrompt((<-events).(AuthPrompt).ID, "secret", true)
+		a.AnswerAuthPrompt((<-events).(AuthPrompt).ID, "", false)
+	}()
+	key := ovsdb.HostKeyPrompt{Host: "node1:22", KeyType: "ssh-ed25519", Fingerprint: "SHA256:abc"}
+	if !a.promptHostKey(key) {
+		t.Error("accepted host key was not trusted")
+	}
+	if a.promptHostKey(key) {
+		t.Error("rejected host key was trusted")
+	}
+	auth := ovsdb.AuthPrompt{Kind: "password", Host: "node1:22", User: "root"}
+	if answer, err := a.promptAuth(auth); err != nil || answer != "secret" {
+		t.Errorf("password prompt = %q, %v", answer, err)
+	}
+	if _, err := a.promptAuth(auth); err == nil {
+		t.Error("cancelled password prompt returned no error")
+	}
+
+	if err := a.ConfirmHostKey("unknown", true); err == nil {
+		t.Error("answered a prompt that is not pending")
+	}
+}
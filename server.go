@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// webMethods are the App methods the HTTP API exposes. Methods that open native dialogs,
+// read files of the server or change its global settings are left out.
+var webMethods = map[string]bool{
+	"ConnectOVSDB":        true,
+	"ConnectDynamic":      true,
+	"DisconnectOVSDB":     true,
+	"ListSessions":        true,
+	"GetConnectionState":  true,
+	"GetClusterStatus":    true,
+	"GetReadOnlyStatus":   true,
+	"GetHistory":          true,
+	"DeleteHistory":       true,
+	"GetProfiles":         true,
+	"ConnectProfile":      true,
+	"ListDatabases":       true,
+	"GetSchema":           true,
+	"GetTable":            true,
+	"GetSchemaDynamic":    true,
+	"GetTableDynamic":     true,
+	"QueryTable":          true,
+	"GetTablePage":        true,
+	"PreviewTransaction":  true,
+	"ApplyTransaction":    true,
+	"GuardedUpdate":       true,
+	"GetUndoHistory":      true,
+	"UndoLast":            true,
+	"UndoTransaction":     true,
+	"GetAuditLog":         true,
+	"MonitorTable":        true,
+	"StopMonitorTable":    true,
+	"ConfirmHostKey":      true,
+	"AnswerAuthPrompt":    true,
+	"GetDatabaseFileInfo": true,
+}
+
+// Cookies of the web server
+const (
+	tokenCookie   = "ovsdb_viewer_token"
+	sessionCookie = "ovsdb_viewer_session"
+)
+
+// sessionHeader names the session of an API client authenticated with a bearer token, which
+// starts one with POST /api/session
+const sessionHeader = "X-Ovsdb-Viewer-Session"
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Limits of the web server
+const (
+	webWriteTimeout      = 10 * time.Second // how long an event waits for a slow browser
+	webReadHeaderTimeout = 10 * time.Second // how long a client may take to send headers
+	webMaxCallBody       = 8 << 20          // bytes of the arguments of one call
+)
+
+// webServer serves the UI and the App methods to browsers. Each browser session gets an
+// App of its own, so its connections are independent of every other browser's, while the
+// history, profiles and settings are those of app and shared by every session.
+type webServer struct {
+	ctx    context.Context
+	app    *App
+	token  string
+	assets fs.FS
+	idle   time.Duration // sessions without a socket are dropped after this long unused
+
+	mu       sync.Mutex
+	sessions map[string]*webSession
+	upgrader websocket.Upgrader
+}
+
+// webSession is the App of one browser session and the sockets its events go to
+type webSession struct {
+	app *App
+
+	mu       sync.Mutex
+	sockets  map[*websocket.Conn]bool
+	lastUsed time.Time
+}
+
+// webEvent is an App event as pushed over the WebSocket
+type webEvent struct {
+	Name string      `json:"name"`
+	Data interface{} `json:"data"`
+}
+
+func newWebServer(app *App, token string, assets fs.FS, idle time.Duration) *webServer {
+	return &webServer{
+		ctx:      app.ctx,
+		app:      app,
+		token:    token,
+		assets:   assets,
+		idle:     idle,
+		sessions: make(map[string]*webSession),
+	}
+}
+
+// ServeHTTP authenticates every request, then serves the API, the event socket or the UI
+func (s *webServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticate(w, r) {
+		return
+	}
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/api/call/"):
+		s.serveCall(w, r, strings.TrimPrefix(r.URL.Path, "/api/call/"))
+	case r.URL.Path == "/api/events":
+		s.serveEvents(w, r)
+	case r.URL.Path == "/api/session":
+		s.serveSession(w, r)
+	case r.URL.Path == "/bridge.js":
+		w.Header().Set("Content-Type", "text/javascript")
+		io.WriteString(w, bridgeScript)
+	case r.URL.Path == "/" || r.URL.Path == "/index.html":
+		// The session starts with the page, before the UI calls and subscribes
+		s.session(w, r)
+		s.serveIndex(w)
+	default:
+		http.FileServer(http.FS(s.assets)).ServeHTTP(w, r)
+	}
+}
+
+// authenticate accepts the token as a bearer token or as the cookie that a first visit
+// with ?token= sets. That visit is redirected so the token leaves the address bar.
+func (s *webServer) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	if token := r.URL.Query().Get("token"); token != "" && s.validToken(token) {
+		http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode, Secure: r.TLS != nil})
+		query := r.URL.Query()
+		query.Del("token")
+		target := *r.URL
+		target.RawQuery = query.Encode()
+		http.Redirect(w, r, target.String(), http.StatusSeeOther)
+		return false
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(bearer) {
+		return true
+	}
+	if cookie, err := r.Cookie(tokenCookie); err == nil && s.validToken(cookie.Value) {
+		return true
+	}
+	http.Error(w, "unauthorized: open the address with ?token=<token>, or send it as a bearer token", http.StatusUnauthorized)
+	return false
+}
+
+func (s *webServer) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// session returns the session a request belongs to: the one its session header names, or
+// else its cookie's. A browser without a session gets a new one with a cookie; an API client
+// has to start one with POST /api/session and name it in the header, so that calls made
+// without a cookie jar do not each open a session of their own.
+func (s *webServer) session(w http.ResponseWriter, r *http.Request) (*webSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id := r.Header.Get(sessionHeader); id != "" {
+		session, ok := s.sessions[id]
+		if !ok {
+			return nil, fmt.Errorf("no session %s; it may have expired, start another with POST /api/session", id)
+		}
+		session.touch()
+		return session, nil
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if session, ok := s.sessions[cookie.Value]; ok {
+			session.touch()
+			return session, nil
+		}
+	}
+	if cookie, err := r.Cookie(tokenCookie); err != nil || !s.validToken(cookie.Value) {
+		return nil, fmt.Errorf("no session: start one with POST /api/session and send its id in the %s header", sessionHeader)
+	}
+
+	id, session := s.newSession(r)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode, Secure: r.TLS != nil})
+	return session, nil
+}
+
+// newSession starts a session with a new App for the client of a request; s.mu must be held
+func (s *webServer) newSession(r *http.Request) (string, *webSession) {
+	id := randomToken()
+	session := &webSession{sockets: make(map[*websocket.Conn]bool), lastUsed: time.Now()}
+	session.app = newWebApp(s.app, webUser(id, r))
+	session.app.emit = session.push
+	s.sessions[id] = session
+	return id, session
+}
+
+// webUser names a session in the audit log by a digest of its id, which is a secret, and
+// the address of the client that started it, e.g. "web:1f2e3d4c@192.0.2.10"
+func webUser(id string, r *http.Request) string {
+	sum := sha256.Sum256([]byte(id))
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return fmt.Sprintf("web:%s@%s", hex.EncodeToString(sum[:4]), host)
+}
+
+// serveSession starts a session for an API client with POST, replying {"session": id}, or
+// ends the session named by the header with DELETE, disconnecting its connections
+func (s *webServer) serveSession(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.mu.Lock()
+		id, _ := s.newSession(r)
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"session": id})
+	case http.MethodDelete:
+		id := r.Header.Get(sessionHeader)
+		s.mu.Lock()
+		session, ok := s.sessions[id]
+		delete(s.sessions, id)
+		s.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no session %s", id)})
+			return
+		}
+		session.app.closeSessions()
+		writeJSON(w, http.StatusOK, map[string]interface{}{"result": nil})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST or DELETE"})
+	}
+}
+
+// serveCall runs an App method. The body is the JSON array of its arguments; the reply
+// is {"result": ...}, or {"error": "..."} when the method fails.
+func (s *webServer) serveCall(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+	if !webMethods[name] {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no method %s", name)})
+		return
+	}
+	session, err := s.session(w, r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	method := reflect.ValueOf(session.app).MethodByName(name)
+
+	var raw []json.RawMessage
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webMaxCallBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("the arguments exceed %d bytes", tooLarge.Limit)})
+		return
+	}
+	if err != nil || len(bytes.TrimSpace(body)) > 0 && json.Unmarshal(body, &raw) != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "the body must be a JSON array of arguments"})
+		return
+	}
+	if len(raw) != method.Type().NumIn() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s takes %d arguments, got %d", name, method.Type().NumIn(), len(raw))})
+		return
+	}
+	args := make([]reflect.Value, len(raw))
+	for i, data := range raw {
+		arg := reflect.New(method.Type().In(i))
+		if err := json.Unmarshal(data, arg.Interface()); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("argument %d of %s: %v", i+1, name, err)})
+			return
+		}
+		args[i] = arg.Elem()
+	}
+
+	var result interface{}
+	for _, out := range method.Call(args) {
+		if out.Type() != errorType {
+			result = out.Interface()
+		} else if !out.IsNil() {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": out.Interface().(error).Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"result": result})
+}
+
+// serveEvents upgrades to a WebSocket that receives the session's App events, such as
+// table updates, connection states and SSH prompts, as {"name": ..., "data": ...}
+func (s *webServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	session, err := s.session(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, w.Header())
+	if err != nil {
+		return
+	}
+	session.mu.Lock()
+	session.sockets[conn] = true
+	session.mu.Unlock()
+
+	// Nothing is expected from the browser; reading notices when it goes away
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			break
+		}
+	}
+	session.mu.Lock()
+	delete(session.sockets, conn)
+	session.lastUsed = time.Now()
+	session.mu.Unlock()
+	conn.Close()
+}
+
+// serveIndex serves index.html with the bridge loaded before the UI, so the UI's calls to
+// the desktop bindings and runtime go to this server
+func (s *webServer) serveIndex(w http.ResponseWriter) {
+	index, err := fs.ReadFile(s.assets, "index.html")
+	if err != nil {
+		http.Error(w, "the UI is not built into this binary", http.StatusNotFound)
+		return
+	}
+	bridge := `<script src="/bridge.js"></script>`
+	if bytes.Contains(index, []byte("<head>")) {
+		index = bytes.Replace(index, []byte("<head>"), []byte("<head>"+bridge), 1)
+	} else {
+		index = append([]byte(bridge), index...)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(index)
+}
+
+// expire disconnects the sessions left without a socket for longer than the idle time,
+// until the context is done, when every session is disconnected
+func (s *webServer) expire() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.mu.Lock()
+			for id, session := range s.sessions {
+				session.app.closeSessions()
+				delete(s.sessions, id)
+			}
+			s.mu.Unlock()
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		for id, session := range s.sessions {
+			if session.idleSince(s.idle) {
+				session.app.closeSessions()
+				delete(s.sessions, id)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (ws *webSession) touch() {
+	ws.mu.Lock()
+	ws.lastUsed = time.Now()
+	ws.mu.Unlock()
+}
+
+func (ws *webSession) idleSince(idle time.Duration) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return len(ws.sockets) == 0 && time.Since(ws.lastUsed) > idle
+}
+
+// push sends an App event to every socket of the session; a socket that cannot take it
+// in time is closed, and the browser reconnects and reloads
+func (ws *webSession) push(name string, data interface{}) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for conn := range ws.sockets {
+		conn.SetWriteDeadline(time.Now().Add(webWriteTimeout))
+		if err := conn.WriteJSON(webEvent{Name: name, Data: data}); err != nil {
+			conn.Close()
+			delete(ws.sockets, conn)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// randomToken returns 128 random bits in hex
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// serve runs the web server until interrupted
+func (c *cli) serve(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+	}
+	if c.token == "" {
+		c.token = randomToken()
+		fmt.Fprintf(c.stderr, "generated token: %s\n", c.token)
+	}
+	web, err := fs.Sub(assets, "frontend/dist")
+	if err != nil {
+		return err
+	}
+	ws := newWebServer(c.app, c.token, web, c.idle)
+	go ws.expire()
+	server := &http.Server{Addr: c.listen, Handler: ws, ReadHeaderTimeout: webReadHeaderTimeout}
+	go func() {
+		<-c.app.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	scheme := "http"
+	if c.httpsCert != "" {
+		scheme = "https"
+	}
+	fmt.Fprintf(c.stderr, "serving on %s://%s/?token=<token>\n", scheme, c.listen)
+	if c.httpsCert != "" {
+		err = server.ListenAndServeTLS(c.httpsCert, c.httpsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// bridgeScript stands in for the desktop bindings and runtime: App calls become API calls
+// and events arrive over the WebSocket, reconnected whenever it drops
+const bridgeScript = `(() => {
+  const call = async (method, args) => {
+    const response = await fetch("/api/call/" + method, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(args),
+    });
+    const body = await response.json();
+    if (!response.ok) throw body.error;
+    return body.result;
+  };
+  window.go = { main: { App: new Proxy({}, { get: (_, method) => (...args) => call(method, args) }) } };
+
+  const listeners = {};
+  const off = (name, listener) => {
+    listeners[name] = (listeners[name] || []).filter((l) => l !== listener);
+  };
+  const connect = () => {
+    const socket = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/api/events");
+    socket.onmessage = (message) => {
+      const event = JSON.parse(message.data);
+      for (const listener of (listeners[event.name] || []).slice()) {
+        if (listener.max > 0 && --listener.max === 0) off(event.name, listener);
+        listener.callback(event.data);
+      }
+    };
+    socket.onclose = () => setTimeout(connect, 2000);
+  };
+  connect();
+
+  const runtime = {
+    EventsOnMultiple(name, callback, max) {
+      const listener = { callback, max };
+      (listeners[name] = listeners[name] || []).push(listener);
+      return () => off(name, listener);
+    },
+    EventsOff(name, ...more) {
+      for (const n of [name, ...more]) delete listeners[n];
+    },
+    EventsOffAll() {
+      for (const n of Object.keys(listeners)) delete listeners[n];
+    },
+    BrowserOpenURL(url) {
+      window.open(url, "_blank");
+    },
+  };
+  // Window and dialog functions have no meaning in a browser tab
+  window.runtime = new Proxy(runtime, { get: (target, name) => target[name] || (() => {}) });
+})();
+`
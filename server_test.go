@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"ovsdb-viewer/internal/ovsdb"
+
+	"github.com/gorilla/websocket"
+	ovsdbovsdb "github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func testWebServer(t *testing.T) (*webServer, *httptest.Server) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	app := NewApp()
+	app.ctx = context.Background()
+	assets := fstest.MapFS{"index.html": {Data: []byte("<html><head></head><body></body></html>")}}
+	ws := newWebServer(app, "secret", assets, 0)
+	server := httptest.NewServer(ws)
+	t.Cleanup(server.Close)
+	return ws, server
+}
+
+// call posts to the API with the bearer token and the given headers, and decodes the reply
+func call(t *testing.T, url, body string, header map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var reply map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&reply)
+	return resp.StatusCode, reply
+}
+
+func TestWebServerToken(t *testing.T) {
+	_, server := testWebServer(t)
+	for _, header := range []string{"", "Bearer wrong"} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/session", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("authorization %q got status %d", header, resp.StatusCode)
+		}
+	}
+
+	// The token in the address becomes a cookie, and the page starts a session
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+	resp, err := client.Get(server.URL + "/?token=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.RawQuery != "" {
+		t.Fatalf("visit with the token ended at %s with status %d", resp.Request.URL, resp.StatusCode)
+	}
+	resp, err = client.Post(server.URL+"/api/call/ListSessions", "application/json", strings.NewReader("[]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("call with the cookies got status %d", resp.StatusCode)
+	}
+}
+
+func TestWebServerBearerSessions(t *testing.T) {
+	ws, server := testWebServer(t)
+	if status, reply := call(t, server.URL+"/api/call/ListSessions", "[]", nil); status != http.StatusBadRequest {
+		t.Fatalf("call without a session got %d %v", status, reply)
+	}
+
+	status, reply := call(t, server.URL+"/api/session", "", nil)
+	id, _ := reply["session"].(string)
+	if status != http.StatusOK || id == "" {
+		t.Fatalf("starting a session got %d %v", status, reply)
+	}
+	header := map[string]string{sessionHeader: id}
+	for i := 0; i < 2; i++ {
+		if status, reply := call(t, server.URL+"/api/call/ListSessions", "[]", header); status != http.StatusOK {
+			t.Fatalf("call in the session got %d %v", status, reply)
+		}
+	}
+	if len(ws.sessions) != 1 {
+		t.Errorf("calls in one session left %d sessions", len(ws.sessions))
+	}
+	if status, _ := call(t, server.URL+"/api/call/ListSessions", "[]", map[string]string{sessionHeader: "unknown"}); status != http.StatusBadRequest {
+		t.Errorf("call in an unknown session got %d", status)
+	}
+
+	large := "[\"" + strings.Repeat("x", webMaxCallBody) + "\"]"
+	if status, _ := call(t, server.URL+"/api/call/ListSessions", large, header); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized call got %d", status)
+	}
+	if status, _ := call(t, server.URL+"/api/call/ListSSHHostAliases", "[]", header); status != http.StatusNotFound {
+		t.Errorf("method that is not exposed got %d", status)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/session", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set(sessionHeader, id)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(ws.sessions) != 0 {
+		t.Errorf("ending the session got %d and left %d sessions", resp.StatusCode, len(ws.sessions))
+	}
+}
+
+func TestWebServerConnect(t *testing.T) {
+	ws, server := testWebServer(t)
+	endpoint := startServer(t)
+	t.Cleanup(func() {
+		for _, session := range ws.sessions {
+			session.app.closeSessions()
+		}
+	})
+	start := func() map[string]string {
+		t.Helper()
+		status, reply := call(t, server.URL+"/api/session", "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("starting a session got %d %v", status, reply)
+		}
+		return map[string]string{sessionHeader: reply["session"].(string)}
+	}
+	first, second := start(), start()
+
+	status, reply := call(t, server.URL+"/api/call/ConnectDynamic", `["", {"endpoints": [{"endpoint": "`+endpoint+`"}]}, ""]`, first)
+	id, _ := reply["result"].(string)
+	if status != http.StatusOK || id == "" {
+		t.Fatalf("connect got %d %v", status, reply)
+	}
+	if status, reply := call(t, server.URL+"/api/call/ConnectDynamic", `["", {"endpoints": []}]`, first); status != http.StatusBadRequest {
+		t.Errorf("call with too few arguments got %d %v", status, reply)
+	}
+	if status, reply := call(t, server.URL+"/api/call/GetTableDynamic", `["`+id+`", "Open_vSwitch", "Other"]`, first); status != http.StatusInternalServerError || reply["error"] == nil {
+		t.Errorf("failing call got %d %v", status, reply)
+	}
+
+	// Each browser session has connections of its own
+	if _, reply := call(t, server.URL+"/api/call/ListSessions", "[]", first); len(reply["result"].([]interface{})) != 1 {
+		t.Errorf("sessions of the first browser session = %v", reply)
+	}
+	if _, reply := call(t, server.URL+"/api/call/ListSessions", "[]", second); len(reply["result"].([]interface{})) != 0 {
+		t.Errorf("sessions of the second browser session = %v", reply)
+	}
+	if status, _ := call(t, server.URL+"/api/call/GetTableDynamic", `["`+id+`", "Open_vSwitch", "Bridge"]`, second); status != http.StatusInternalServerError {
+		t.Errorf("reading a connection of another browser session got %d", status)
+	}
+
+	// Table updates are pushed over the event socket
+	header := http.Header{"Authorization": {"Bearer secret"}, sessionHeader: {first[sessionHeader]}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/events", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if status, reply := call(t, server.URL+"/api/call/MonitorTable", `["`+id+`", "Open_vSwitch", "Bridge"]`, first); status != http.StatusOK {
+		t.Fatalf("monitor got %d %v", status, reply)
+	}
+	client := &ovsdb.OVSDBClient{}
+	if err := client.Connect(context.Background(), ovsdb.ConnectionConfig{}, endpoint, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	if _, err := client.Transact(context.Background(), "", ovsdbovsdb.Operation{Op: ovsdbovsdb.OperationInsert, Table: "Bridge", Row: ovsdbovsdb.Row{"name": "br0"}}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event struct {
+			Name string      `json:"name"`
+			Data TableUpdate `json:"data"`
+		}
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("no table update: %v", err)
+		}
+		if event.Name == "table:update" {
+			if event.Data.Session != id || event.Data.Table != "Bridge" || len(event.Data.Inserted) != 1 || event.Data.Inserted[0]["name"].Atom.Value != "br0" {
+				t.Errorf("table update = %+v", event.Data)
+			}
+			break
+		}
+	}
+}
+
+func TestWebServerIndex(t *testing.T) {
+	_, server := testWebServer(t)
+	get := func(path string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if status, body := get("/"); status != http.StatusOK || !strings.Contains(body, `<head><script src="/bridge.js"></script></head>`) {
+		t.Errorf("index got %d %s", status, body)
+	}
+	if status, body := get("/bridge.js"); status != http.StatusOK || body == "" {
+		t.Errorf("bridge script got %d", status)
+	}
+	if status, _ := get("/missing.js"); status != http.StatusNotFound {
+		t.Errorf("missing asset got %d", status)
+	}
+}
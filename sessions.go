@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"ovsdb-viewer/internal/ovsdb"
+)
+
+// session is one open connection of the App, to a server or a database file. Bound methods
+// select it by id, so several servers can be browsed side by side.
+type session struct {
+	id        string
+	client    *ovsdb.OVSDBClient
+	endpoints []string
+	database  string
+	// readOnlySource tells whether the session's read-only mode came from the connection
+	// request, from the default or from being a file
+	readOnlySource string
+	opened         time.Time
+	// replaced is set once a reconnect put another connection in the session's place,
+	// to keep the old one's events from reaching the frontend
+	replaced atomic.Bool
+}
+
+// SessionInfo describes an open session
+type SessionInfo struct {
+	ID        string   `json:"id"`
+	Endpoints []string `json:"endpoints"`
+	Database  string   `json:"database"`
+	File      string   `json:"file,omitempty"`
+	State     string   `json:"state"`
+	ReadOnly  bool     `json:"readOnly"`
+	Opened    int64    `json:"opened"`
+}
+
+// FileSession is the session of a database file opened with OpenDatabaseFile
+type FileSession struct {
+	SessionID string `json:"sessionId"`
+	*ovsdb.DBFileInfo
+}
+
+// ListSessions returns the open sessions, oldest first
+func (a *App) ListSessions() []SessionInfo {
+	a.mu.RLock()
+	sessions := make([]*session, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.RUnlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].opened.Before(sessions[j].opened) })
+
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		info := SessionInfo{
+			ID:        s.id,
+			Endpoints: s.endpoints,
+			Database:  s.database,
+			State:     s.client.State(),
+			ReadOnly:  s.client.ReadOnly,
+			Opened:    s.opened.Unix(),
+		}
+		if file := s.client.FileInfo(); file != nil {
+			info.File = file.Path
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// newSessionID returns an id no session has used yet
+func (a *App) newSessionID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sessionSeq++
+	return strconv.FormatUint(a.sessionSeq, 10)
+}
+
+// session returns an open session by id
+func (a *App) session(sessionID string) (*session, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	s, ok := a.sessions[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("not connected: no session %q", sessionID)
+	}
+	return s, nil
+}
+
+// client returns the client of an open session
+func (a *App) client(sessionID string) (*ovsdb.OVSDBClient, error) {
+	s, err := a.session(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.client, nil
+}
+
+// register makes s the session of its id. A connection it replaces is closed once no
+// longer reachable, so calls racing the reconnect see either the old or the new one.
+func (a *App) register(s *session) {
+	s.opened = time.Now()
+	a.mu.Lock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]*session)
+	}
+	old := a.sessions[s.id]
+	if old != nil {
+		s.opened = old.opened
+	}
+	a.sessions[s.id] = s
+	a.mu.Unlock()
+	if old != nil {
+		old.replaced.Store(true)
+		old.client.Disconnect()
+	}
+}
+
+// closeSession disconnects a session and forgets it
+func (a *App) closeSession(sessionID string) {
+	a.mu.Lock()
+	s := a.sessions[sessionID]
+	delete(a.sessions, sessionID)
+	a.mu.Unlock()
+	if s != nil {
+		s.client.Disconnect()
+	}
+}
+
+// closeSessions disconnects every session
+func (a *App) closeSessions() {
+	a.mu.Lock()
+	sessions := a.sessions
+	a.sessions = nil
+	a.mu.Unlock()
+	for _, s := range sessions {
+		s.client.Disconnect()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"ovsdb-viewer/internal/ovsdb"
+
+	ovsdbovsdb "github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+func TestSessions(t *testing.T) {
+	a := testApp(t)
+	first, second := startServer(t), startServer(t)
+	client := &ovsdb.OVSDBClient{}
+	if err := client.Connect(context.Background(), ovsdb.ConnectionConfig{}, first, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect()
+	if _, err := client.Transact(context.Background(), "", ovsdbovsdb.Operation{Op: ovsdbovsdb.OperationInsert, Table: "Bridge", Row: ovsdbovsdb.Row{"name": "br0"}}); err != nil {
+		t.Fatal(err)
+	}
+	connect := func(id, endpoint string) string {
+		t.Helper()
+		id, err := a.ConnectDynamic(id, ConnectRequest{Endpoints: []EndpointConfig{{Endpoint: endpoint}}}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	bridges := func(id string) int {
+		t.Helper()
+		rows, err := a.GetTableDynamic(id, "Open_vSwitch", "Bridge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(rows)
+	}
+
+	// Sessions to two servers are open side by side
+	idA, idB := connect("", first), connect("", second)
+	if idA == idB {
+		t.Fatalf("both sessions have the id %s", idA)
+	}
+	sessions := a.ListSessions()
+	if len(sessions) != 2 || sessions[0].ID != idA || !reflect.DeepEqual(sessions[0].Endpoints, []string{first}) || sessions[1].ID != idB ||
+		sessions[0].State != ovsdb.StateConnected {
+		t.Errorf("sessions = %+v", sessions)
+	}
+	if bridges(idA) != 1 || bridges(idB) != 0 {
+		t.Errorf("sessions read %d and %d bridges", bridges(idA), bridges(idB))
+	}
+
+	// Connecting with the id of an open session replaces its connection
+	old, err := a.client(idA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := connect(idA, second); id != idA {
+		t.Errorf("reconnect returned the id %s, not %s", id, idA)
+	}
+	if sessions := a.ListSessions(); len(sessions) != 2 || sessions[0].ID != idA || !reflect.DeepEqual(sessions[0].Endpoints, []string{second}) {
+		t.Errorf("sessions after the reconnect = %+v", sessions)
+	}
+	if old.State() != ovsdb.StateDisconnected || bridges(idA) != 0 {
+		t.Errorf("replaced connection is %s, session reads %d bridges", old.State(), bridges(idA))
+	}
+
+	// Calls racing a reconnect see either connection
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := a.ConnectDynamic(idB, ConnectRequest{Endpoints: []EndpointConfig{{Endpoint: second}}}, ""); err != nil {
+				t.Errorf("reconnect: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := a.GetTableDynamic(idB, "Open_vSwitch", "Bridge"); err != nil {
+				t.Errorf("read during a reconnect: %v", err)
+			}
+			a.ListSessions()
+		}()
+	}
+	wg.Wait()
+
+	if err := a.DisconnectOVSDB(idB); err != nil {
+		t.Fatal(err)
+	}
+	if state := a.GetConnectionState(idB); state != ovsdb.StateDisconnected {
+		t.Errorf("closed session is %s", state)
+	}
+	if _, err := a.GetTableDynamic(idB, "Open_vSwitch", "Bridge"); err == nil || !strings.Contains(err.Error(), "no session") {
+		t.Errorf("read from a closed session returned %v", err)
+	}
+	if sessions := a.ListSessions(); len(sessions) != 1 || sessions[0].ID != idA {
+		t.Errorf("sessions after closing one = %+v", sessions)
+	}
+}